@@ -1,9 +1,12 @@
 package checks
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"log/slog"
 	"math/rand"
+	"slices"
 	"strings"
 	"time"
 )
@@ -16,14 +19,23 @@ type TaskCredential struct {
 
 // checks for each service
 type Runner interface {
-	Run(teamID uint, identifier string, roundID uint, resultsChan chan Result)
+	Run(ctx context.Context, teamID uint, identifier string, roundID uint, resultsChan chan Result)
 	Runnable() bool
 	Verify(box string, ip string, points int, timeout int, slapenalty int, slathreshold int) error
 	GetType() string
 	GetName() string
+	GetTarget() string
+	GetPoints() int
 	GetAttempts() int
+	GetTimeout() int
+	GetEveryRounds() int
+	GetInterval() int
 	GetCredlists() []string
+	GetTags() []string
+	GetDependsOn() []string
+	GetSlaRule() SlaRule
 	SetTaskCredentials(creds []TaskCredential)
+	SetTaskRun(run uint)
 }
 
 // services will inherit Service so that config.Config can be read from file, but will not be used after initial read
@@ -36,15 +48,32 @@ type Service struct {
 	Timeout         int              `toml:",omitempty"`
 	SlaPenalty      int              `toml:",omitempty"`
 	SlaThreshold    int              `toml:",omitempty"`
+	SlaPolicy       string           `toml:",omitempty"` // SlaPolicy is consecutive, rolling or escalating
+	SlaWindow       int              `toml:",omitempty"` // SlaWindow is the rounds the rolling SLA policy looks back on
 	LaunchTime      time.Time        `toml:",omitempty"`
 	StopTime        time.Time        `toml:",omitempty"`
 	Disabled        bool             `toml:",omitempty"`
 	Target          string           `toml:",omitempty"` // Target is the IP address or hostname for the box
 	ServiceType     string           `toml:",omitempty"` // ServiceType is the name of the Runner that checks the service
 	Attempts        int              `toml:",omitempty"` // Attempts is the number of times the service has been checked
+	Tags            []string         `toml:",omitempty"` // Tags a runner must carry to run this check (ex. windows-tools)
+	EveryRounds     int              `toml:",omitempty"` // EveryRounds runs the check only every N rounds
+	Interval        int              `toml:",omitempty"` // Interval runs the check only every so many seconds
+	DependsOn       []string         `toml:",omitempty"` // DependsOn names checks that must be up for this check to run (ex. ping)
+	Selection       string           `toml:",omitempty"` // Selection is how the item tested each round is picked: random, round or rotate
 	TaskCredentials []TaskCredential `toml:"-"`          // Credentials from task payload (set per-task, not from config)
+	TaskRun         uint             `toml:"-"`          // TaskRun counts the check's runs up to this one, from task payload (set per-task, not from config)
 }
 
+const (
+	// StateNoResult marks a result the engine filled in for a check that never reported back
+	StateNoResult = "no_result"
+	// StateSkipped marks a round an interval check was not scheduled in
+	StateSkipped = "skipped"
+	// StateDependencyDown marks a round a check was not run in because a check it depends on was down
+	StateDependencyDown = "dependency_down"
+)
+
 type Result struct {
 	ServiceName string `json:"name,omitempty"`
 	Target      string `json:"target,omitempty"`
@@ -55,6 +84,21 @@ type Result struct {
 	Points      int    `json:"points,omitempty"`
 	ServiceType string `json:"service_type,omitempty"`
 	RoundID     uint   `json:"round_id"`
+	TaskID      string `json:"task_id,omitempty"`  // ID of the engine.Task this result answers
+	OwnerID     uint   `json:"owner_id,omitempty"` // team holding the box, only set by koth checks
+	State       string `json:"-"`                  // set by the engine for results that are not a real pass/fail
+	RootCause   string `json:"-"`                  // check a dependency down result is blamed on
+
+	// Item is the url, record, command, query or file the check tested this round
+	Item string `json:"item,omitempty"`
+
+	// Parts and PartsPassed count the sub-items of a check scored with partial credit
+	Parts       int `json:"parts,omitempty"`
+	PartsPassed int `json:"parts_passed,omitempty"`
+
+	// Every attempt made this round, the last one being the result above
+	Attempts    []Attempt `json:"attempts,omitempty"`
+	MaxAttempts int       `json:"max_attempts,omitempty"`
 
 	// Added for runner visualization
 	RunnerID   string `json:"runner_id,omitempty"`
@@ -63,6 +107,28 @@ type Result struct {
 	StatusText string `json:"status_text,omitempty"` // "running", "success", or "failed"
 }
 
+// EarnedPoints returns the points the result is worth: all of them for a pass,
+// or the share of sub-items that passed for a check scored with partial credit
+func (r Result) EarnedPoints() int {
+	if r.Parts > 0 {
+		return r.Points * min(r.PartsPassed, r.Parts) / r.Parts
+	}
+	if r.Status {
+		return r.Points
+	}
+	return 0
+}
+
+// Attempt is one try of a check within a round
+type Attempt struct {
+	Number     int    `json:"number"`
+	Status     bool   `json:"status"`
+	Error      string `json:"error,omitempty"`
+	Debug      string `json:"debug,omitempty"`
+	DurationMs int64  `json:"duration_ms"`
+	RunnerID   string `json:"runner_id,omitempty"`
+}
+
 func (service *Service) GetType() string {
 	return service.ServiceType
 }
@@ -71,10 +137,33 @@ func (service *Service) GetName() string {
 	return service.Name
 }
 
+// GetTarget returns the configured target, still templated with "_" for the team identifier
+func (service *Service) GetTarget() string {
+	return service.Target
+}
+
+func (service *Service) GetPoints() int {
+	return service.Points
+}
+
 func (service *Service) GetAttempts() int {
 	return service.Attempts
 }
 
+// GetTimeout returns the seconds a single attempt of the check may take
+func (service *Service) GetTimeout() int {
+	return service.Timeout
+}
+
+func (service *Service) GetEveryRounds() int {
+	return service.EveryRounds
+}
+
+// GetInterval returns the seconds between runs of the check, 0 to run it every round
+func (service *Service) GetInterval() int {
+	return service.Interval
+}
+
 func (service *Service) GetCredlists() []string {
 	return service.CredLists
 }
@@ -83,11 +172,55 @@ func (service *Service) SetCredlists(lists []string) {
 	service.CredLists = lists
 }
 
+func (service *Service) GetTags() []string {
+	return service.Tags
+}
+
+func (service *Service) SetTags(tags []string) {
+	service.Tags = tags
+}
+
+func (service *Service) GetSlaRule() SlaRule {
+	return SlaRule{
+		Policy:    service.SlaPolicy,
+		Threshold: service.SlaThreshold,
+		Window:    service.SlaWindow,
+		Penalty:   service.SlaPenalty,
+	}
+}
+
+// SetSlaDefaults fills in the SLA policy and window when the check does not set
+// its own. Without a window anywhere, it is twice the check's threshold.
+func (service *Service) SetSlaDefaults(policy string, window int) {
+	if service.SlaPolicy == "" {
+		service.SlaPolicy = policy
+	}
+	if service.SlaWindow == 0 {
+		service.SlaWindow = window
+	}
+	if service.SlaWindow == 0 {
+		service.SlaWindow = 2 * service.SlaThreshold
+	}
+}
+
+func (service *Service) GetDependsOn() []string {
+	return service.DependsOn
+}
+
+func (service *Service) SetDependsOn(names []string) {
+	service.DependsOn = names
+}
+
 // SetTaskCredentials sets credentials from the task payload (thread-safe per-instance)
 func (service *Service) SetTaskCredentials(creds []TaskCredential) {
 	service.TaskCredentials = creds
 }
 
+// SetTaskRun sets which run of the check the task is, from the task payload
+func (service *Service) SetTaskRun(run uint) {
+	service.TaskRun = run
+}
+
 func (service *Service) getCreds(teamID uint) (string, string, error) {
 	if len(service.TaskCredentials) == 0 {
 		return "", "", errors.New("no credentials available")
@@ -118,6 +251,15 @@ func (service *Service) Configure(ip string, points int, timeout int, slapenalty
 	if service.Attempts == 0 {
 		service.Attempts = 1
 	}
+	if service.EveryRounds < 0 || service.Interval < 0 {
+		return errors.New("everyrounds and interval can't be negative on a " + strings.ToLower(service.ServiceType) + " check")
+	}
+	if service.Selection != "" && !slices.Contains(SelectionModes, service.Selection) {
+		return fmt.Errorf("selection must be one of %v on a %s check", SelectionModes, strings.ToLower(service.ServiceType))
+	}
+	if service.EveryRounds > 0 && service.Interval > 0 {
+		return errors.New("only one of everyrounds and interval can be set on a " + strings.ToLower(service.ServiceType) + " check")
+	}
 
 	return nil
 }
@@ -22,7 +22,7 @@ type Custom struct {
 	Regex   string
 }
 
-func (c Custom) Run(teamID uint, teamIdentifier string, roundID uint, resultsChan chan Result) {
+func (c Custom) Run(ctx context.Context, teamID uint, teamIdentifier string, roundID uint, resultsChan chan Result) {
 	definition := func(teamID uint, teamIdentifier string, checkResult Result, response chan Result) {
 
 		var username, password string
@@ -51,7 +51,8 @@ func (c Custom) Run(teamID uint, teamIdentifier string, roundID uint, resultsCha
 
 		// Create command with timeout context
 		timeout := time.Duration(c.Timeout) * time.Second
-		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		// also cancelled when the runner drains, which kills the command and lets the tmpfile below be cleaned up
+		ctx, cancel := context.WithTimeout(ctx, timeout)
 		defer cancel()
 		cmd := exec.CommandContext(ctx, "/bin/sh", "-c", formedCommand) // #nosec G204 -- custom checks intentionally run user-defined commands
 
@@ -0,0 +1,23 @@
+package checks
+
+import (
+	"context"
+	"net"
+)
+
+// contextDialer dials like net.Dialer for client libraries that take a dialer
+// without a context. The connection is closed once ctx is cancelled, so a
+// draining runner doesn't wait on a check blocked reading from it.
+type contextDialer struct {
+	ctx    context.Context
+	dialer net.Dialer
+}
+
+func (d contextDialer) Dial(network, address string) (net.Conn, error) {
+	conn, err := d.dialer.DialContext(d.ctx, network, address)
+	if err != nil {
+		return nil, err
+	}
+	context.AfterFunc(d.ctx, func() { conn.Close() })
+	return conn, nil
+}
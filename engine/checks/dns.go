@@ -1,10 +1,12 @@
 package checks
 
 import (
+	"context"
 	"errors"
 	"fmt"
-	"math/rand"
+	"net"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 
@@ -14,88 +16,315 @@ import (
 type Dns struct {
 	Service
 	Record []DnsRecord
+	// PartialCredit checks every record each round and awards a share of the
+	// points for each one that passes, instead of checking one at random
+	PartialCredit bool `toml:",omitempty"`
+	// UseTcp sends every query over TCP instead of UDP
+	UseTcp bool `toml:",omitempty"`
+	// ZoneTransfer asserts a zone transfer of Zone is refused or allowed
+	ZoneTransfer string `toml:",omitempty"`
+	Zone         string `toml:",omitempty"`
+	// NoRecursion asserts the server does not resolve RecursionDomain, a name it
+	// is not authoritative for, for the scoring engine
+	NoRecursion     bool   `toml:",omitempty"`
+	RecursionDomain string `toml:",omitempty"`
 }
 
 type DnsRecord struct {
 	Kind   string
 	Domain string
 	Answer []string
+	// Dnssec requires the answer to come signed, with an RRSIG covering it
+	Dnssec bool `toml:",omitempty"`
 }
 
-func (c Dns) Run(teamID uint, teamIdentifier string, roundID uint, resultsChan chan Result) {
-	definition := func(teamID uint, teamIdentifier string, checkResult Result, response chan Result) {
-		// Pick a record
-		record := c.Record[rand.Intn(len(c.Record))] // #nosec G404 -- non-crypto selection of DNS record to test
-		fqdn := dns.Fqdn(strings.ReplaceAll(dns.Fqdn(record.Domain), "_", teamIdentifier))
-
-		// Setup for dns query
-		var msg dns.Msg
-
-		// switch of kind of record (A, MX, etc)
-		// TODO: add more values
-		switch record.Kind {
-		case "A":
-			msg.SetQuestion(fqdn, dns.TypeA)
-		case "MX":
-			msg.SetQuestion(fqdn, dns.TypeMX)
-		}
+// dnsAssertion checks one property of the server, returning whether it held
+// along with the error and debug output to report
+type dnsAssertion func() (bool, string, string)
 
-		// Make it obey timeout via deadline
-		// deadctx, cancel := context.WithTimeout(context.TODO(), time.Duration(2)*time.Second)
-		// defer cancel()
+func (c Dns) Run(ctx context.Context, teamID uint, teamIdentifier string, roundID uint, resultsChan chan Result) {
+	definition := func(teamID uint, teamIdentifier string, checkResult Result, response chan Result) {
+		// zone transfer and recursion are asserted every round on top of the records
+		assertions := c.assertions(ctx, teamIdentifier)
 
-		// Send the query
-		client := dns.Client{Timeout: time.Duration(c.Timeout-1) * time.Second, DialTimeout: time.Duration(c.Timeout-1) * time.Second}
-		// _, _ = dns.ExchangeContext(deadctx, &msg, fmt.Sprintf("%s:%d", c.Target, c.Port)) // double tap for propagation
-		in, rtt, err := client.Exchange(&msg, fmt.Sprintf("%s:%d", c.Target, c.Port))
-		if err != nil {
-			if errors.Is(err, os.ErrDeadlineExceeded) {
-				in, rtt, err = client.Exchange(&msg, fmt.Sprintf("%s:%d", c.Target, c.Port))
-				if err != nil {
-					checkResult.Error = "error sending query"
-					checkResult.Debug = "record " + record.Domain + ":" + fmt.Sprint(record.Answer) + fmt.Sprintf("(took %s)", rtt) + ": " + err.Error()
-					response <- checkResult
-					return
+		if !c.PartialCredit {
+			// Pick a record
+			record := c.Record[c.pick(roundID, len(c.Record))]
+			checkResult.Item = record.Kind + " " + record.Domain
+			checkResult.Status, checkResult.Error, checkResult.Debug = c.checkRecord(ctx, record, teamIdentifier)
+			for _, assertion := range assertions {
+				if !checkResult.Status {
+					break
+				}
+				ok, errMsg, debug := assertion()
+				if !ok {
+					checkResult.Status = false
+					checkResult.Error = errMsg
 				}
-			} else {
-				checkResult.Error = "error sending query"
-				checkResult.Debug = "record " + record.Domain + ":" + fmt.Sprint(record.Answer) + fmt.Sprintf("(took %s)", rtt) + ": " + err.Error()
-				response <- checkResult
-				return
+				checkResult.Debug += "; " + debug
 			}
-		}
-
-		// Check if we got any records
-		if len(in.Answer) < 1 {
-			checkResult.Error = "no records received"
-			checkResult.Debug = "record " + record.Domain + "-> " + fmt.Sprint(record.Answer)
 			response <- checkResult
 			return
 		}
 
-		// Loop through results and check for correct match
-		for _, answer := range in.Answer {
-			// Check if an answer is an A record and it matches the expected IP
-			for _, expectedAnswer := range record.Answer {
-				expectedAnswer = strings.ReplaceAll(expectedAnswer, "_", teamIdentifier)
-				if a, ok := answer.(*dns.A); ok && (a.A).String() == expectedAnswer {
-					checkResult.Status = true
-					checkResult.Debug = fmt.Sprintf("record %s returned %s. acceptable answers were: %v", record.Domain, expectedAnswer, record.Answer)
-					response <- checkResult
-					return
-				}
-			}
+		// every record and assertion is worth an equal share of the points
+		parts := make([]dnsAssertion, 0, len(c.Record)+len(assertions))
+		for _, record := range c.Record {
+			parts = append(parts, func() (bool, string, string) { return c.checkRecord(ctx, record, teamIdentifier) })
 		}
+		parts = append(parts, assertions...)
 
-		// If we reach here no records matched expected IP and check fails
-		checkResult.Error = "incorrect answer(s) received from DNS"
-		checkResult.Debug = "record " + record.Domain + "-> acceptable answers were: " + fmt.Sprint(record.Answer) + ", received " + fmt.Sprint(in.Answer)
+		var debug []string
+		for _, part := range parts {
+			ok, errMsg, partDebug := part()
+			if ok {
+				checkResult.PartsPassed++
+			} else if checkResult.Error == "" {
+				checkResult.Error = errMsg
+			}
+			debug = append(debug, partDebug)
+		}
+		checkResult.Parts = len(parts)
+		checkResult.Status = checkResult.PartsPassed == checkResult.Parts
+		checkResult.Debug = fmt.Sprintf("%d of %d records and assertions passed: %s", checkResult.PartsPassed, checkResult.Parts, strings.Join(debug, "; "))
 		response <- checkResult
 	}
 
 	c.Service.Run(teamID, teamIdentifier, roundID, resultsChan, definition)
 }
 
+// assertions returns the server properties the check asserts besides its records
+func (c Dns) assertions(ctx context.Context, teamIdentifier string) []dnsAssertion {
+	var assertions []dnsAssertion
+	if c.ZoneTransfer != "" {
+		assertions = append(assertions, func() (bool, string, string) { return c.checkZoneTransfer(ctx, teamIdentifier) })
+	}
+	if c.NoRecursion {
+		assertions = append(assertions, func() (bool, string, string) { return c.checkNoRecursion(ctx) })
+	}
+	return assertions
+}
+
+func (c Dns) address() string {
+	return net.JoinHostPort(c.Target, strconv.Itoa(c.Port))
+}
+
+// exchange sends a query over the check's transport, retrying once on a timeout
+func (c Dns) exchange(ctx context.Context, msg *dns.Msg) (*dns.Msg, time.Duration, error) {
+	client := dns.Client{Timeout: time.Duration(c.Timeout-1) * time.Second, DialTimeout: time.Duration(c.Timeout-1) * time.Second}
+	if c.UseTcp {
+		client.Net = "tcp"
+	}
+	in, rtt, err := client.ExchangeContext(ctx, msg, c.address())
+	if err != nil && errors.Is(err, os.ErrDeadlineExceeded) {
+		// double tap for propagation
+		in, rtt, err = client.ExchangeContext(ctx, msg, c.address())
+	}
+	return in, rtt, err
+}
+
+// checkRecord queries one record of the check, returning whether it passed along
+// with the error and debug output to report
+func (c Dns) checkRecord(ctx context.Context, record DnsRecord, teamIdentifier string) (bool, string, string) {
+	qtype := dnsRecordTypes[strings.ToUpper(record.Kind)]
+	domain := teamDnsName(record.Domain, teamIdentifier)
+	fqdn := dns.Fqdn(domain)
+	if reverse, err := dns.ReverseAddr(domain); qtype == dns.TypePTR && err == nil {
+		// PTR records can be given as the address, they are looked up in its reverse zone
+		fqdn = reverse
+	}
+
+	// Setup for dns query
+	var msg dns.Msg
+	msg.SetQuestion(fqdn, qtype)
+	if record.Dnssec {
+		// ask for signatures with the DNSSEC OK bit
+		msg.SetEdns0(4096, true)
+	}
+
+	// Send the query
+	in, rtt, err := c.exchange(ctx, &msg)
+	if err != nil {
+		return false, "error sending query", "record " + record.Domain + ":" + fmt.Sprint(record.Answer) + fmt.Sprintf("(took %s)", rtt) + ": " + err.Error()
+	}
+
+	// Check if we got any records
+	if len(in.Answer) < 1 {
+		return false, "no records received", "record " + record.Domain + "-> " + fmt.Sprint(record.Answer)
+	}
+
+	// Loop through results and check for correct match
+	for _, answer := range in.Answer {
+		// answers can include the CNAMEs followed to get to the record, only the records asked for count
+		if answer.Header().Rrtype != qtype {
+			continue
+		}
+		for _, expectedAnswer := range record.Answer {
+			expectedAnswer = teamDnsName(expectedAnswer, teamIdentifier)
+			if !dnsAnswerMatches(answer, expectedAnswer) {
+				continue
+			}
+			if record.Dnssec && !signed(in.Answer, qtype) {
+				return false, "answer was not signed", fmt.Sprintf("%s record %s returned %s without an RRSIG", record.Kind, record.Domain, expectedAnswer)
+			}
+			return true, "", fmt.Sprintf("%s record %s returned %s. acceptable answers were: %v", record.Kind, record.Domain, expectedAnswer, record.Answer)
+		}
+	}
+
+	// If we reach here no records matched expected answers and check fails
+	return false, "incorrect answer(s) received from DNS", record.Kind + " record " + record.Domain + "-> acceptable answers were: " + fmt.Sprint(record.Answer) + ", received " + fmt.Sprint(in.Answer)
+}
+
+// signed reports whether the answer section holds an RRSIG over records of qtype
+func signed(answers []dns.RR, qtype uint16) bool {
+	for _, answer := range answers {
+		if sig, ok := answer.(*dns.RRSIG); ok && sig.TypeCovered == qtype {
+			return true
+		}
+	}
+	return false
+}
+
+// checkZoneTransfer requests a transfer of the zone and checks it was refused or
+// allowed as the check asserts. A server that won't take the TCP connection
+// refuses it too.
+func (c Dns) checkZoneTransfer(ctx context.Context, teamIdentifier string) (bool, string, string) {
+	zone := dns.Fqdn(teamDnsName(c.Zone, teamIdentifier))
+	var msg dns.Msg
+	msg.SetAxfr(zone)
+
+	timeout := time.Duration(c.Timeout-1) * time.Second
+	transfer := dns.Transfer{DialTimeout: timeout, ReadTimeout: timeout}
+	records := 0
+	conn, err := contextDialer{ctx: ctx, dialer: net.Dialer{Timeout: timeout}}.Dial("tcp", c.address())
+	var envelopes chan *dns.Envelope
+	if err == nil {
+		defer conn.Close()
+		transfer.Conn = &dns.Conn{Conn: conn}
+		envelopes, err = transfer.In(&msg, c.address())
+	}
+	if err == nil {
+		for envelope := range envelopes {
+			if envelope.Error != nil {
+				err = envelope.Error
+				break
+			}
+			records += len(envelope.RR)
+		}
+	}
+	allowed := err == nil && records > 0
+
+	switch {
+	case c.ZoneTransfer == "refused" && allowed:
+		return false, "zone transfer was allowed", fmt.Sprintf("AXFR of %s returned %d records", zone, records)
+	case c.ZoneTransfer == "allowed" && !allowed:
+		debug := fmt.Sprintf("AXFR of %s returned no records", zone)
+		if err != nil {
+			debug = fmt.Sprintf("AXFR of %s failed: %s", zone, err)
+		}
+		return false, "zone transfer was refused", debug
+	case allowed:
+		return true, "", fmt.Sprintf("AXFR of %s allowed with %d records", zone, records)
+	default:
+		return true, "", fmt.Sprintf("AXFR of %s refused", zone)
+	}
+}
+
+// checkNoRecursion asks the server to resolve a name it is not authoritative for
+// and checks it did not
+func (c Dns) checkNoRecursion(ctx context.Context) (bool, string, string) {
+	var msg dns.Msg
+	msg.SetQuestion(dns.Fqdn(c.RecursionDomain), dns.TypeA)
+	msg.RecursionDesired = true
+
+	in, _, err := c.exchange(ctx, &msg)
+	if err != nil {
+		return false, "error sending recursive query", "recursive query for " + c.RecursionDomain + ": " + err.Error()
+	}
+	if in.Rcode == dns.RcodeSuccess && len(in.Answer) > 0 {
+		return false, "server allows open recursion", "recursive query for " + c.RecursionDomain + " was answered with " + fmt.Sprint(in.Answer)
+	}
+	return true, "", "recursive query for " + c.RecursionDomain + " was not answered (" + dns.RcodeToString[in.Rcode] + ")"
+}
+
+// dnsRecordTypes are the kinds of records a check can query
+var dnsRecordTypes = map[string]uint16{
+	"A":     dns.TypeA,
+	"AAAA":  dns.TypeAAAA,
+	"CNAME": dns.TypeCNAME,
+	"MX":    dns.TypeMX,
+	"NS":    dns.TypeNS,
+	"TXT":   dns.TypeTXT,
+	"PTR":   dns.TypePTR,
+	"SRV":   dns.TypeSRV,
+	"SOA":   dns.TypeSOA,
+}
+
+// dnsAnswerMatches reports whether a record received matches an expected answer.
+// Names compare without regard to case or the trailing dot. MX answers may lead
+// with the preference ("10 mail.example.com"), SRV answers with the port
+// ("389 dc01.example.com") or all of priority, weight and port, and SOA answers
+// are the serial, or the lowest acceptable serial when prefixed with ">=".
+func dnsAnswerMatches(answer dns.RR, expected string) bool {
+	fields := strings.Fields(expected)
+	switch rr := answer.(type) {
+	case *dns.A:
+		return rr.A.Equal(net.ParseIP(expected))
+	case *dns.AAAA:
+		return rr.AAAA.Equal(net.ParseIP(expected))
+	case *dns.CNAME:
+		return sameDnsName(rr.Target, expected)
+	case *dns.NS:
+		return sameDnsName(rr.Ns, expected)
+	case *dns.PTR:
+		return sameDnsName(rr.Ptr, expected)
+	case *dns.TXT:
+		return strings.Join(rr.Txt, "") == expected
+	case *dns.MX:
+		switch len(fields) {
+		case 1:
+			return sameDnsName(rr.Mx, fields[0])
+		case 2:
+			return fields[0] == strconv.Itoa(int(rr.Preference)) && sameDnsName(rr.Mx, fields[1])
+		}
+	case *dns.SRV:
+		switch len(fields) {
+		case 1:
+			return sameDnsName(rr.Target, fields[0])
+		case 2:
+			return fields[0] == strconv.Itoa(int(rr.Port)) && sameDnsName(rr.Target, fields[1])
+		case 4:
+			return fields[0] == strconv.Itoa(int(rr.Priority)) && fields[1] == strconv.Itoa(int(rr.Weight)) &&
+				fields[2] == strconv.Itoa(int(rr.Port)) && sameDnsName(rr.Target, fields[3])
+		}
+	case *dns.SOA:
+		if minimum, ok := strings.CutPrefix(expected, ">="); ok {
+			serial, err := strconv.ParseUint(strings.TrimSpace(minimum), 10, 32)
+			return err == nil && uint64(rr.Serial) >= serial
+		}
+		return expected == strconv.FormatUint(uint64(rr.Serial), 10)
+	}
+	return false
+}
+
+// teamDnsName fills the team identifier into a name or answer. Labels starting
+// with an underscore, like the _ldap._tcp of SRV records, keep that underscore.
+func teamDnsName(name string, teamIdentifier string) string {
+	labels := strings.Split(name, ".")
+	for i, label := range labels {
+		if len(label) > 1 && label[0] == '_' {
+			labels[i] = "_" + strings.ReplaceAll(label[1:], "_", teamIdentifier)
+		} else {
+			labels[i] = strings.ReplaceAll(label, "_", teamIdentifier)
+		}
+	}
+	return strings.Join(labels, ".")
+}
+
+func sameDnsName(a, b string) bool {
+	return strings.EqualFold(dns.Fqdn(a), dns.Fqdn(b))
+}
+
 func (c *Dns) Verify(box string, ip string, points int, timeout int, slapenalty int, slathreshold int) error {
 	if c.ServiceType == "" {
 		c.ServiceType = "Dns"
@@ -115,6 +344,23 @@ func (c *Dns) Verify(box string, ip string, points int, timeout int, slapenalty
 	if c.Name == "" {
 		c.Name = box + "-" + c.Display
 	}
+	for _, record := range c.Record {
+		if _, ok := dnsRecordTypes[strings.ToUpper(record.Kind)]; !ok {
+			return errors.New("dns check " + c.Name + " has unsupported record kind " + record.Kind)
+		}
+	}
+	switch c.ZoneTransfer {
+	case "":
+	case "refused", "allowed":
+		if c.Zone == "" {
+			return errors.New("dns check " + c.Name + " asserts a zone transfer but has no zone")
+		}
+	default:
+		return errors.New("dns check " + c.Name + " zonetransfer must be refused or allowed")
+	}
+	if c.NoRecursion && c.RecursionDomain == "" {
+		c.RecursionDomain = "example.com"
+	}
 
 	return nil
 }
@@ -1,10 +1,10 @@
 package checks
 
 import (
+	"context"
 	"errors"
 	"io"
 	"log/slog"
-	"math/rand"
 	"regexp"
 	"strconv"
 	"strings"
@@ -24,9 +24,9 @@ type FtpFile struct {
 	Regex string
 }
 
-func (c Ftp) Run(teamID uint, teamIdentifier string, roundID uint, resultsChan chan Result) {
+func (c Ftp) Run(ctx context.Context, teamID uint, teamIdentifier string, roundID uint, resultsChan chan Result) {
 	definition := func(teamID uint, teamIdentifier string, checkResult Result, response chan Result) {
-		conn, err := ftp.Dial(c.Target+":"+strconv.Itoa(c.Port), ftp.DialWithTimeout(time.Duration(c.Timeout)*time.Second))
+		conn, err := ftp.Dial(c.Target+":"+strconv.Itoa(c.Port), ftp.DialWithContext(ctx), ftp.DialWithTimeout(time.Duration(c.Timeout)*time.Second))
 		if err != nil {
 			checkResult.Error = "ftp connection failed"
 			checkResult.Debug = err.Error()
@@ -57,7 +57,8 @@ func (c Ftp) Run(teamID uint, teamIdentifier string, roundID uint, resultsChan c
 		}
 
 		if len(c.File) > 0 {
-			file := c.File[rand.Intn(len(c.File))] // #nosec G404 -- non-crypto selection of file to test
+			file := c.File[c.pick(roundID, len(c.File))]
+			checkResult.Item = file.Name
 			r, err := conn.Retr(file.Name)
 			if err != nil {
 				checkResult.Error = "failed to retrieve file " + file.Name
@@ -5,7 +5,9 @@ import (
 	"encoding/hex"
 	"fmt"
 	"io"
+	"math"
 	"os"
+	"strings"
 
 	"github.com/pmezard/go-difflib/difflib"
 )
@@ -18,8 +20,19 @@ func FileDifference(fileName string, fileContent string) (int, error) {
 	if err != nil {
 		return 0, err
 	}
-	diffMatcher := difflib.NewMatcher([]string{originalFileContent}, []string{fileContent})
-	return int((diffMatcher.Ratio() + 0.5) * 100), nil
+	return ContentDifference(originalFileContent, fileContent), nil
+}
+
+// ContentDifference returns the percentage of lines
+// that differ between two contents, 0 when they are
+// the same and 100 when they have nothing in common.
+func ContentDifference(original string, content string) int {
+	if original == content {
+		return 0
+	}
+	lines := func(s string) []string { return strings.Split(strings.TrimSuffix(s, "\n"), "\n") }
+	diffMatcher := difflib.NewMatcher(lines(original), lines(content))
+	return 100 - int(math.Round(diffMatcher.Ratio()*100))
 }
 
 // FileHash returns the sha256sum of the filename
@@ -44,7 +57,7 @@ func StringHash(fileContent string) (string, error) {
 }
 
 func GetFile(fileName string) (string, error) {
-	root, err := os.OpenRoot("./scoredfiles")
+	root, err := os.OpenRoot("./config/scoredfiles")
 	if err != nil {
 		return "", fmt.Errorf("failed to open scoredfiles directory: %w", err)
 	}
@@ -1,6 +1,7 @@
 package checks
 
 import (
+	"context"
 	"crypto/tls"
 	"fmt"
 	"log/slog"
@@ -13,15 +14,17 @@ import (
 
 type Imap struct {
 	Service
+	TlsAssertions
 	Domain    string
 	Encrypted bool
 }
 
-func (c Imap) Run(teamID uint, teamIdentifier string, roundID uint, resultsChan chan Result) {
+func (c Imap) Run(ctx context.Context, teamID uint, teamIdentifier string, roundID uint, resultsChan chan Result) {
 	definition := func(teamID uint, teamIdentifier string, checkResult Result, response chan Result) {
 		// Create a dialer so we can set timeouts
-		dialer := net.Dialer{
-			Timeout: time.Duration(c.Timeout) * time.Second,
+		dialer := contextDialer{
+			ctx:    ctx,
+			dialer: net.Dialer{Timeout: time.Duration(c.Timeout) * time.Second},
 		}
 
 		// Defining these allow the if/else block below
@@ -30,9 +33,9 @@ func (c Imap) Run(teamID uint, teamIdentifier string, roundID uint, resultsChan
 
 		// Connect to server with TLS or not
 		if c.Encrypted {
-			cl, err = client.DialWithDialerTLS(&dialer, fmt.Sprintf("%s:%d", c.Target, c.Port), &tls.Config{}) // #nosec G402 -- competition services may use self-signed certs
+			cl, err = client.DialWithDialerTLS(dialer, fmt.Sprintf("%s:%d", c.Target, c.Port), &tls.Config{}) // #nosec G402 -- competition services may use self-signed certs
 		} else {
-			cl, err = client.DialWithDialer(&dialer, fmt.Sprintf("%s:%d", c.Target, c.Port))
+			cl, err = client.DialWithDialer(dialer, fmt.Sprintf("%s:%d", c.Target, c.Port))
 		}
 		if err != nil {
 			checkResult.Error = "connection to server failed"
@@ -90,7 +93,7 @@ func (c Imap) Run(teamID uint, teamIdentifier string, roundID uint, resultsChan
 		response <- checkResult
 	}
 
-	c.Service.Run(teamID, teamIdentifier, roundID, resultsChan, definition)
+	c.Service.Run(teamID, teamIdentifier, roundID, resultsChan, c.withTls(ctx, c.Encrypted, c.Port, c.Timeout, definition))
 }
 
 func (c *Imap) Verify(box string, ip string, points int, timeout int, slapenalty int, slathreshold int) error {
@@ -110,5 +113,9 @@ func (c *Imap) Verify(box string, ip string, points int, timeout int, slapenalty
 		c.Name = box + "-" + c.Display
 	}
 
+	if err := c.verifyTls(c.Name, c.Encrypted); err != nil {
+		return err
+	}
+
 	return nil
 }
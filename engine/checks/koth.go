@@ -0,0 +1,236 @@
+package checks
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"al.essio.dev/pkg/shellescape"
+	"github.com/miekg/dns"
+	"golang.org/x/crypto/ssh"
+)
+
+// maximum number of bytes read from a box when looking for an ownership token
+const kothMaxRead = 1 << 20
+
+var kothMethods = []string{"file", "web", "banner", "dns"}
+
+// Koth probes a shared box for the ownership token of whichever team currently holds it
+type Koth struct {
+	Service
+	Method   string // Method is how the token is retrieved: file, web, banner or dns
+	Path     string `toml:",omitempty"` // Path is the file path for file probes or the URL path for web probes
+	Scheme   string `toml:",omitempty"`
+	Domain   string `toml:",omitempty"` // Domain is the TXT record queried for dns probes
+	Username string `toml:",omitempty"`
+	Password string `toml:",omitempty"`
+	PrivKey  string `toml:",omitempty"`
+
+	// Tokens maps team IDs to ownership tokens, filled in by the engine for each round
+	Tokens map[uint]string `toml:"-" json:"tokens,omitempty"`
+}
+
+func (c Koth) Run(ctx context.Context, teamID uint, teamIdentifier string, roundID uint, resultsChan chan Result) {
+	definition := func(teamID uint, teamIdentifier string, checkResult Result, response chan Result) {
+		content, err := c.probe()
+		if err != nil {
+			checkResult.Error = "error probing for ownership token"
+			checkResult.Debug = err.Error()
+			response <- checkResult
+			return
+		}
+
+		owners := FindTokenOwners(content, c.Tokens)
+		switch len(owners) {
+		case 0:
+			checkResult.Error = "no ownership token found"
+			checkResult.Debug = fmt.Sprintf("%s probe returned no known team token", c.Method)
+		case 1:
+			checkResult.Status = true
+			checkResult.OwnerID = owners[0]
+			checkResult.Debug = fmt.Sprintf("%s probe found token for team %d", c.Method, owners[0])
+		default:
+			checkResult.Error = "multiple ownership tokens found"
+			checkResult.Debug = fmt.Sprintf("%s probe found tokens for teams %v, box is contested", c.Method, owners)
+		}
+		response <- checkResult
+	}
+
+	c.Service.Run(teamID, teamIdentifier, roundID, resultsChan, definition)
+}
+
+// FindTokenOwners returns the sorted IDs of every team whose token appears in content
+func FindTokenOwners(content string, tokens map[uint]string) []uint {
+	owners := []uint{}
+	for id, token := range tokens {
+		if token != "" && strings.Contains(content, token) {
+			owners = append(owners, id)
+		}
+	}
+	slices.Sort(owners)
+	return owners
+}
+
+func (c Koth) probe() (string, error) {
+	address := c.Target + ":" + strconv.Itoa(c.Port)
+	timeout := time.Duration(c.Timeout) * time.Second
+
+	switch c.Method {
+	case "web":
+		client := &http.Client{
+			Timeout: timeout,
+			Transport: &http.Transport{
+				DisableKeepAlives: true,
+				TLSClientConfig: &tls.Config{
+					InsecureSkipVerify: true, // #nosec G402 -- competition services may use self-signed certs
+				},
+			},
+		}
+		resp, err := client.Get(fmt.Sprintf("%s://%s%s", c.Scheme, address, c.Path)) // #nosec G107 -- target comes from admin-controlled event.conf
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(io.LimitReader(resp.Body, kothMaxRead))
+		if err != nil {
+			return "", err
+		}
+		return string(body), nil
+
+	case "banner":
+		conn, err := net.DialTimeout("tcp", address, timeout)
+		if err != nil {
+			return "", err
+		}
+		defer conn.Close()
+		if err := conn.SetReadDeadline(time.Now().Add(timeout)); err != nil {
+			return "", err
+		}
+		buf := make([]byte, 4096)
+		n, err := conn.Read(buf)
+		if err != nil && n == 0 {
+			return "", err
+		}
+		return string(buf[:n]), nil
+
+	case "dns":
+		var msg dns.Msg
+		msg.SetQuestion(dns.Fqdn(c.Domain), dns.TypeTXT)
+		client := dns.Client{Timeout: timeout}
+		in, _, err := client.Exchange(&msg, address)
+		if err != nil {
+			return "", err
+		}
+		var txt []string
+		for _, answer := range in.Answer {
+			if t, ok := answer.(*dns.TXT); ok {
+				txt = append(txt, strings.Join(t.Txt, ""))
+			}
+		}
+		return strings.Join(txt, "\n"), nil
+
+	case "file":
+		config := &ssh.ClientConfig{
+			User:            c.Username,
+			HostKeyCallback: ssh.InsecureIgnoreHostKey(), // #nosec G106 -- competition hosts have unknown keys
+			Timeout:         timeout,
+		}
+		if c.PrivKey != "" {
+			key, err := os.ReadFile("./config/scoredfiles/" + c.PrivKey)
+			if err != nil {
+				return "", err
+			}
+			signer, err := ssh.ParsePrivateKey(key)
+			if err != nil {
+				return "", err
+			}
+			config.Auth = []ssh.AuthMethod{ssh.PublicKeys(signer)}
+		} else {
+			config.Auth = []ssh.AuthMethod{ssh.Password(c.Password)}
+		}
+		conn, err := ssh.Dial("tcp", address, config)
+		if err != nil {
+			return "", err
+		}
+		defer conn.Close()
+		session, err := conn.NewSession()
+		if err != nil {
+			return "", err
+		}
+		defer session.Close()
+		out, err := session.Output("head -c " + strconv.Itoa(kothMaxRead) + " " + shellescape.Quote(c.Path))
+		if err != nil {
+			return "", err
+		}
+		return string(out), nil
+	}
+
+	return "", errors.New("unknown koth method " + c.Method)
+}
+
+func (c *Koth) Verify(box string, ip string, points int, timeout int, slapenalty int, slathreshold int) error {
+	if c.ServiceType == "" {
+		c.ServiceType = "Koth"
+	}
+	if err := c.Service.Configure(ip, points, timeout, slapenalty, slathreshold); err != nil {
+		return err
+	}
+	if c.Display == "" {
+		c.Display = "koth"
+	}
+	if c.Name == "" {
+		c.Name = box + "-" + c.Display
+	}
+	if !slices.Contains(kothMethods, c.Method) {
+		return fmt.Errorf("koth check %s has invalid method %q (must be one of %v)", c.Name, c.Method, kothMethods)
+	}
+
+	switch c.Method {
+	case "web":
+		if c.Scheme == "" {
+			c.Scheme = "http"
+		}
+		if c.Path == "" {
+			c.Path = "/"
+		}
+		if c.Port == 0 {
+			if c.Scheme == "https" {
+				c.Port = 443
+			} else {
+				c.Port = 80
+			}
+		}
+	case "banner":
+		if c.Port == 0 {
+			return errors.New("koth check " + c.Name + " needs a port for banner probes")
+		}
+	case "dns":
+		if c.Port == 0 {
+			c.Port = 53
+		}
+		if c.Domain == "" {
+			return errors.New("koth check " + c.Name + " needs a domain for dns probes")
+		}
+	case "file":
+		if c.Port == 0 {
+			c.Port = 22
+		}
+		if c.Path == "" {
+			return errors.New("koth check " + c.Name + " needs a path for file probes")
+		}
+		if c.Username == "" || (c.Password == "" && c.PrivKey == "") {
+			return errors.New("koth check " + c.Name + " needs a username and a password or private key for file probes")
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,140 @@
+package checks
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestKothCheckVerification tests Koth check configuration validation
+func TestKothCheckVerification(t *testing.T) {
+	tests := []struct {
+		name         string
+		check        *Koth
+		expectError  bool
+		errorMsg     string
+		expectedPort int
+	}{
+		{
+			name:         "web probe defaults",
+			check:        &Koth{Method: "web"},
+			expectedPort: 80,
+		},
+		{
+			name:         "https web probe defaults",
+			check:        &Koth{Method: "web", Scheme: "https"},
+			expectedPort: 443,
+		},
+		{
+			name:         "file probe with key",
+			check:        &Koth{Method: "file", Path: "/root/king.txt", Username: "root", PrivKey: "koth.key"},
+			expectedPort: 22,
+		},
+		{
+			name:        "file probe without credentials",
+			check:       &Koth{Method: "file", Path: "/root/king.txt"},
+			expectError: true,
+			errorMsg:    "needs a username",
+		},
+		{
+			name:        "dns probe without domain",
+			check:       &Koth{Method: "dns"},
+			expectError: true,
+			errorMsg:    "needs a domain",
+		},
+		{
+			name:        "banner probe without port",
+			check:       &Koth{Method: "banner"},
+			expectError: true,
+			errorMsg:    "needs a port",
+		},
+		{
+			name:        "unknown method",
+			check:       &Koth{Method: "smoke-signal"},
+			expectError: true,
+			errorMsg:    "invalid method",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.check.Verify("hill", "10.100.0.5", 5, 30, 1, 3)
+			if tt.expectError {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.errorMsg)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, "Koth", tt.check.ServiceType)
+			assert.Equal(t, "hill-koth", tt.check.Name)
+			assert.Equal(t, tt.expectedPort, tt.check.Port)
+		})
+	}
+}
+
+func TestFindTokenOwners(t *testing.T) {
+	tokens := map[uint]string{1: "token-one", 2: "token-two", 3: ""}
+
+	assert.Empty(t, FindTokenOwners("nobody here", tokens))
+	assert.Equal(t, []uint{2}, FindTokenOwners("owned by token-two\n", tokens))
+	assert.Equal(t, []uint{1, 2}, FindTokenOwners("token-two token-one", tokens))
+}
+
+func TestKothRun(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/king.html":
+			w.Write([]byte("<h1>token-two</h1>"))
+		case "/contested.html":
+			w.Write([]byte("token-one token-two"))
+		default:
+			w.Write([]byte("nothing to see"))
+		}
+	}))
+	defer server.Close()
+
+	parts := strings.Split(server.URL[len("http://"):], ":")
+	tokens := map[uint]string{1: "token-one", 2: "token-two"}
+
+	tests := []struct {
+		name          string
+		path          string
+		expectedOwner uint
+		expectedError string
+	}{
+		{name: "single owner", path: "/king.html", expectedOwner: 2},
+		{name: "contested", path: "/contested.html", expectedError: "multiple ownership tokens found"},
+		{name: "unowned", path: "/", expectedError: "no ownership token found"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			check := &Koth{
+				Service: Service{Target: parts[0], Port: mustAtoi(parts[1]), Timeout: 5},
+				Method:  "web",
+				Scheme:  "http",
+				Path:    tt.path,
+				Tokens:  tokens,
+			}
+			resultsChan := make(chan Result, 1)
+			check.Run(context.Background(), 0, "", 1, resultsChan)
+
+			select {
+			case result := <-resultsChan:
+				assert.Equal(t, tt.expectedOwner, result.OwnerID)
+				assert.Equal(t, tt.expectedOwner != 0, result.Status)
+				if tt.expectedError != "" {
+					assert.Contains(t, result.Error, tt.expectedError)
+				}
+			case <-time.After(10 * time.Second):
+				t.Fatal("Check timed out")
+			}
+		})
+	}
+}
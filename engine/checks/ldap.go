@@ -1,8 +1,12 @@
 package checks
 
 import (
+	"context"
+	"crypto/tls"
 	"fmt"
 	"log/slog"
+	"net"
+	"strconv"
 	"strings"
 	"time"
 
@@ -11,15 +15,13 @@ import (
 
 type Ldap struct {
 	Service
+	TlsAssertions
 	Domain    string
 	Encrypted bool
 }
 
-func (c Ldap) Run(teamID uint, teamIdentifier string, roundID uint, resultsChan chan Result) {
+func (c Ldap) Run(ctx context.Context, teamID uint, teamIdentifier string, roundID uint, resultsChan chan Result) {
 	definition := func(teamID uint, teamIdentifier string, checkResult Result, response chan Result) {
-		// Set timeout
-		ldap.DefaultTimeout = time.Duration(c.Timeout) * time.Second
-
 		username, password, err := c.getCreds(teamID)
 		if err != nil {
 			checkResult.Error = "error getting creds"
@@ -28,17 +30,15 @@ func (c Ldap) Run(teamID uint, teamIdentifier string, roundID uint, resultsChan
 			return
 		}
 
-		scheme := "ldap"
-		if c.Encrypted {
-			scheme = "ldaps"
-		}
-		lconn, err := ldap.DialURL(fmt.Sprintf("%s://%s:%d", scheme, c.Target, c.Port))
+		conn, err := c.dial(ctx)
 		if err != nil {
 			checkResult.Error = "failed to connect"
 			checkResult.Debug = "login " + username + " password " + password + " failed with error: " + err.Error()
 			response <- checkResult
 			return
 		}
+		lconn := ldap.NewConn(conn, c.Encrypted)
+		lconn.Start()
 		defer func() {
 		if err := lconn.Close(); err != nil {
 			slog.Error("failed to close ldap connection", "error", err)
@@ -70,7 +70,23 @@ func (c Ldap) Run(teamID uint, teamIdentifier string, roundID uint, resultsChan
 		response <- checkResult
 	}
 
-	c.Service.Run(teamID, teamIdentifier, roundID, resultsChan, definition)
+	c.Service.Run(teamID, teamIdentifier, roundID, resultsChan, c.withTls(ctx, c.Encrypted, c.Port, c.Timeout, definition))
+}
+
+// dial connects to the server, over TLS for ldaps, the way ldap.DialURL does
+// but cancelled with ctx
+func (c Ldap) dial(ctx context.Context) (net.Conn, error) {
+	dialer := contextDialer{ctx: ctx, dialer: net.Dialer{Timeout: time.Duration(c.Timeout) * time.Second}}
+	conn, err := dialer.Dial("tcp", net.JoinHostPort(c.Target, strconv.Itoa(c.Port)))
+	if err != nil || !c.Encrypted {
+		return conn, err
+	}
+	tlsConn := tls.Client(conn, &tls.Config{ServerName: c.Target})
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return tlsConn, nil
 }
 
 func (c *Ldap) Verify(box string, ip string, points int, timeout int, slapenalty int, slathreshold int) error {
@@ -90,5 +106,9 @@ func (c *Ldap) Verify(box string, ip string, points int, timeout int, slapenalty
 		c.Name = box + "-" + c.Display
 	}
 
+	if err := c.verifyTls(c.Name, c.Encrypted); err != nil {
+		return err
+	}
+
 	return nil
 }
@@ -1,6 +1,7 @@
 package checks
 
 import (
+	"context"
 	"fmt"
 	"time"
 
@@ -14,7 +15,7 @@ type Ping struct {
 	Percent         int
 }
 
-func (c Ping) Run(teamID uint, teamIdentifier string, roundID uint, resultsChan chan Result) {
+func (c Ping) Run(ctx context.Context, teamID uint, teamIdentifier string, roundID uint, resultsChan chan Result) {
 	definition := func(teamID uint, teamIdentifier string, checkResult Result, response chan Result) {
 		// Create pinger
 		pinger, err := ping.NewPinger(c.Target)
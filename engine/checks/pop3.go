@@ -1,22 +1,31 @@
 package checks
 
 import (
+	"context"
+	"net"
+	"time"
+
 	"github.com/knadh/go-pop3"
 )
 
 type Pop3 struct {
 	Service
+	TlsAssertions
 	Domain    string
 	Encrypted bool
 }
 
-func (c Pop3) Run(teamID uint, teamIdentifier string, roundID uint, resultsChan chan Result) {
+func (c Pop3) Run(ctx context.Context, teamID uint, teamIdentifier string, roundID uint, resultsChan chan Result) {
 	definition := func(teamID uint, teamIdentifier string, checkResult Result, response chan Result) {
 		// Create a dialer so we can set timeouts
 		p := pop3.New(pop3.Opt{
 			Host:       c.Target,
 			Port:       c.Port,
 			TLSEnabled: c.Encrypted,
+			Dialer: contextDialer{
+				ctx:    ctx,
+				dialer: net.Dialer{Timeout: time.Duration(c.Timeout) * time.Second},
+			},
 		})
 
 		// Create a new connection. POP3 connections are stateful and should end
@@ -68,7 +77,7 @@ func (c Pop3) Run(teamID uint, teamIdentifier string, roundID uint, resultsChan
 		response <- checkResult
 	}
 
-	c.Service.Run(teamID, teamIdentifier, roundID, resultsChan, definition)
+	c.Service.Run(teamID, teamIdentifier, roundID, resultsChan, c.withTls(ctx, c.Encrypted, c.Port, c.Timeout, definition))
 }
 
 func (c *Pop3) Verify(box string, ip string, points int, timeout int, slapenalty int, slathreshold int) error {
@@ -88,5 +97,9 @@ func (c *Pop3) Verify(box string, ip string, points int, timeout int, slapenalty
 		c.Port = 110
 	}
 
+	if err := c.verifyTls(c.Name, c.Encrypted); err != nil {
+		return err
+	}
+
 	return nil
 }
@@ -1,6 +1,7 @@
 package checks
 
 import (
+	"context"
 	"net"
 	"strconv"
 	"time"
@@ -11,7 +12,7 @@ type Rdp struct {
 	Service
 }
 
-func (c Rdp) Run(teamID uint, teamIdentifier string, roundID uint, resultsChan chan Result) {
+func (c Rdp) Run(ctx context.Context, teamID uint, teamIdentifier string, roundID uint, resultsChan chan Result) {
 	definition := func(teamID uint, teamIdentifier string, checkResult Result, response chan Result) {
 		_, err := net.DialTimeout("tcp", c.Target+":"+strconv.Itoa(c.Port), time.Duration(c.Timeout)*time.Second)
 		if err != nil {
@@ -1,6 +1,7 @@
 package checks
 
 import (
+	"context"
 	"fmt"
 	"log/slog"
 	"net"
@@ -134,7 +135,7 @@ func TestWebRun_ActualExecution(t *testing.T) {
 
 			// Run the ACTUAL check
 			resultsChan := make(chan Result, 1)
-			tt.webCheck.Run(1, "01", 1, resultsChan)
+			tt.webCheck.Run(context.Background(), 1, "01", 1, resultsChan)
 
 			// Wait for result with timeout
 			select {
@@ -206,7 +207,7 @@ func TestTcpRun_ActualExecution(t *testing.T) {
 
 			// Run the ACTUAL check
 			resultsChan := make(chan Result, 1)
-			tcpCheck.Run(1, "01", 1, resultsChan)
+			tcpCheck.Run(context.Background(), 1, "01", 1, resultsChan)
 
 			// Wait for result
 			select {
@@ -339,7 +340,7 @@ func TestDnsRun_ActualExecution(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			// Run the ACTUAL check
 			resultsChan := make(chan Result, 1)
-			tt.dnsCheck.Run(1, "01", 1, resultsChan)
+			tt.dnsCheck.Run(context.Background(), 1, "01", 1, resultsChan)
 
 			// Wait for result
 			select {
@@ -411,7 +412,7 @@ func TestCustomRun_ActualExecution(t *testing.T) {
 
 			// Run the ACTUAL check
 			resultsChan := make(chan Result, 1)
-			customCheck.Run(1, "01", 1, resultsChan)
+			customCheck.Run(context.Background(), 1, "01", 1, resultsChan)
 
 			// Wait for result
 			select {
@@ -442,7 +443,7 @@ func TestPingRun_ActualExecution(t *testing.T) {
 
 		// Run the ACTUAL check
 		resultsChan := make(chan Result, 1)
-		pingCheck.Run(1, "01", 1, resultsChan)
+		pingCheck.Run(context.Background(), 1, "01", 1, resultsChan)
 
 		// Wait for result
 		select {
@@ -479,7 +480,7 @@ func TestServiceTimeout(t *testing.T) {
 		}
 
 		resultsChan := make(chan Result, 1)
-		webCheck.Run(1, "01", 1, resultsChan)
+		webCheck.Run(context.Background(), 1, "01", 1, resultsChan)
 
 		// Should timeout and return result
 		select {
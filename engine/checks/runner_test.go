@@ -1,12 +1,15 @@
 package checks
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net"
 	"net/http"
 	"net/http/httptest"
+	"os"
 	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -190,7 +193,7 @@ func TestWebCheckRun(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			resultsChan := make(chan Result, 1)
-			tt.check.Run(1, "01", 1, resultsChan)
+			tt.check.Run(context.Background(), 1, "01", 1, resultsChan)
 
 			select {
 			case result := <-resultsChan:
@@ -205,6 +208,210 @@ func TestWebCheckRun(t *testing.T) {
 	}
 }
 
+// TestWebCheckRunRecordsItem tests that the url a check picked is recorded in its result
+func TestWebCheckRunRecordsItem(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	parts := strings.Split(server.URL[7:], ":")
+	check := &Web{
+		Service: Service{
+			Name:      "web01-web",
+			Target:    parts[0],
+			Port:      mustAtoi(parts[1]),
+			Timeout:   5,
+			Selection: SelectRotate,
+		},
+		Scheme: "http",
+		Url:    []urlData{{Path: "/"}, {Path: "/login"}},
+	}
+
+	for round, want := range []string{"/", "/login", "/"} {
+		resultsChan := make(chan Result, 1)
+		check.Run(context.Background(), 1, "01", uint(round+1), resultsChan)
+		select {
+		case result := <-resultsChan:
+			assert.True(t, result.Status)
+			assert.Equal(t, want, result.Item)
+		case <-time.After(10 * time.Second):
+			t.Fatal("Check timed out")
+		}
+	}
+}
+
+// TestWebCheckRunScenario tests a login scenario with a CSRF token and a session cookie
+func TestWebCheckRunScenario(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /login", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<form><input type="hidden" name="csrf" value="tok3n"></form>`))
+	})
+	mux.HandleFunc("POST /login", func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("csrf") != "tok3n" || r.FormValue("user") != "alice" || r.FormValue("pass") != "s3cret&" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "alice"})
+		http.Redirect(w, r, "/dashboard", http.StatusFound)
+	})
+	mux.HandleFunc("GET /dashboard", func(w http.ResponseWriter, r *http.Request) {
+		cookie, err := r.Cookie("session")
+		if err != nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte("Welcome back, " + cookie.Value))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	parts := strings.Split(server.URL[7:], ":")
+	check := &Web{
+		Service: Service{
+			Target:          parts[0],
+			Port:            mustAtoi(parts[1]),
+			Timeout:         5,
+			Points:          9,
+			CredLists:       []string{"users.credlist"},
+			TaskCredentials: []TaskCredential{{Username: "alice", Password: "s3cret&"}},
+		},
+		Scheme: "http",
+		Step: []webStep{
+			{Path: "/login", Status: 200, Extract: map[string]string{"csrf": `name="csrf" value="([^"]+)"`}},
+			{Method: "post", Path: "/login", Form: map[string]string{"user": "USERNAME", "pass": "PASSWORD", "csrf": "{{csrf}}"}},
+			{Path: "/dashboard", Status: 200, Regex: "Welcome back, USERNAME"},
+		},
+	}
+	require.NoError(t, check.Verify("web01", "127.0.0.1", 5, 5, 1, 3))
+
+	run := func() Result {
+		resultsChan := make(chan Result, 1)
+		check.Run(context.Background(), 1, "01", 1, resultsChan)
+		select {
+		case result := <-resultsChan:
+			return result
+		case <-time.After(10 * time.Second):
+			t.Fatal("Check timed out")
+			return Result{}
+		}
+	}
+
+	result := run()
+	assert.True(t, result.Status, "%s: %s", result.Error, result.Debug)
+
+	// without the CSRF token the login is refused, and the dashboard has no session
+	check.Step[0].Extract = nil
+	check.Step[1].Form["csrf"] = "wrong"
+	check.PartialCredit = true
+	result = run()
+	assert.False(t, result.Status)
+	assert.Equal(t, "step 3 status returned by webserver was incorrect", result.Error)
+	assert.Equal(t, 3, result.Parts)
+	assert.Equal(t, 2, result.PartsPassed)
+	assert.Equal(t, 6, result.EarnedPoints())
+}
+
+// TestWebCheckRunCompareFile tests pages are compared against their original with dynamic parts stripped
+func TestWebCheckRunCompareFile(t *testing.T) {
+	original := "<html>\n<h1>Team Bank</h1>\n<p>Rendered at 10:00:00</p>\n<p>Open an account today</p>\n<footer>Contact us</footer>\n</html>\n"
+	t.Chdir(t.TempDir())
+	require.NoError(t, os.MkdirAll("config/scoredfiles", 0o750))
+	require.NoError(t, os.WriteFile("config/scoredfiles/bank.html", []byte(original), 0o600))
+
+	page := strings.Replace(original, "10:00:00", "11:42:17", 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(page))
+	}))
+	defer server.Close()
+
+	parts := strings.Split(server.URL[7:], ":")
+	check := &Web{
+		Service: Service{
+			Target:  parts[0],
+			Port:    mustAtoi(parts[1]),
+			Timeout: 5,
+		},
+		Scheme: "http",
+		Url:    []urlData{{Path: "/", CompareFile: "bank.html", Strip: []string{`Rendered at [0-9:]+`}}},
+	}
+	client := &http.Client{Timeout: 5 * time.Second}
+
+	ok, errMsg, debug := check.checkUrl(context.Background(), client, check.Url[0])
+	assert.True(t, ok, "%s: %s", errMsg, debug)
+
+	// without stripping the timestamp the page is no longer the same
+	check.Url[0].Strip = nil
+	ok, errMsg, _ = check.checkUrl(context.Background(), client, check.Url[0])
+	assert.False(t, ok)
+	assert.Equal(t, "page differed too much from the original", errMsg)
+
+	// unless some difference is allowed
+	check.Url[0].Diff = 20
+	ok, _, _ = check.checkUrl(context.Background(), client, check.Url[0])
+	assert.True(t, ok)
+
+	// a defaced page still returns 200, but fails
+	page = "<html><h1>hacked by red team</h1></html>"
+	ok, errMsg, _ = check.checkUrl(context.Background(), client, check.Url[0])
+	assert.False(t, ok)
+	assert.Equal(t, "page differed too much from the original", errMsg)
+}
+
+func TestContentDifference(t *testing.T) {
+	assert.Equal(t, 0, ContentDifference("a\nb\nc\nd\n", "a\nb\nc\nd\n"))
+	assert.Equal(t, 25, ContentDifference("a\nb\nc\nd\n", "a\nb\nc\nx\n"))
+	assert.Equal(t, 100, ContentDifference("a\nb\n", "x\ny\n"))
+}
+
+// TestWebCheckRunPartialCredit tests that partial credit checks every url and scores each one
+func TestWebCheckRunPartialCredit(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("Welcome to the competition!"))
+	}))
+	defer server.Close()
+
+	parts := strings.Split(server.URL[7:], ":")
+	check := &Web{
+		Service: Service{
+			Target:  parts[0],
+			Port:    mustAtoi(parts[1]),
+			Timeout: 5,
+			Points:  12,
+		},
+		Scheme:        "http",
+		Url:           []urlData{{Path: "/", Status: 200}, {Path: "/about", Regex: "competition"}, {Path: "/missing", Status: 200}},
+		PartialCredit: true,
+	}
+
+	resultsChan := make(chan Result, 1)
+	check.Run(context.Background(), 1, "01", 1, resultsChan)
+
+	select {
+	case result := <-resultsChan:
+		assert.False(t, result.Status, "a check is only up when every url passes")
+		assert.Equal(t, 3, result.Parts)
+		assert.Equal(t, 2, result.PartsPassed)
+		assert.Equal(t, 8, result.EarnedPoints())
+		assert.Contains(t, result.Error, "status returned by webserver was incorrect")
+	case <-time.After(10 * time.Second):
+		t.Fatal("Check timed out")
+	}
+}
+
+func TestResultEarnedPoints(t *testing.T) {
+	assert.Equal(t, 10, Result{Points: 10, Status: true}.EarnedPoints())
+	assert.Equal(t, 0, Result{Points: 10, Status: false}.EarnedPoints())
+	assert.Equal(t, 5, Result{Points: 10, Parts: 4, PartsPassed: 2}.EarnedPoints())
+	assert.Equal(t, 3, Result{Points: 10, Parts: 3, PartsPassed: 1}.EarnedPoints(), "shares round down")
+	assert.Equal(t, 0, Result{Points: 10, Parts: 3}.EarnedPoints())
+}
+
 // TestDnsCheckVerification tests DNS check configuration validation
 func TestDnsCheckVerification(t *testing.T) {
 	tests := []struct {
@@ -236,6 +443,33 @@ func TestDnsCheckVerification(t *testing.T) {
 			expectError: true,
 			errorMsg:    "has no records",
 		},
+		{
+			name: "unsupported record kind",
+			check: &Dns{
+				Service: Service{
+					Target: "10.100.1_.2",
+				},
+				Record: []DnsRecord{
+					{Kind: "HINFO", Domain: "team_.example.com", Answer: []string{"x86"}},
+				},
+			},
+			expectError: true,
+			errorMsg:    "unsupported record kind HINFO",
+		},
+		{
+			name: "zone transfer without a zone",
+			check: &Dns{
+				Service: Service{
+					Target: "10.100.1_.2",
+				},
+				Record: []DnsRecord{
+					{Kind: "A", Domain: "team_.example.com", Answer: []string{"10.100.1_.2"}},
+				},
+				ZoneTransfer: "refused",
+			},
+			expectError: true,
+			errorMsg:    "has no zone",
+		},
 		{
 			name: "default port",
 			check: &Dns{
@@ -361,7 +595,7 @@ func TestDnsCheckRun(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			resultsChan := make(chan Result, 1)
-			tt.check.Run(1, "01", 1, resultsChan)
+			tt.check.Run(context.Background(), 1, "01", 1, resultsChan)
 
 			select {
 			case result := <-resultsChan:
@@ -373,6 +607,206 @@ func TestDnsCheckRun(t *testing.T) {
 	}
 }
 
+// TestDnsAnswerMatches tests answer matching for every supported record type
+func TestDnsAnswerMatches(t *testing.T) {
+	tests := []struct {
+		record   string
+		expected string
+		matches  bool
+	}{
+		{"www.team01.local. 300 IN A 10.100.1.10", "10.100.1.10", true},
+		{"www.team01.local. 300 IN A 10.100.1.10", "10.100.1.11", false},
+		{"www.team01.local. 300 IN AAAA fd00::10", "fd00:0::10", true},
+		{"mail.team01.local. 300 IN CNAME web01.team01.local.", "WEB01.team01.local", true},
+		{"team01.local. 300 IN MX 10 mail.team01.local.", "mail.team01.local", true},
+		{"team01.local. 300 IN MX 10 mail.team01.local.", "10 mail.team01.local", true},
+		{"team01.local. 300 IN MX 10 mail.team01.local.", "20 mail.team01.local", false},
+		{"team01.local. 300 IN NS ns1.team01.local.", "ns1.team01.local.", true},
+		{`team01.local. 300 IN TXT "v=spf1 " "mx -all"`, "v=spf1 mx -all", true},
+		{"10.1.100.10.in-addr.arpa. 300 IN PTR www.team01.local.", "www.team01.local", true},
+		{"_ldap._tcp.team01.local. 300 IN SRV 0 100 389 dc01.team01.local.", "dc01.team01.local", true},
+		{"_ldap._tcp.team01.local. 300 IN SRV 0 100 389 dc01.team01.local.", "389 dc01.team01.local", true},
+		{"_ldap._tcp.team01.local. 300 IN SRV 0 100 389 dc01.team01.local.", "0 100 389 dc01.team01.local", true},
+		{"_ldap._tcp.team01.local. 300 IN SRV 0 100 389 dc01.team01.local.", "636 dc01.team01.local", false},
+		{"team01.local. 300 IN SOA ns1.team01.local. admin.team01.local. 2024010105 3600 600 86400 300", "2024010105", true},
+		{"team01.local. 300 IN SOA ns1.team01.local. admin.team01.local. 2024010105 3600 600 86400 300", ">=2024010101", true},
+		{"team01.local. 300 IN SOA ns1.team01.local. admin.team01.local. 2024010105 3600 600 86400 300", ">= 2024010110", false},
+	}
+
+	for _, tt := range tests {
+		rr, err := dns.NewRR(tt.record)
+		require.NoError(t, err)
+		assert.Equal(t, tt.matches, dnsAnswerMatches(rr, tt.expected), "%s against %q", tt.record, tt.expected)
+	}
+}
+
+// TestDnsCheckRunRecordTypes tests queries for records other than A, including PTR lookups by address
+func TestDnsCheckRunRecordTypes(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Skip("Cannot create UDP listener for DNS test")
+		return
+	}
+	zone := map[uint16][]string{
+		dns.TypeSRV:   {"_ldap._tcp.team01.local. 300 IN SRV 0 100 389 dc01.team01.local."},
+		dns.TypePTR:   {"10.1.100.10.in-addr.arpa. 300 IN PTR www.team01.local."},
+		dns.TypeAAAA:  {"www.team01.local. 300 IN CNAME web01.team01.local.", "web01.team01.local. 300 IN AAAA fd00::10"},
+		dns.TypeCNAME: {"www.team01.local. 300 IN CNAME web01.team01.local."},
+	}
+	server := &dns.Server{PacketConn: pc, Handler: dns.HandlerFunc(func(w dns.ResponseWriter, req *dns.Msg) {
+		resp := new(dns.Msg)
+		resp.SetReply(req)
+		for _, record := range zone[req.Question[0].Qtype] {
+			rr, _ := dns.NewRR(record)
+			resp.Answer = append(resp.Answer, rr)
+		}
+		w.WriteMsg(resp)
+	})}
+	go server.ActivateAndServe()
+	defer server.Shutdown()
+
+	port := pc.LocalAddr().(*net.UDPAddr).Port
+	records := []DnsRecord{
+		{Kind: "SRV", Domain: "_ldap._tcp.team_.local", Answer: []string{"389 dc01.team_.local"}},
+		{Kind: "PTR", Domain: "10.100.1.10", Answer: []string{"www.team_.local"}},
+		{Kind: "AAAA", Domain: "www.team_.local", Answer: []string{"fd00::10"}},
+		{Kind: "CNAME", Domain: "www.team_.local", Answer: []string{"web01.team_.local"}},
+		{Kind: "aaaa", Domain: "www.team_.local", Answer: []string{"fd00::11"}},
+	}
+	check := &Dns{
+		Service: Service{
+			Target:  "127.0.0.1",
+			Port:    port,
+			Timeout: 5,
+		},
+	}
+
+	for i, want := range []bool{true, true, true, true, false} {
+		ok, errMsg, debug := check.checkRecord(context.Background(), records[i], "01")
+		assert.Equal(t, want, ok, "%s %s: %s %s", records[i].Kind, records[i].Domain, errMsg, debug)
+	}
+}
+
+// TestDnsCheckAssertions tests zone transfer, recursion and DNSSEC assertions over TCP
+func TestDnsCheckAssertions(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skip("Cannot create TCP listener for DNS test")
+		return
+	}
+	var allowTransfer, openRecursion atomic.Bool
+	mustRR := func(record string) dns.RR {
+		rr, err := dns.NewRR(record)
+		require.NoError(t, err)
+		return rr
+	}
+	soa := mustRR("team01.local. 300 IN SOA ns1.team01.local. admin.team01.local. 1 3600 600 86400 300")
+	www := mustRR("www.team01.local. 300 IN A 10.100.1.10")
+	sig := mustRR("www.team01.local. 300 IN RRSIG A 13 3 300 20300101000000 20200101000000 12345 team01.local. c2lnbmF0dXJl")
+	server := &dns.Server{Listener: listener, Handler: dns.HandlerFunc(func(w dns.ResponseWriter, req *dns.Msg) {
+		resp := new(dns.Msg)
+		resp.SetReply(req)
+		q := req.Question[0]
+		switch {
+		case q.Qtype == dns.TypeAXFR && allowTransfer.Load():
+			resp.Answer = []dns.RR{soa, www, soa}
+		case q.Qtype == dns.TypeAXFR:
+			resp.Rcode = dns.RcodeRefused
+		case q.Name == "www.team01.local.":
+			resp.Answer = []dns.RR{www}
+			if opt := req.IsEdns0(); opt != nil && opt.Do() {
+				resp.Answer = append(resp.Answer, sig)
+			}
+		case openRecursion.Load():
+			resp.Answer = []dns.RR{mustRR(q.Name + " 300 IN A 93.184.216.34")}
+		default:
+			resp.Rcode = dns.RcodeRefused
+		}
+		w.WriteMsg(resp)
+	})}
+	go server.ActivateAndServe()
+	defer server.Shutdown()
+
+	check := &Dns{
+		Service: Service{
+			Target:  "127.0.0.1",
+			Port:    listener.Addr().(*net.TCPAddr).Port,
+			Timeout: 5,
+		},
+		Record:       []DnsRecord{{Kind: "A", Domain: "www.team_.local", Answer: []string{"10.100.1.10"}, Dnssec: true}},
+		UseTcp:       true,
+		ZoneTransfer: "refused",
+		Zone:         "team_.local",
+		NoRecursion:  true,
+	}
+	require.NoError(t, check.Verify("dns01", "127.0.0.1", 5, 5, 1, 3))
+
+	run := func() Result {
+		resultsChan := make(chan Result, 1)
+		check.Run(context.Background(), 1, "01", 1, resultsChan)
+		select {
+		case result := <-resultsChan:
+			return result
+		case <-time.After(10 * time.Second):
+			t.Fatal("Check timed out")
+			return Result{}
+		}
+	}
+
+	result := run()
+	assert.True(t, result.Status, "%s: %s", result.Error, result.Debug)
+
+	allowTransfer.Store(true)
+	result = run()
+	assert.False(t, result.Status)
+	assert.Equal(t, "zone transfer was allowed", result.Error)
+
+	check.ZoneTransfer = "allowed"
+	openRecursion.Store(true)
+	result = run()
+	assert.False(t, result.Status)
+	assert.Equal(t, "server allows open recursion", result.Error)
+
+	// partial credit scores the record and each assertion as a part
+	check.PartialCredit = true
+	result = run()
+	assert.Equal(t, 3, result.Parts)
+	assert.Equal(t, 2, result.PartsPassed)
+
+	// an answer only counts as signed with an RRSIG over its own type
+	assert.True(t, signed([]dns.RR{www, sig}, dns.TypeA))
+	assert.False(t, signed([]dns.RR{www, sig}, dns.TypeAAAA))
+}
+
+// echoShell answers every command written to it with its output, from another
+// goroutine like an ssh session does
+type echoShell struct {
+	stdout *lockedBuffer
+}
+
+func (e echoShell) Write(p []byte) (int, error) {
+	command := strings.TrimSpace(string(p))
+	go func() {
+		for range 50 {
+			e.stdout.Write([]byte("."))
+		}
+		e.stdout.Write([]byte("output of " + command + "\n"))
+	}()
+	return len(p), nil
+}
+
+// TestSshRunCommandReadsOwnOutput tests each command is checked against only its own output
+func TestSshRunCommandReadsOwnOutput(t *testing.T) {
+	var stdout, stderr lockedBuffer
+	shell := echoShell{stdout: &stdout}
+	check := Ssh{}
+
+	ok, errMsg, _ := check.runCommand(context.Background(), shell, &stdout, &stderr, commandData{Command: "whoami", Output: "output of whoami", Contains: true}, 50*time.Millisecond)
+	assert.True(t, ok, errMsg)
+	ok, _, _ = check.runCommand(context.Background(), shell, &stdout, &stderr, commandData{Command: "hostname", Output: "output of whoami", Contains: true}, 50*time.Millisecond)
+	assert.False(t, ok, "output of an earlier command doesn't count")
+}
+
 // TestSshCheckVerification tests SSH check configuration validation
 func TestSshCheckVerification(t *testing.T) {
 	tests := []struct {
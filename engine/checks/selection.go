@@ -0,0 +1,47 @@
+package checks
+
+import (
+	"hash/fnv"
+	"math/rand"
+	"strconv"
+)
+
+// Selection modes decide which of a check's urls, records, commands, queries or
+// files it tests in a round
+const (
+	// SelectRandom picks an item at random for every team
+	SelectRandom = "random"
+	// SelectRound picks an item at random per round, the same one for every team
+	SelectRound = "round"
+	// SelectRotate goes through the items in order, one per run of the check
+	SelectRotate = "rotate"
+)
+
+var SelectionModes = []string{SelectRandom, SelectRound, SelectRotate}
+
+// SetSelectionDefault fills in the selection mode when the check does not set its own
+func (service *Service) SetSelectionDefault(mode string) {
+	if service.Selection == "" {
+		service.Selection = mode
+	}
+}
+
+// pick returns the index of the item out of n the check tests in a round
+func (service *Service) pick(roundID uint, n int) int {
+	switch service.Selection {
+	case SelectRound:
+		// seeded by the check too, so checks of the same length don't move in lockstep
+		h := fnv.New64a()
+		h.Write([]byte(service.Name + "/" + strconv.FormatUint(uint64(roundID), 10)))
+		return int(h.Sum64() % uint64(n))
+	case SelectRotate:
+		// checks that skip rounds step on their own runs, so every item gets its turn
+		run := uint64(service.TaskRun)
+		if run == 0 {
+			run = uint64(roundID)
+		}
+		return int((run + uint64(n) - 1) % uint64(n))
+	default:
+		return rand.Intn(n) // #nosec G404 -- non-crypto selection of item to test
+	}
+}
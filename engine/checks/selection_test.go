@@ -0,0 +1,48 @@
+package checks
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPickRound(t *testing.T) {
+	web := Service{Name: "web01-web", Selection: SelectRound}
+
+	// every team's copy of the check picks the same item in a round
+	picks := make(map[int]bool)
+	for round := uint(1); round <= 50; round++ {
+		teamA, teamB := web, web
+		assert.Equal(t, teamA.pick(round, 4), teamB.pick(round, 4), "round %d", round)
+		picks[web.pick(round, 4)] = true
+	}
+	assert.Len(t, picks, 4, "every item is picked across rounds")
+}
+
+func TestPickRotate(t *testing.T) {
+	web := Service{Name: "web01-web", Selection: SelectRotate}
+
+	var picks []int
+	for round := uint(1); round <= 7; round++ {
+		picks = append(picks, web.pick(round, 3))
+	}
+	assert.Equal(t, []int{0, 1, 2, 0, 1, 2, 0}, picks)
+
+	// a check running every third round still reaches every item
+	web.EveryRounds = 3
+	picks = nil
+	for round := uint(1); round <= 7; round += 3 {
+		web.SetTaskRun(round/3 + 1)
+		picks = append(picks, web.pick(round, 3))
+	}
+	assert.Equal(t, []int{0, 1, 2}, picks)
+}
+
+func TestPickRandom(t *testing.T) {
+	web := Service{Name: "web01-web", Selection: SelectRandom}
+	for round := uint(1); round <= 20; round++ {
+		pick := web.pick(round, 3)
+		assert.GreaterOrEqual(t, pick, 0)
+		assert.Less(t, pick, 3)
+	}
+}
@@ -0,0 +1,65 @@
+package checks
+
+// SLA policies decide when failures of a check become an SLA violation
+const (
+	// SlaConsecutive is a violation after Threshold failures in a row
+	SlaConsecutive = "consecutive"
+	// SlaRolling is a violation after Threshold failures within the last Window rounds
+	SlaRolling = "rolling"
+	// SlaEscalating counts like SlaConsecutive, but every repeat violation costs
+	// Penalty more than the one before
+	SlaEscalating = "escalating"
+)
+
+// SlaRule is how failures of one check turn into SLA penalties
+type SlaRule struct {
+	Policy    string
+	Threshold int
+	Window    int // rounds looked back on by the rolling policy
+	Penalty   int
+}
+
+// SlaState is the progress of one team's check toward its next SLA violation
+type SlaState struct {
+	Streak     int    // failures counted toward the next violation
+	Recent     []bool // failures over the last Window rounds, oldest first, for the rolling policy
+	Violations int    // violations so far
+}
+
+// Record counts a round of the check and returns the penalty of the violation
+// it caused, 0 if it caused none. The state is reset after every violation, so
+// the next one needs a whole new set of failures.
+func (rule SlaRule) Record(state *SlaState, passed bool) int {
+	threshold := max(rule.Threshold, 1)
+
+	switch rule.Policy {
+	case SlaRolling:
+		state.Recent = append(state.Recent, !passed)
+		if over := len(state.Recent) - max(rule.Window, threshold); over > 0 {
+			state.Recent = state.Recent[over:]
+		}
+		state.Streak = 0
+		for _, failed := range state.Recent {
+			if failed {
+				state.Streak++
+			}
+		}
+	default:
+		if passed {
+			state.Streak = 0
+		} else {
+			state.Streak++
+		}
+	}
+	if state.Streak < threshold {
+		return 0
+	}
+
+	state.Streak = 0
+	state.Recent = nil
+	state.Violations++
+	if rule.Policy == SlaEscalating {
+		return rule.Penalty * state.Violations
+	}
+	return rule.Penalty
+}
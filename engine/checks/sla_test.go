@@ -0,0 +1,76 @@
+package checks
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"pgregory.net/rapid"
+)
+
+// record feeds a sequence of rounds through a rule and returns the penalty of each
+func record(rule SlaRule, state *SlaState, rounds ...bool) []int {
+	penalties := make([]int, 0, len(rounds))
+	for _, passed := range rounds {
+		penalties = append(penalties, rule.Record(state, passed))
+	}
+	return penalties
+}
+
+func TestSlaConsecutive(t *testing.T) {
+	rule := SlaRule{Policy: SlaConsecutive, Threshold: 3, Penalty: 10}
+	var state SlaState
+
+	assert.Equal(t, []int{0, 0, 0, 0, 0, 10, 0}, record(rule, &state, false, false, true, false, false, false, false))
+	assert.Equal(t, 1, state.Streak)
+	assert.Equal(t, 1, state.Violations)
+}
+
+func TestSlaRolling(t *testing.T) {
+	rule := SlaRule{Policy: SlaRolling, Threshold: 3, Window: 5, Penalty: 10}
+	var state SlaState
+
+	// failures do not need to be in a row, only within the window
+	assert.Equal(t, []int{0, 0, 0, 0, 10}, record(rule, &state, false, true, false, true, false))
+	assert.Equal(t, 0, state.Streak)
+
+	// failures that fell out of the window no longer count
+	assert.Equal(t, []int{0, 0, 0, 0, 0, 0, 0}, record(rule, &state, false, true, true, true, true, false, true))
+	assert.Equal(t, 1, state.Streak)
+	assert.Len(t, state.Recent, 5)
+}
+
+func TestSlaEscalating(t *testing.T) {
+	rule := SlaRule{Policy: SlaEscalating, Threshold: 2, Penalty: 10}
+	var state SlaState
+
+	assert.Equal(t, []int{0, 10, 0, 20, 0, 0, 30}, record(rule, &state, false, false, false, false, true, false, false))
+	assert.Equal(t, 3, state.Violations)
+}
+
+// TestPropertySlaReplay verifies that replaying the same rounds always reaches the
+// same state, which restart recovery relies on
+func TestPropertySlaReplay(t *testing.T) {
+	rapid.Check(t, func(t *rapid.T) {
+		rule := SlaRule{
+			Policy:    rapid.SampledFrom([]string{SlaConsecutive, SlaRolling, SlaEscalating}).Draw(t, "policy"),
+			Threshold: rapid.IntRange(1, 5).Draw(t, "threshold"),
+			Penalty:   rapid.IntRange(1, 50).Draw(t, "penalty"),
+		}
+		rule.Window = rule.Threshold + rapid.IntRange(0, 5).Draw(t, "extraWindow")
+		rounds := rapid.SliceOf(rapid.Bool()).Draw(t, "rounds")
+
+		var live, replayed SlaState
+		penalties := record(rule, &live, rounds...)
+		record(rule, &replayed, rounds...)
+		assert.Equal(t, live, replayed)
+
+		violations := 0
+		for _, penalty := range penalties {
+			if penalty > 0 {
+				violations++
+			}
+		}
+		assert.Equal(t, violations, live.Violations)
+		assert.Less(t, live.Streak, rule.Threshold, "the state resets after every violation")
+	})
+}
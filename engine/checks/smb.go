@@ -1,12 +1,13 @@
 package checks
 
 import (
+	"context"
 	"io"
 	"log/slog"
-	"math/rand"
 	"net"
 	"regexp"
 	"strconv"
+	"time"
 
 	"github.com/hirochachacha/go-smb2"
 )
@@ -24,7 +25,7 @@ type smbFile struct {
 	Regex string
 }
 
-func (c Smb) Run(teamID uint, teamIdentifier string, roundID uint, resultsChan chan Result) {
+func (c Smb) Run(ctx context.Context, teamID uint, teamIdentifier string, roundID uint, resultsChan chan Result) {
 	definition := func(teamID uint, teamIdentifier string, checkResult Result, response chan Result) {
 		var username, password string
 		if len(c.CredLists) == 0 {
@@ -40,7 +41,7 @@ func (c Smb) Run(teamID uint, teamIdentifier string, roundID uint, resultsChan c
 			}
 		}
 
-		conn, err := net.Dial("tcp", c.Target+":"+strconv.Itoa(c.Port))
+		conn, err := (&net.Dialer{Timeout: time.Duration(c.Timeout) * time.Second}).DialContext(ctx, "tcp", c.Target+":"+strconv.Itoa(c.Port))
 		if err != nil {
 			checkResult.Error = "smb connection failed"
 			checkResult.Debug = err.Error()
@@ -60,7 +61,7 @@ func (c Smb) Run(teamID uint, teamIdentifier string, roundID uint, resultsChan c
 			},
 		}
 
-		s, err := d.Dial(conn)
+		s, err := d.DialContext(ctx, conn)
 		if err != nil {
 			checkResult.Error = "smb login failed"
 			if len(c.CredLists) == 0 {
@@ -74,7 +75,7 @@ func (c Smb) Run(teamID uint, teamIdentifier string, roundID uint, resultsChan c
 		defer s.Logoff()
 
 		if len(c.File) > 0 {
-			fs, err := s.Mount(c.Share)
+			fs, err := s.WithContext(ctx).Mount(c.Share)
 			if err != nil {
 				checkResult.Error = "failed to mount share"
 				checkResult.Debug = "share " + c.Share + ", creds " + username + ":" + password
@@ -83,7 +84,8 @@ func (c Smb) Run(teamID uint, teamIdentifier string, roundID uint, resultsChan c
 			}
 			defer fs.Umount()
 
-			file := c.File[rand.Intn(len(c.File))] // #nosec G404 -- non-crypto selection of file to test
+			file := c.File[c.pick(roundID, len(c.File))]
+			checkResult.Item = file.Name
 
 			f, err := fs.Open(file.Name)
 			if err != nil {
@@ -22,6 +22,7 @@ func generateRandomContent() (subject string, body string) {
 
 type Smtp struct {
 	Service
+	TlsAssertions
 	Encrypted   bool
 	Domain      string
 	RequireAuth bool
@@ -38,7 +39,7 @@ func (a unencryptedAuth) Start(server *smtp.ServerInfo) (string, []byte, error)
 	return a.Auth.Start(&s)
 }
 
-func (c Smtp) Run(teamID uint, teamIdentifier string, roundID uint, resultsChan chan Result) {
+func (c Smtp) Run(ctx context.Context, teamID uint, teamIdentifier string, roundID uint, resultsChan chan Result) {
 	definition := func(teamID uint, teamIdentifier string, checkResult Result, response chan Result) {
 		// Create a dialer
 		dialer := net.Dialer{
@@ -86,7 +87,7 @@ func (c Smtp) Run(teamID uint, teamIdentifier string, roundID uint, resultsChan
 		if c.Encrypted {
 			conn, err = tls.DialWithDialer(&dialer, "tcp", fmt.Sprintf("%s:%d", c.Target, c.Port), &tlsConfig)
 		} else {
-			conn, err = dialer.DialContext(context.TODO(), "tcp", fmt.Sprintf("%s:%d", c.Target, c.Port))
+			conn, err = dialer.DialContext(ctx, "tcp", fmt.Sprintf("%s:%d", c.Target, c.Port))
 		}
 		if err != nil {
 			checkResult.Error = "connection to server failed"
@@ -173,7 +174,7 @@ func (c Smtp) Run(teamID uint, teamIdentifier string, roundID uint, resultsChan
 		response <- checkResult
 	}
 
-	c.Service.Run(teamID, teamIdentifier, roundID, resultsChan, definition)
+	c.Service.Run(teamID, teamIdentifier, roundID, resultsChan, c.withTls(ctx, c.Encrypted, c.Port, c.Timeout, definition))
 }
 
 func (c *Smtp) Verify(box string, ip string, points int, timeout int, slapenalty int, slathreshold int) error {
@@ -193,5 +194,9 @@ func (c *Smtp) Verify(box string, ip string, points int, timeout int, slapenalty
 		c.Port = 25
 	}
 
+	if err := c.verifyTls(c.Name, c.Encrypted); err != nil {
+		return err
+	}
+
 	return nil
 }
@@ -5,7 +5,6 @@ import (
 	"database/sql"
 	"fmt"
 	"log/slog"
-	"math/rand"
 	"regexp"
 	"strings"
 
@@ -25,7 +24,7 @@ type queryData struct {
 	Output   string `toml:",omitempty"`
 }
 
-func (c Sql) Run(teamID uint, teamIdentifier string, roundID uint, resultsChan chan Result) {
+func (c Sql) Run(ctx context.Context, teamID uint, teamIdentifier string, roundID uint, resultsChan chan Result) {
 	definition := func(teamID uint, teamIdentifier string, checkResult Result, response chan Result) {
 		username, password, err := c.getCreds(teamID)
 		if err != nil {
@@ -35,11 +34,12 @@ func (c Sql) Run(teamID uint, teamIdentifier string, roundID uint, resultsChan c
 			return
 		}
 
-		// Select a random query
+		// Select a query
 		// If no queries defined, just use empty query
 		var q queryData
 		if len(c.Query) != 0 {
-			q = c.Query[rand.Intn(len(c.Query))] // #nosec G404 -- non-crypto selection of query to test
+			q = c.Query[c.pick(roundID, len(c.Query))]
+			checkResult.Item = q.Command
 		}
 
 		// Open the DB handle
@@ -57,7 +57,7 @@ func (c Sql) Run(teamID uint, teamIdentifier string, roundID uint, resultsChan c
 	}()
 
 		// Check DB connection
-		err = db.PingContext(context.TODO())
+		err = db.PingContext(ctx)
 		if err != nil {
 			checkResult.Error = "db connection or login failed"
 			checkResult.Debug = err.Error()
@@ -75,7 +75,7 @@ func (c Sql) Run(teamID uint, teamIdentifier string, roundID uint, resultsChan c
 
 		// Query the DB
 		var rows *sql.Rows
-		rows, err = db.QueryContext(context.TODO(), q.Command)
+		rows, err = db.QueryContext(ctx, q.Command)
 		if err != nil {
 			checkResult.Error = "could not query db with command " + q.Command
 			checkResult.Debug = err.Error()
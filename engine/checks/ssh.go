@@ -2,14 +2,17 @@ package checks
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
+	"io"
 	"log/slog"
-	"math/rand"
+	"net"
 	"os"
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
@@ -21,6 +24,9 @@ type Ssh struct {
 	PrivKey     string `toml:",omitempty"`
 	BadAttempts int    `toml:",omitzero"`
 	Command     []commandData
+	// PartialCredit runs every command each round and awards a share of the
+	// points for each one that passes, instead of running one at random
+	PartialCredit bool `toml:",omitempty"`
 }
 
 type commandData struct {
@@ -30,7 +36,7 @@ type commandData struct {
 	Output   string `toml:",omitempty"`
 }
 
-func (c Ssh) Run(teamID uint, teamIdentifier string, roundID uint, resultsChan chan Result) {
+func (c Ssh) Run(ctx context.Context, teamID uint, teamIdentifier string, roundID uint, resultsChan chan Result) {
 	definition := func(teamID uint, teamIdentifier string, checkResult Result, response chan Result) {
 
 		// Create client config
@@ -83,7 +89,7 @@ func (c Ssh) Run(teamID uint, teamIdentifier string, roundID uint, resultsChan c
 				Timeout:         time.Duration(c.Timeout) * time.Second,
 			}
 
-			badConn, err := ssh.Dial("tcp", c.Target+":"+strconv.Itoa(c.Port), badConf)
+			badConn, err := c.dial(ctx, badConf)
 			if err == nil {
 				if err := badConn.Close(); err != nil {
 					slog.Error("failed to close bad ssh connection", "error", err)
@@ -92,7 +98,7 @@ func (c Ssh) Run(teamID uint, teamIdentifier string, roundID uint, resultsChan c
 		}
 
 		// Connect to ssh server
-		conn, err := ssh.Dial("tcp", c.Target+":"+strconv.Itoa(c.Port), config)
+		conn, err := c.dial(ctx, config)
 		if err != nil {
 			if c.PrivKey != "" {
 				checkResult.Error = "error logging in to ssh server with private key " + c.PrivKey
@@ -144,8 +150,8 @@ func (c Ssh) Run(teamID uint, teamIdentifier string, roundID uint, resultsChan c
 			return
 		}
 
-		var stdoutBytes bytes.Buffer
-		var stderrBytes bytes.Buffer
+		// the session writes output from its own goroutines while commands are checked
+		var stdoutBytes, stderrBytes lockedBuffer
 		session.Stdout = &stdoutBytes
 		session.Stderr = &stderrBytes
 
@@ -157,34 +163,45 @@ func (c Ssh) Run(teamID uint, teamIdentifier string, roundID uint, resultsChan c
 			return
 		}
 
-		// If any commands specified, run a random one
-		if len(c.Command) > 0 {
-			r := c.Command[rand.Intn(len(c.Command))] // #nosec G404 -- non-crypto selection of command to test
-			fmt.Fprintln(stdin, r.Command)
-			time.Sleep(time.Duration(int(time.Duration(c.Timeout)*time.Second) / 8)) // command wait time
-			if r.Contains {
-				if !strings.Contains(stdoutBytes.String(), r.Output) {
-					checkResult.Error = "command output didn't contain string"
-					checkResult.Debug = "command output of '" + r.Command + "' didn't contain string '" + r.Output + "': " + stdoutBytes.String() + ",  " + stderrBytes.String()
-					response <- checkResult
-					return
-				}
-			} else if r.UseRegex {
-				re := regexp.MustCompile(r.Output)
-				if !re.Match(stdoutBytes.Bytes()) {
-					checkResult.Error = "command output didn't match regex"
-					checkResult.Debug = "command output'" + r.Command + "' didn't match regex '" + r.Output
-					response <- checkResult
-					return
-				}
-			} else {
-				if stderrBytes.Len() != 0 {
-					checkResult.Error = "command returned an error"
-					checkResult.Debug = "command stderr was not empty: " + stderrBytes.String()
-					response <- checkResult
-					return
+		// Every command gets the same time to run, so that all of them fit in the timeout
+		commands := 1
+		if c.PartialCredit {
+			commands = len(c.Command)
+		}
+		wait := time.Duration(c.Timeout) * time.Second / time.Duration(max(8, commands+1))
+
+		// If any commands specified, run one, or all of them for partial credit
+		if len(c.Command) > 0 && !c.PartialCredit {
+			r := c.Command[c.pick(roundID, len(c.Command))]
+			checkResult.Item = r.Command
+			if ok, errMsg, debug := c.runCommand(ctx, stdin, &stdoutBytes, &stderrBytes, r, wait); !ok {
+				checkResult.Error = errMsg
+				checkResult.Debug = debug
+				response <- checkResult
+				return
+			}
+		} else if len(c.Command) > 0 {
+			// every command is worth an equal share of the points
+			var debug []string
+			for _, r := range c.Command {
+				ok, errMsg, commandDebug := c.runCommand(ctx, stdin, &stdoutBytes, &stderrBytes, r, wait)
+				if ok {
+					checkResult.PartsPassed++
+				} else {
+					if checkResult.Error == "" {
+						checkResult.Error = errMsg
+					}
+					debug = append(debug, commandDebug)
 				}
 			}
+			checkResult.Parts = len(c.Command)
+			checkResult.Status = checkResult.PartsPassed == checkResult.Parts
+			checkResult.Debug = fmt.Sprintf("%d of %d commands passed with creds %s:%s", checkResult.PartsPassed, checkResult.Parts, username, password)
+			if len(debug) > 0 {
+				checkResult.Debug += ": " + strings.Join(debug, "; ")
+			}
+			response <- checkResult
+			return
 		}
 		checkResult.Status = true
 		checkResult.Points = c.Points
@@ -195,6 +212,78 @@ func (c Ssh) Run(teamID uint, teamIdentifier string, roundID uint, resultsChan c
 	c.Service.Run(teamID, teamIdentifier, roundID, resultsChan, definition)
 }
 
+// lockedBuffer is a bytes.Buffer safe to read while an ssh session writes to it
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) Len() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Len()
+}
+
+// From returns a copy of everything written from offset on
+func (b *lockedBuffer) From(offset int) []byte {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return bytes.Clone(b.buf.Bytes()[offset:])
+}
+
+// dial connects to the ssh server like ssh.Dial, closing the connection once ctx
+// is cancelled
+func (c Ssh) dial(ctx context.Context, config *ssh.ClientConfig) (*ssh.Client, error) {
+	address := c.Target + ":" + strconv.Itoa(c.Port)
+	conn, err := contextDialer{ctx: ctx, dialer: net.Dialer{Timeout: config.Timeout}}.Dial("tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	clientConn, chans, reqs, err := ssh.NewClientConn(conn, address, config)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return ssh.NewClient(clientConn, chans, reqs), nil
+}
+
+// runCommand sends one command to the shell and checks the output it produced,
+// returning whether it passed along with the error and debug output to report
+func (c Ssh) runCommand(ctx context.Context, stdin io.Writer, stdoutBytes, stderrBytes *lockedBuffer, r commandData, wait time.Duration) (bool, string, string) {
+	// only look at the output written after this command was sent
+	stdoutStart, stderrStart := stdoutBytes.Len(), stderrBytes.Len()
+	fmt.Fprintln(stdin, r.Command)
+	// command wait time
+	select {
+	case <-time.After(wait):
+	case <-ctx.Done():
+	}
+	stdout := stdoutBytes.From(stdoutStart)
+	stderr := stderrBytes.From(stderrStart)
+
+	if r.Contains {
+		if !strings.Contains(string(stdout), r.Output) {
+			return false, "command output didn't contain string", "command output of '" + r.Command + "' didn't contain string '" + r.Output + "': " + string(stdout) + ",  " + string(stderr)
+		}
+	} else if r.UseRegex {
+		re := regexp.MustCompile(r.Output)
+		if !re.Match(stdout) {
+			return false, "command output didn't match regex", "command output'" + r.Command + "' didn't match regex '" + r.Output
+		}
+	} else {
+		if len(stderr) != 0 {
+			return false, "command returned an error", "command stderr was not empty: " + string(stderr)
+		}
+	}
+	return true, "", ""
+}
+
 func (c *Ssh) Verify(box string, ip string, points int, timeout int, slapenalty int, slathreshold int) error {
 	if c.ServiceType == "" {
 		c.ServiceType = "Ssh"
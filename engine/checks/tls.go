@@ -0,0 +1,209 @@
+package checks
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TlsAssertions are optional checks on the certificate and TLS settings of a
+// check's encrypted connection, made in a separate handshake once the check
+// itself has run. Any of them also requires the certificate to be within its
+// validity period.
+type TlsAssertions struct {
+	CertMinDays         int      `toml:",omitempty"` // CertMinDays is how many more days the certificate must stay valid
+	CertHostname        string   `toml:",omitempty"` // CertHostname must be covered by the certificate's SANs (ex. www.team_.local)
+	CertCA              string   `toml:",omitempty"` // CertCA is a CA file in config/certs the certificate chain must validate against
+	TlsMinVersion       string   `toml:",omitempty"` // TlsMinVersion is the oldest TLS version the server may accept (ex. 1.2)
+	TlsForbiddenCiphers []string `toml:",omitempty"` // TlsForbiddenCiphers are cipher suites the server must refuse (ex. TLS_RSA_WITH_RC4_128_SHA)
+}
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+func tlsCipherSuite(name string) (uint16, bool) {
+	for _, suite := range append(tls.CipherSuites(), tls.InsecureCipherSuites()...) {
+		if suite.Name == name {
+			return suite.ID, true
+		}
+	}
+	return 0, false
+}
+
+func (a TlsAssertions) set() bool {
+	return a.CertMinDays != 0 || a.CertHostname != "" || a.CertCA != "" || a.TlsMinVersion != "" || len(a.TlsForbiddenCiphers) > 0
+}
+
+// verifyTls checks the assertions of a check can be made, encrypted being whether
+// the check connects over TLS
+func (a TlsAssertions) verifyTls(name string, encrypted bool) error {
+	if !a.set() {
+		return nil
+	}
+	if !encrypted {
+		return errors.New("check " + name + " makes TLS assertions but does not connect over TLS")
+	}
+	if a.CertMinDays < 0 {
+		return errors.New("check " + name + " certmindays can't be negative")
+	}
+	if _, ok := tlsVersions[a.TlsMinVersion]; a.TlsMinVersion != "" && !ok {
+		return errors.New("check " + name + " tlsminversion must be one of 1.0, 1.1, 1.2 or 1.3")
+	}
+	for _, cipher := range a.TlsForbiddenCiphers {
+		if _, ok := tlsCipherSuite(cipher); !ok {
+			return errors.New("check " + name + " forbids unknown cipher suite " + cipher)
+		}
+	}
+	return nil
+}
+
+// withTls wraps the definition of a check to make its TLS assertions after it
+// runs, in whatever is left of the check's timeout. A failed assertion fails a
+// check that otherwise passed; with partial credit the assertions are one more
+// part of the check.
+func (a TlsAssertions) withTls(ctx context.Context, encrypted bool, port int, timeout int, definition func(teamID uint, teamIdentifier string, checkResult Result, response chan Result)) func(teamID uint, teamIdentifier string, checkResult Result, response chan Result) {
+	if !encrypted || !a.set() {
+		return definition
+	}
+	return func(teamID uint, teamIdentifier string, checkResult Result, response chan Result) {
+		ctx, cancel := context.WithTimeout(ctx, time.Duration(timeout)*time.Second)
+		defer cancel()
+
+		inner := make(chan Result, 1)
+		definition(teamID, teamIdentifier, checkResult, inner)
+		result := <-inner
+
+		if !result.Status && result.Parts == 0 {
+			response <- result
+			return
+		}
+		ok, errMsg, debug := a.checkTls(ctx, result.Target, port, teamIdentifier)
+		if result.Parts > 0 {
+			result.Parts++
+			if ok {
+				result.PartsPassed++
+			}
+		}
+		if !ok {
+			result.Status = false
+			if result.Error == "" {
+				result.Error = errMsg
+			}
+		}
+		result.Debug += "; " + debug
+		response <- result
+	}
+}
+
+// checkTls makes the assertions against the server, returning whether they held
+// along with the error and debug output to report
+func (a TlsAssertions) checkTls(ctx context.Context, host string, port int, teamIdentifier string) (bool, string, string) {
+	address := net.JoinHostPort(host, strconv.Itoa(port))
+	hostname := strings.ReplaceAll(a.CertHostname, "_", teamIdentifier)
+	config := &tls.Config{
+		InsecureSkipVerify: true, // #nosec G402 -- the certificate is verified against the assertions below
+		MinVersion:         tls.VersionTLS10,
+		ServerName:         hostname,
+	}
+	if config.ServerName == "" && net.ParseIP(host) == nil {
+		config.ServerName = host
+	}
+
+	state, err := tlsHandshake(ctx, address, config)
+	if err != nil {
+		return false, "tls handshake failed", "handshake with " + address + " failed: " + err.Error()
+	}
+	leaf := state.PeerCertificates[0]
+	now := time.Now()
+
+	if now.Before(leaf.NotBefore) {
+		return false, "certificate is not yet valid", "certificate is valid from " + leaf.NotBefore.Format(time.RFC3339)
+	}
+	if now.After(leaf.NotAfter) {
+		return false, "certificate has expired", "certificate expired " + leaf.NotAfter.Format(time.RFC3339)
+	}
+	if a.CertMinDays > 0 && leaf.NotAfter.Before(now.AddDate(0, 0, a.CertMinDays)) {
+		return false, "certificate expires too soon", fmt.Sprintf("certificate expires %s, wanted at least %d more days", leaf.NotAfter.Format(time.RFC3339), a.CertMinDays)
+	}
+	if hostname != "" {
+		if err := leaf.VerifyHostname(hostname); err != nil {
+			return false, "certificate does not match hostname", fmt.Sprintf("certificate for %v does not cover %s", leaf.DNSNames, hostname)
+		}
+	}
+	if a.CertCA != "" {
+		roots, err := loadCA(a.CertCA)
+		if err != nil {
+			return false, "error loading CA certificate", err.Error()
+		}
+		intermediates := x509.NewCertPool()
+		for _, cert := range state.PeerCertificates[1:] {
+			intermediates.AddCert(cert)
+		}
+		if _, err := leaf.Verify(x509.VerifyOptions{Roots: roots, Intermediates: intermediates, CurrentTime: now}); err != nil {
+			return false, "certificate chain is not trusted", "certificate chain did not validate against " + a.CertCA + ": " + err.Error()
+		}
+	}
+
+	// the server must refuse a handshake offering only what it shouldn't accept
+	if minimum := tlsVersions[a.TlsMinVersion]; minimum > tls.VersionTLS10 {
+		old := config.Clone()
+		old.MaxVersion = minimum - 1
+		if state, err := tlsHandshake(ctx, address, old); err == nil {
+			return false, "server accepts an old TLS version", "server accepted " + tls.VersionName(state.Version) + ", wanted at least TLS " + a.TlsMinVersion
+		}
+	}
+	if len(a.TlsForbiddenCiphers) > 0 {
+		forbidden := config.Clone()
+		forbidden.MaxVersion = tls.VersionTLS12 // TLS 1.3 suites can't be chosen
+		for _, cipher := range a.TlsForbiddenCiphers {
+			id, _ := tlsCipherSuite(cipher)
+			forbidden.CipherSuites = append(forbidden.CipherSuites, id)
+		}
+		if state, err := tlsHandshake(ctx, address, forbidden); err == nil && slices.Contains(forbidden.CipherSuites, state.CipherSuite) {
+			return false, "server accepts a forbidden cipher suite", "server accepted " + tls.CipherSuiteName(state.CipherSuite)
+		}
+	}
+
+	return true, "", fmt.Sprintf("%s with %s, certificate for %v valid until %s", tls.VersionName(state.Version), tls.CipherSuiteName(state.CipherSuite), leaf.DNSNames, leaf.NotAfter.Format(time.DateOnly))
+}
+
+func tlsHandshake(ctx context.Context, address string, config *tls.Config) (tls.ConnectionState, error) {
+	dialer := &tls.Dialer{Config: config}
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return tls.ConnectionState{}, err
+	}
+	defer conn.Close()
+	return conn.(*tls.Conn).ConnectionState(), nil
+}
+
+// loadCA reads a PEM encoded CA certificate from config/certs
+func loadCA(fileName string) (*x509.CertPool, error) {
+	root, err := os.OpenRoot("./config/certs")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open certs directory: %w", err)
+	}
+	defer root.Close()
+
+	content, err := root.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(content) {
+		return nil, errors.New("no certificates found in " + fileName)
+	}
+	return pool, nil
+}
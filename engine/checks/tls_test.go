@@ -0,0 +1,161 @@
+package checks
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestCertificate returns a certificate for dnsNames valid for days, signed by
+// parent, or self-signed as a CA when parent is nil
+func newTestCertificate(t *testing.T, parent *tls.Certificate, days int, dnsNames ...string) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: "quotient test"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().AddDate(0, 0, days),
+		DNSNames:     dnsNames,
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	signer, signerKey := template, any(key)
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+	} else {
+		signer, signerKey = parent.Leaf, parent.PrivateKey
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	require.NoError(t, err)
+	leaf, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}
+}
+
+func TestTlsAssertions(t *testing.T) {
+	t.Chdir(t.TempDir())
+	ca := newTestCertificate(t, nil, 3650)
+	otherCA := newTestCertificate(t, nil, 3650)
+	require.NoError(t, os.MkdirAll("config/certs", 0o750))
+	require.NoError(t, os.WriteFile("config/certs/team-ca.pem", pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.Leaf.Raw}), 0o600))
+	require.NoError(t, os.WriteFile("config/certs/other-ca.pem", pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: otherCA.Leaf.Raw}), 0o600))
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	server.TLS = &tls.Config{
+		Certificates: []tls.Certificate{newTestCertificate(t, &ca, 90, "www.team01.local")},
+		MinVersion:   tls.VersionTLS12,
+		CipherSuites: []uint16{tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256},
+	}
+	server.StartTLS()
+	defer server.Close()
+	host, port, err := net.SplitHostPort(server.Listener.Addr().String())
+	require.NoError(t, err)
+
+	check := &Web{
+		Service: Service{
+			Target:  host,
+			Timeout: 5,
+		},
+		TlsAssertions: TlsAssertions{
+			CertMinDays:         30,
+			CertHostname:        "www.team_.local",
+			CertCA:              "team-ca.pem",
+			TlsMinVersion:       "1.2",
+			TlsForbiddenCiphers: []string{"TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA"},
+		},
+		Scheme: "https",
+		Url:    []urlData{{Path: "/", Status: 200}},
+	}
+	check.Port, _ = strconv.Atoi(port)
+	require.NoError(t, check.Verify("web01", host, 5, 5, 1, 3))
+
+	run := func() Result {
+		resultsChan := make(chan Result, 1)
+		check.Run(context.Background(), 1, "01", 1, resultsChan)
+		select {
+		case result := <-resultsChan:
+			return result
+		case <-time.After(10 * time.Second):
+			t.Fatal("Check timed out")
+			return Result{}
+		}
+	}
+
+	result := run()
+	assert.True(t, result.Status, "%s: %s", result.Error, result.Debug)
+
+	for _, tt := range []struct {
+		name       string
+		assertions TlsAssertions
+		err        string
+	}{
+		{"expiry", TlsAssertions{CertMinDays: 120}, "certificate expires too soon"},
+		{"hostname", TlsAssertions{CertHostname: "mail.team_.local"}, "certificate does not match hostname"},
+		{"chain", TlsAssertions{CertCA: "other-ca.pem"}, "certificate chain is not trusted"},
+		{"version", TlsAssertions{TlsMinVersion: "1.3"}, "server accepts an old TLS version"},
+		{"cipher", TlsAssertions{TlsForbiddenCiphers: []string{"TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256"}}, "server accepts a forbidden cipher suite"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			check.TlsAssertions = tt.assertions
+			result := run()
+			assert.False(t, result.Status)
+			assert.Equal(t, tt.err, result.Error)
+		})
+	}
+}
+
+// TestTlsAssertionsStopAtDeadline tests the handshakes give up when the check runs out of time
+func TestTlsAssertionsStopAtDeadline(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+	// accept connections but never answer the handshake
+	go func() {
+		var conns []net.Conn
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				for _, conn := range conns {
+					conn.Close()
+				}
+				return
+			}
+			conns = append(conns, conn)
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	ok, errMsg, _ := TlsAssertions{CertMinDays: 30}.checkTls(ctx, "127.0.0.1", listener.Addr().(*net.TCPAddr).Port, "01")
+	assert.False(t, ok)
+	assert.Equal(t, "tls handshake failed", errMsg)
+	assert.Less(t, time.Since(start), 2*time.Second)
+}
+
+func TestTlsAssertionsVerify(t *testing.T) {
+	assert.NoError(t, TlsAssertions{}.verifyTls("web01-web", false))
+	assert.ErrorContains(t, TlsAssertions{CertMinDays: 30}.verifyTls("web01-web", false), "does not connect over TLS")
+	assert.ErrorContains(t, TlsAssertions{TlsMinVersion: "1.4"}.verifyTls("web01-web", true), "tlsminversion must be one of")
+	assert.ErrorContains(t, TlsAssertions{TlsForbiddenCiphers: []string{"TLS_NULL"}}.verifyTls("web01-web", true), "unknown cipher suite TLS_NULL")
+}
@@ -13,7 +13,7 @@ type Vnc struct {
 	Service
 }
 
-func (c Vnc) Run(teamID uint, teamIdentifier string, roundID uint, resultsChan chan Result) {
+func (c Vnc) Run(ctx context.Context, teamID uint, teamIdentifier string, roundID uint, resultsChan chan Result) {
 	definition := func(teamID uint, teamIdentifier string, checkResult Result, response chan Result) {
 
 		// Configure the vnc client
@@ -33,7 +33,7 @@ func (c Vnc) Run(teamID uint, teamIdentifier string, roundID uint, resultsChan c
 
 		// Dial the vnc server
 		dialer := net.Dialer{}
-		conn, err := dialer.DialContext(context.TODO(), "tcp", fmt.Sprintf("%s:%d", c.Target, c.Port))
+		conn, err := dialer.DialContext(ctx, "tcp", fmt.Sprintf("%s:%d", c.Target, c.Port))
 		if err != nil {
 			checkResult.Error = "connection to vnc server failed"
 			checkResult.Debug = err.Error() + " for creds " + username + ":" + password
@@ -1,13 +1,15 @@
 package checks
 
 import (
+	"cmp"
+	"context"
 	"crypto/tls"
 	"errors"
 	"fmt"
 	"io"
 	"log/slog"
-	"math/rand"
 	"net/http"
+	"net/http/cookiejar"
 	"net/url"
 	"regexp"
 	"strconv"
@@ -19,25 +21,42 @@ import (
 
 type Web struct {
 	Service
+	TlsAssertions
 	Url    []urlData
 	Scheme string
+	// PartialCredit checks every url each round and awards a share of the points
+	// for each one that passes, instead of checking one at random
+	PartialCredit bool `toml:",omitempty"`
+	// Step is a scenario run in order every round instead of checking a url, ex.
+	// logging in and then loading a page only a logged in user can see
+	Step []webStep `toml:",omitempty"`
+}
+
+// webStep is one request of a scenario. USERNAME and PASSWORD in its path,
+// headers, form and body are filled in from the check's credlists, and {{name}}
+// with a value extracted from an earlier response.
+type webStep struct {
+	Method  string            `toml:",omitempty"` // GET unless set
+	Path    string            `toml:",omitempty"`
+	Header  map[string]string `toml:",omitempty"`
+	Form    map[string]string `toml:",omitempty"` // sent url-encoded as the body
+	Body    string            `toml:",omitempty"`
+	Status  int               `toml:",omitempty"`
+	Regex   string            `toml:",omitempty"`
+	Extract map[string]string `toml:",omitempty"` // values for later steps, a regex whose first group is the value (ex. a CSRF token)
 }
 
 type urlData struct {
 	Path        string
-	Status      int    `toml:",omitempty"`
-	Diff        int    `toml:",omitempty"`
-	Regex       string `toml:",omitempty"`
-	CompareFile string `toml:",omitempty"` // TODO implement
+	Status      int      `toml:",omitempty"`
+	Diff        int      `toml:",omitempty"` // Diff is how many percent of the lines may differ from CompareFile
+	Regex       string   `toml:",omitempty"`
+	CompareFile string   `toml:",omitempty"` // CompareFile is the original page in config/scoredfiles
+	Strip       []string `toml:",omitempty"` // Strip removes dynamic parts, matched by regex, from both pages before comparing
 }
 
-func (c Web) Run(teamID uint, teamIdentifier string, roundID uint, resultsChan chan Result) {
+func (c Web) Run(ctx context.Context, teamID uint, teamIdentifier string, roundID uint, resultsChan chan Result) {
 	definition := func(teamID uint, teamIdentifier string, checkResult Result, response chan Result) {
-		u := c.Url[rand.Intn(len(c.Url))] // #nosec G404 -- non-crypto selection of URL to test
-
-		// random user agent
-		ua := uarand.GetRandom()
-
 		tr := &http.Transport{
 			MaxIdleConns:      1,
 			IdleConnTimeout:   time.Duration(c.Timeout) * time.Second, // address this
@@ -53,86 +72,228 @@ func (c Web) Run(teamID uint, teamIdentifier string, roundID uint, resultsChan c
 			Timeout:   clientTimeout,
 		}
 
-		requestURL := fmt.Sprintf("%s://%s:%d%s", c.Scheme, c.Target, c.Port, u.Path)
-		parsedURL, err := url.Parse(requestURL)
-		if err != nil || (parsedURL.Scheme != "http" && parsedURL.Scheme != "https") {
-			checkResult.Error = "invalid request URL"
-			checkResult.Debug = "URL failed validation: " + requestURL
+		if len(c.Step) > 0 {
+			// cookies set by a step are sent by the ones after it, like a browser session
+			client.Jar, _ = cookiejar.New(nil)
+
+			var username, password string
+			if len(c.CredLists) > 0 {
+				var err error
+				username, password, err = c.getCreds(teamID)
+				if err != nil {
+					checkResult.Error = "error getting creds"
+					checkResult.Debug = err.Error()
+					response <- checkResult
+					return
+				}
+			}
+
+			passed, errMsg, debug := c.runScenario(ctx, client, username, password)
+			if c.PartialCredit {
+				// every step is worth an equal share of the points, a scenario stops at the first step that fails
+				checkResult.Parts = len(c.Step)
+				checkResult.PartsPassed = passed
+			}
+			checkResult.Status = passed == len(c.Step)
+			checkResult.Error = errMsg
+			checkResult.Debug = debug
+			if username != "" {
+				checkResult.Debug += ", creds used were " + username + ":" + password
+			}
 			response <- checkResult
 			return
 		}
-		req, err := http.NewRequest("GET", parsedURL.String(), nil)
-		if err != nil {
-			checkResult.Error = "error creating web request"
-			checkResult.Debug = err.Error()
+
+		if !c.PartialCredit {
+			u := c.Url[c.pick(roundID, len(c.Url))]
+			checkResult.Item = u.Path
+			checkResult.Status, checkResult.Error, checkResult.Debug = c.checkUrl(ctx, client, u)
 			response <- checkResult
 			return
 		}
 
-		req.Header.Set("User-Agent", ua)
+		// every url is worth an equal share of the points
+		var debug []string
+		for _, u := range c.Url {
+			ok, errMsg, urlDebug := c.checkUrl(ctx, client, u)
+			if ok {
+				checkResult.PartsPassed++
+			} else if checkResult.Error == "" {
+				checkResult.Error = errMsg
+			}
+			debug = append(debug, urlDebug)
+		}
+		checkResult.Parts = len(c.Url)
+		checkResult.Status = checkResult.PartsPassed == checkResult.Parts
+		checkResult.Debug = fmt.Sprintf("%d of %d urls passed: %s", checkResult.PartsPassed, checkResult.Parts, strings.Join(debug, "; "))
+		response <- checkResult
+	}
 
-		// Store request info for timeout debugging
-		checkResult.Debug = fmt.Sprintf("Attempting GET %s", requestURL)
+	c.Service.Run(teamID, teamIdentifier, roundID, resultsChan, c.withTls(ctx, c.Scheme == "https", c.Port, c.Timeout, definition))
+}
 
-		resp, err := client.Do(req) // #nosec G704 -- URL is validated above; target comes from admin-controlled event.conf
+// checkUrl requests one url of the check, returning whether it passed along with
+// the error and debug output to report
+func (c Web) checkUrl(ctx context.Context, client *http.Client, u urlData) (bool, string, string) {
+	requestURL := fmt.Sprintf("%s://%s:%d%s", c.Scheme, c.Target, c.Port, u.Path)
+	parsedURL, err := url.Parse(requestURL)
+	if err != nil || (parsedURL.Scheme != "http" && parsedURL.Scheme != "https") {
+		return false, "invalid request URL", "URL failed validation: " + requestURL
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", parsedURL.String(), nil)
+	if err != nil {
+		return false, "error creating web request", err.Error()
+	}
+
+	// random user agent
+	req.Header.Set("User-Agent", uarand.GetRandom())
+
+	resp, err := client.Do(req) // #nosec G704 -- URL is validated above; target comes from admin-controlled event.conf
+	if err != nil {
+		if strings.Contains(err.Error(), "Client.Timeout exceeded") {
+			return false, "web request errored out", fmt.Sprintf("HTTP request to %s timed out after %v (TCP connection may have succeeded but server did not respond)", requestURL, client.Timeout)
+		}
+		return false, "web request errored out", err.Error() + " for url " + u.Path
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			slog.Error("failed to close http response body", "error", err)
+		}
+	}()
+
+	if u.Status != 0 && resp.StatusCode != u.Status {
+		return false, "status returned by webserver was incorrect", "status was " + strconv.Itoa(resp.StatusCode) + " wanted " + strconv.Itoa(u.Status) + " for url " + u.Path
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return false, "error reading page content", "error was '" + err.Error() + "' for url " + u.Path
+	}
+
+	if u.Regex != "" {
+		re, err := regexp.Compile(u.Regex)
 		if err != nil {
-			checkResult.Error = "web request errored out"
-			if strings.Contains(err.Error(), "Client.Timeout exceeded") {
-				checkResult.Debug = fmt.Sprintf("HTTP request to %s timed out after %v (TCP connection may have succeeded but server did not respond)", requestURL, clientTimeout)
-			} else {
-				checkResult.Debug = err.Error() + " for url " + u.Path
+			return false, "error compiling regex to match for web page", err.Error()
+		}
+		if re.Find(body) == nil {
+			return false, "didn't find regex on page", "couldn't find regex \"" + u.Regex + "\" for " + u.Path
+		}
+		if u.CompareFile == "" {
+			return true, "", "matched regex \"" + u.Regex + "\" for " + u.Path
+		}
+	}
+
+	if u.CompareFile != "" {
+		baseline, err := GetFile(u.CompareFile)
+		if err != nil {
+			return false, "error opening compare file", err.Error()
+		}
+		page := string(body)
+		for _, pattern := range u.Strip {
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				return false, "error compiling strip regex for web page", err.Error()
 			}
-			response <- checkResult
-			return
+			baseline = re.ReplaceAllString(baseline, "")
+			page = re.ReplaceAllString(page, "")
 		}
+		diff := ContentDifference(baseline, page)
+		if diff > u.Diff {
+			return false, "page differed too much from the original", fmt.Sprintf("%s was %d%% different from %s, at most %d%% allowed", u.Path, diff, u.CompareFile, u.Diff)
+		}
+		return true, "", fmt.Sprintf("%s was %d%% different from %s", u.Path, diff, u.CompareFile)
+	}
 
-		if u.Status != 0 && resp.StatusCode != u.Status {
-			checkResult.Error = "status returned by webserver was incorrect"
-			checkResult.Debug = "status was " + strconv.Itoa(resp.StatusCode) + " wanted " + strconv.Itoa(u.Status) + " for url " + u.Path
-			response <- checkResult
-			return
+	return true, "", "GET " + requestURL + " succeeded"
+}
+
+// runScenario runs the steps of the check in order, stopping at the first one
+// that fails. It returns how many steps passed along with the error and debug
+// output to report.
+func (c Web) runScenario(ctx context.Context, client *http.Client, username string, password string) (int, string, string) {
+	values := make(map[string]string)
+	expand := func(s string, escape func(string) string) string {
+		s = strings.ReplaceAll(s, "USERNAME", escape(username))
+		s = strings.ReplaceAll(s, "PASSWORD", escape(password))
+		for name, value := range values {
+			s = strings.ReplaceAll(s, "{{"+name+"}}", escape(value))
+		}
+		return s
+	}
+	raw := func(s string) string { return s }
+
+	for i, step := range c.Step {
+		method := cmp.Or(strings.ToUpper(step.Method), http.MethodGet)
+		requestURL := fmt.Sprintf("%s://%s:%d%s", c.Scheme, c.Target, c.Port, expand(step.Path, url.QueryEscape))
+		prefix := fmt.Sprintf("step %d %s %s", i+1, method, step.Path)
+		parsedURL, err := url.Parse(requestURL)
+		if err != nil || (parsedURL.Scheme != "http" && parsedURL.Scheme != "https") {
+			return i, "invalid request URL", prefix + ": URL failed validation: " + requestURL
 		}
 
-		defer func() {
+		var body io.Reader
+		contentType := ""
+		if len(step.Form) > 0 {
+			form := url.Values{}
+			for field, value := range step.Form {
+				form.Set(field, expand(value, raw))
+			}
+			body = strings.NewReader(form.Encode())
+			contentType = "application/x-www-form-urlencoded"
+		} else if step.Body != "" {
+			body = strings.NewReader(expand(step.Body, raw))
+		}
+
+		req, err := http.NewRequestWithContext(ctx, method, parsedURL.String(), body)
+		if err != nil {
+			return i, "error creating web request", prefix + ": " + err.Error()
+		}
+		req.Header.Set("User-Agent", uarand.GetRandom())
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		for header, value := range step.Header {
+			req.Header.Set(header, expand(value, raw))
+		}
+
+		resp, err := client.Do(req) // #nosec G704 -- URL is validated above; target comes from admin-controlled event.conf
+		if err != nil {
+			return i, "web request errored out", prefix + ": " + err.Error()
+		}
+		content, err := io.ReadAll(resp.Body)
 		if err := resp.Body.Close(); err != nil {
 			slog.Error("failed to close http response body", "error", err)
 		}
-	}()
-		body, err := io.ReadAll(resp.Body)
 		if err != nil {
-			checkResult.Error = "error reading page content"
-			checkResult.Debug = "error was '" + err.Error() + "' for url " + u.Path
-			response <- checkResult
-			return
+			return i, "error reading page content", prefix + ": " + err.Error()
 		}
 
-		if u.Regex != "" {
-			re, err := regexp.Compile(u.Regex)
+		if step.Status != 0 && resp.StatusCode != step.Status {
+			return i, fmt.Sprintf("step %d status returned by webserver was incorrect", i+1), prefix + ": status was " + strconv.Itoa(resp.StatusCode) + " wanted " + strconv.Itoa(step.Status)
+		}
+		if step.Regex != "" {
+			re, err := regexp.Compile(expand(step.Regex, regexp.QuoteMeta))
 			if err != nil {
-				checkResult.Error = "error compiling regex to match for web page"
-				checkResult.Debug = err.Error()
-				response <- checkResult
-				return
+				return i, "error compiling regex to match for web page", prefix + ": " + err.Error()
 			}
-			reFind := re.Find(body)
-			if reFind == nil {
-				checkResult.Error = "didn't find regex on page"
-				checkResult.Debug = "couldn't find regex \"" + u.Regex + "\" for " + u.Path
-				response <- checkResult
-				return
-			} else {
-				checkResult.Status = true
-				checkResult.Debug = "matched regex \"" + u.Regex + "\" for " + u.Path
-				response <- checkResult
-				return
+			if re.Find(content) == nil {
+				return i, fmt.Sprintf("step %d didn't find regex on page", i+1), prefix + ": couldn't find regex \"" + step.Regex + "\""
 			}
 		}
-
-		checkResult.Status = true
-		response <- checkResult
+		for name, pattern := range step.Extract {
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				return i, "error compiling regex to extract from web page", prefix + ": " + err.Error()
+			}
+			match := re.FindSubmatch(content)
+			if len(match) < 2 {
+				return i, fmt.Sprintf("step %d couldn't extract %s from page", i+1, name), prefix + ": couldn't find regex \"" + pattern + "\""
+			}
+			values[name] = string(match[1])
+		}
 	}
 
-	c.Service.Run(teamID, teamIdentifier, roundID, resultsChan, definition)
+	return len(c.Step), "", fmt.Sprintf("all %d steps passed", len(c.Step))
 }
 
 func (c *Web) Verify(box string, ip string, points int, timeout int, slapenalty int, slathreshold int) error {
@@ -159,9 +320,23 @@ func (c *Web) Verify(box string, ip string, points int, timeout int, slapenalty
 			c.Port = 80
 		}
 	}
-	if len(c.Url) == 0 {
+	if len(c.Url) == 0 && len(c.Step) == 0 {
 		return errors.New("no urls defined")
 	}
+	for i, step := range c.Step {
+		if step.Form != nil && step.Body != "" {
+			return fmt.Errorf("step %d of web check %s has both a form and a body", i+1, c.Name)
+		}
+		for _, pattern := range step.Extract {
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				return fmt.Errorf("step %d of web check %s has an invalid extract regex: %w", i+1, c.Name, err)
+			}
+			if re.NumSubexp() < 1 {
+				return fmt.Errorf("step %d of web check %s extracts with a regex that has no group", i+1, c.Name)
+			}
+		}
+	}
 	if c.Scheme == "" {
 		c.Scheme = "http"
 	}
@@ -169,10 +344,22 @@ func (c *Web) Verify(box string, ip string, points int, timeout int, slapenalty
 		if u.Diff != 0 && u.CompareFile == "" {
 			return errors.New("need compare file for diff in web")
 		}
+		if u.Diff < 0 || u.Diff > 100 {
+			return errors.New("diff in web must be a percentage between 0 and 100")
+		}
+		for _, pattern := range u.Strip {
+			if _, err := regexp.Compile(pattern); err != nil {
+				return fmt.Errorf("invalid strip regex in web check %s: %w", c.Name, err)
+			}
+		}
 		if u.Path == "" {
 			u.Path = "/"
 		}
 	}
 
+	if err := c.verifyTls(c.Name, c.Scheme == "https"); err != nil {
+		return err
+	}
+
 	return nil
 }
@@ -2,8 +2,8 @@ package checks
 
 import (
 	"bytes"
+	"context"
 	"log/slog"
-	"math/rand"
 	"regexp"
 	"strings"
 	"time"
@@ -25,7 +25,7 @@ type winCommandData struct {
 	Output   string
 }
 
-func (c WinRM) Run(teamID uint, teamIdentifier string, roundID uint, resultsChan chan Result) {
+func (c WinRM) Run(ctx context.Context, teamID uint, teamIdentifier string, roundID uint, resultsChan chan Result) {
 	definition := func(teamID uint, teamIdentifier string, checkResult Result, response chan Result) {
 		username, password, err := c.getCreds(teamID)
 		if err != nil {
@@ -61,11 +61,12 @@ func (c WinRM) Run(teamID uint, teamIdentifier string, roundID uint, resultsChan
 		// If any commands specified, run them; otherwise run a simple connectivity test
 		var powershellCmd string
 		if len(c.Command) > 0 {
-			r := c.Command[rand.Intn(len(c.Command))] // #nosec G404 -- non-crypto selection of command to test
+			r := c.Command[c.pick(roundID, len(c.Command))]
+			checkResult.Item = r.Command
 			powershellCmd = winrm.Powershell(r.Command)
 			bufOut := new(bytes.Buffer)
 			bufErr := new(bytes.Buffer)
-			_, err = client.Run(powershellCmd, bufOut, bufErr)
+			_, err = client.RunWithContext(ctx, powershellCmd, bufOut, bufErr)
 			output := bufOut.Bytes()
 			errString := bufErr.String()
 			if err != nil {
@@ -101,7 +102,7 @@ func (c WinRM) Run(teamID uint, teamIdentifier string, roundID uint, resultsChan
 			powershellCmd = winrm.Powershell("hostname")
 			bufOut := new(bytes.Buffer)
 			bufErr := new(bytes.Buffer)
-			_, err = client.Run(powershellCmd, bufOut, bufErr)
+			_, err = client.RunWithContext(ctx, powershellCmd, bufOut, bufErr)
 			if err != nil {
 				checkResult.Error = "connection test failed with creds " + username + ":" + password
 				checkResult.Debug = err.Error()
@@ -1,12 +1,15 @@
 package config
 
 import (
+	"cmp"
 	"errors"
 	"fmt"
 	"log/slog"
+	"maps"
 	"os"
 	"path/filepath"
 	"quotient/engine/checks"
+	"regexp"
 	"slices"
 	"sort"
 	"strings"
@@ -15,7 +18,12 @@ import (
 )
 
 var (
-	supportedEvents = []string{"rvb", "koth"} // golang doesn't have constant arrays :/
+	validTag = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]*$`)
+
+	supportedEvents         = []string{"rvb", "koth"} // golang doesn't have constant arrays :/
+	supportedNoResultPolicy = []string{"down", "up", "exclude"}
+	supportedDispatchModes  = []string{"burst", "random", "spread"}
+	supportedSlaPolicies    = []string{checks.SlaConsecutive, checks.SlaRolling, checks.SlaEscalating}
 )
 
 type ConfigSettings struct {
@@ -125,6 +133,23 @@ type MiscConfig struct {
 	Timeout      int
 	SlaThreshold int
 	SlaPenalty   int
+	SlaPolicy    string // consecutive, rolling or escalating
+	SlaWindow    int    // rounds the rolling SLA policy looks back on, twice the threshold if unset
+
+	// How checks that never reported back in a round are scored: down, up, or exclude
+	NoResultPolicy string
+
+	// Whether rounds a check was not run in because its dependency was down count toward its SLA
+	SlaOnDependencyDown bool
+
+	// When checks are sent out within a round: burst sends them all at round start,
+	// random at a random offset and spread evenly across the round
+	Dispatch string
+
+	// How checks with several urls, records, commands, queries or files pick the one
+	// tested each round: random for every team, round for the same one for every
+	// team, or rotate through them in order
+	Selection string
 }
 
 type UIConfig struct {
@@ -147,6 +172,7 @@ type Inject User
 type Box struct {
 	Name string
 	IP   string
+	Tags []string `toml:",omitempty" json:"tags,omitempty"` // Runner tags required by every check on the box
 
 	// Internal use but not in config file
 	Runners []checks.Runner `toml:"-" json:"-"`
@@ -156,6 +182,7 @@ type Box struct {
 	Dns    []*checks.Dns    `toml:"Dns,omitempty" json:"dns,omitempty"`
 	Ftp    []*checks.Ftp    `toml:"Ftp,omitempty" json:"ftp,omitempty"`
 	Imap   []*checks.Imap   `toml:"Imap,omitempty" json:"imap,omitempty"`
+	Koth   []*checks.Koth   `toml:"Koth,omitempty" json:"koth,omitempty"`
 	Ldap   []*checks.Ldap   `toml:"Ldap,omitempty" json:"ldap,omitempty"`
 	Ping   []*checks.Ping   `toml:"Ping,omitempty" json:"ping,omitempty"`
 	Pop3   []*checks.Pop3   `toml:"Pop3,omitempty" json:"pop3,omitempty"`
@@ -335,6 +362,34 @@ func checkConfig(conf *ConfigSettings) error {
 		conf.MiscSettings.SlaPenalty = conf.MiscSettings.SlaThreshold * conf.MiscSettings.Points
 	}
 
+	if conf.MiscSettings.SlaPolicy == "" {
+		conf.MiscSettings.SlaPolicy = checks.SlaConsecutive
+	}
+	if err := validateSlaRule(conf.SlaRule()); err != nil {
+		errResult = errors.Join(errResult, err)
+	}
+
+	if conf.MiscSettings.NoResultPolicy == "" {
+		conf.MiscSettings.NoResultPolicy = "down"
+	}
+	if !slices.Contains(supportedNoResultPolicy, conf.MiscSettings.NoResultPolicy) {
+		errResult = errors.Join(errResult, fmt.Errorf("no result policy must be one of %v", supportedNoResultPolicy))
+	}
+
+	if conf.MiscSettings.Dispatch == "" {
+		conf.MiscSettings.Dispatch = "burst"
+	}
+	if !slices.Contains(supportedDispatchModes, conf.MiscSettings.Dispatch) {
+		errResult = errors.Join(errResult, fmt.Errorf("dispatch must be one of %v", supportedDispatchModes))
+	}
+
+	if conf.MiscSettings.Selection == "" {
+		conf.MiscSettings.Selection = checks.SelectRound
+	}
+	if !slices.Contains(checks.SelectionModes, conf.MiscSettings.Selection) {
+		errResult = errors.Join(errResult, fmt.Errorf("selection must be one of %v", checks.SelectionModes))
+	}
+
 	// OIDC settings defaults
 	if conf.OIDCSettings.OIDCEnabled {
 		if conf.OIDCSettings.OIDCIssuerURL == "" {
@@ -410,9 +465,10 @@ func checkConfig(conf *ConfigSettings) error {
 		allChecks := []checks.Runner{}
 		checkSets := [][]checks.Runner{
 			getRunners(conf.Box[i].Custom), getRunners(conf.Box[i].Dns), getRunners(conf.Box[i].Ftp), getRunners(conf.Box[i].Imap),
-			getRunners(conf.Box[i].Ldap), getRunners(conf.Box[i].Ping), getRunners(conf.Box[i].Pop3), getRunners(conf.Box[i].Rdp),
-			getRunners(conf.Box[i].Smb), getRunners(conf.Box[i].Smtp), getRunners(conf.Box[i].Sql), getRunners(conf.Box[i].Ssh),
-			getRunners(conf.Box[i].Tcp), getRunners(conf.Box[i].Vnc), getRunners(conf.Box[i].Web), getRunners(conf.Box[i].WinRM),
+			getRunners(conf.Box[i].Koth), getRunners(conf.Box[i].Ldap), getRunners(conf.Box[i].Ping), getRunners(conf.Box[i].Pop3),
+			getRunners(conf.Box[i].Rdp), getRunners(conf.Box[i].Smb), getRunners(conf.Box[i].Smtp), getRunners(conf.Box[i].Sql),
+			getRunners(conf.Box[i].Ssh), getRunners(conf.Box[i].Tcp), getRunners(conf.Box[i].Vnc), getRunners(conf.Box[i].Web),
+			getRunners(conf.Box[i].WinRM),
 		}
 		for _, checks := range checkSets {
 			for _, check := range checks {
@@ -426,6 +482,18 @@ func checkConfig(conf *ConfigSettings) error {
 				); err != nil {
 					errResult = errors.Join(errResult, err)
 				}
+				if setter, ok := check.(interface{ SetSlaDefaults(string, int) }); ok {
+					setter.SetSlaDefaults(conf.MiscSettings.SlaPolicy, conf.MiscSettings.SlaWindow)
+				}
+				if setter, ok := check.(interface{ SetSelectionDefault(string) }); ok {
+					setter.SetSelectionDefault(conf.MiscSettings.Selection)
+				}
+				if err := validateSlaRule(check.GetSlaRule()); err != nil {
+					errResult = errors.Join(errResult, fmt.Errorf("invalid SLA settings for %s: %w", check.GetName(), err))
+				}
+				if check.GetType() == "Koth" && conf.RequiredSettings.EventType != "koth" {
+					errResult = errors.Join(errResult, fmt.Errorf("koth check %s requires EventType \"koth\"", check.GetName()))
+				}
 				if _, exists := runnerNames[check.GetName()]; exists {
 					errResult = errors.Join(errResult, fmt.Errorf("duplicate check name found: %s", check.GetName()))
 				} else {
@@ -445,16 +513,153 @@ func checkConfig(conf *ConfigSettings) error {
 					setter.SetCredlists(credPaths)
 				}
 
+				// checks need the tags of their box as well as their own
+				tags, err := NormalizeTags(append(slices.Clone(conf.Box[i].Tags), check.GetTags()...))
+				if err != nil {
+					errResult = errors.Join(errResult, fmt.Errorf("invalid tags for %s: %w", check.GetName(), err))
+				}
+				if setter, ok := check.(interface{ SetTags([]string) }); ok {
+					setter.SetTags(tags)
+				}
+
 				allChecks = append(allChecks, check)
 			}
 		}
 		conf.Box[i].Runners = allChecks
 	}
+	errResult = errors.Join(errResult, resolveDependencies(conf))
 
 	// errResult is nil by default if no errors occured
 	return errResult
 }
 
+// SlaRule returns the SLA rule of checks that are no longer configured
+func (conf *ConfigSettings) SlaRule() checks.SlaRule {
+	return checks.SlaRule{
+		Policy:    conf.MiscSettings.SlaPolicy,
+		Threshold: conf.MiscSettings.SlaThreshold,
+		Window:    cmp.Or(conf.MiscSettings.SlaWindow, 2*conf.MiscSettings.SlaThreshold),
+		Penalty:   conf.MiscSettings.SlaPenalty,
+	}
+}
+
+// SlaRules returns the SLA rule of every configured check by name
+func (conf *ConfigSettings) SlaRules() map[string]checks.SlaRule {
+	rules := make(map[string]checks.SlaRule)
+	for _, check := range conf.AllChecks() {
+		rules[check.GetName()] = check.GetSlaRule()
+	}
+	return rules
+}
+
+func validateSlaRule(rule checks.SlaRule) error {
+	if !slices.Contains(supportedSlaPolicies, rule.Policy) {
+		return fmt.Errorf("SLA policy must be one of %v", supportedSlaPolicies)
+	}
+	if rule.Threshold < 1 {
+		return errors.New("SLA threshold must be at least 1")
+	}
+	if rule.Policy == checks.SlaRolling && rule.Window < rule.Threshold {
+		return errors.New("SLA window must be at least the SLA threshold")
+	}
+	return nil
+}
+
+// resolveDependencies turns the dependencies of every check into full check names,
+// accepting the display name of a check on the same box, and rejects cycles
+func resolveDependencies(conf *ConfigSettings) error {
+	var errResult error
+	byName := make(map[string]checks.Runner)
+	for _, box := range conf.Box {
+		for _, check := range box.Runners {
+			byName[check.GetName()] = check
+		}
+	}
+
+	for _, box := range conf.Box {
+		for _, check := range box.Runners {
+			if len(check.GetDependsOn()) == 0 {
+				continue
+			}
+			resolved := make([]string, 0, len(check.GetDependsOn()))
+			for _, dep := range check.GetDependsOn() {
+				name := dep
+				if _, ok := byName[name]; !ok {
+					name = box.Name + "-" + dep
+				}
+				if other, ok := byName[name]; !ok || other.GetType() == "Koth" {
+					errResult = errors.Join(errResult, fmt.Errorf("dependency %s of %s not found", dep, check.GetName()))
+					continue
+				}
+				if name == check.GetName() {
+					errResult = errors.Join(errResult, fmt.Errorf("check %s can't depend on itself", check.GetName()))
+					continue
+				}
+				resolved = append(resolved, name)
+			}
+			slices.Sort(resolved)
+			if setter, ok := check.(interface{ SetDependsOn([]string) }); ok {
+				setter.SetDependsOn(slices.Compact(resolved))
+			}
+		}
+	}
+	if errResult != nil {
+		return errResult
+	}
+
+	// a cycle would leave every check in it waiting on the others
+	const (
+		visiting = 1
+		done     = 2
+	)
+	state := make(map[string]int)
+	var visit func(name string, path []string) error
+	visit = func(name string, path []string) error {
+		switch state[name] {
+		case visiting:
+			return fmt.Errorf("dependency cycle: %s", strings.Join(append(path, name), " -> "))
+		case done:
+			return nil
+		}
+		state[name] = visiting
+		for _, dep := range byName[name].GetDependsOn() {
+			if err := visit(dep, append(path, name)); err != nil {
+				return err
+			}
+		}
+		state[name] = done
+		return nil
+	}
+	names := slices.Sorted(maps.Keys(byName))
+	for _, name := range names {
+		if err := visit(name, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// NormalizeTags lowercases, sorts and deduplicates runner tags, rejecting ones
+// that could not be used in a queue name. Empty tags are left out, as in an
+// unset or comma terminated list.
+func NormalizeTags(tags []string) ([]string, error) {
+	var errResult error
+	var out []string
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" {
+			continue
+		}
+		if !validTag.MatchString(tag) {
+			errResult = errors.Join(errResult, fmt.Errorf("tag %q must be letters, digits, '.', '_' or '-'", tag))
+			continue
+		}
+		out = append(out, tag)
+	}
+	slices.Sort(out)
+	return slices.Compact(out), errResult
+}
+
 func getRunners[T checks.Runner](arr []T) []checks.Runner {
 	if len(arr) == 0 {
 		return nil // Return nil instead of empty slice to make it clearer in logs
@@ -1,12 +1,18 @@
 package engine
 
 import (
+	"cmp"
 	"encoding/csv"
 	"fmt"
 	"log/slog"
 	"os"
+	"quotient/engine/checks"
 	"quotient/engine/db"
+	"slices"
+	"strings"
 	"sync"
+
+	"al.essio.dev/pkg/shellescape"
 )
 
 // safeOpenInDir opens a file within the given base directory safely using os.Root.
@@ -221,3 +227,73 @@ func (se *ScoringEngine) GetTeamCredentials(teamID uint, credlistName string) ([
 func (se *ScoringEngine) GetPCRHistory(teamID uint, credlistName string, username string) ([]db.PCRHistorySchema, error) {
 	return db.GetPCRHistory(teamID, credlistName, username)
 }
+
+// redacted replaces credential values scrubbed from check output
+const redacted = "[redacted]"
+
+// passwords shorter than this are left in check output, scrubbing them would mangle it
+const minScrubbedLength = 4
+
+// credentialReplacer returns a replacer removing the given passwords from text, both
+// as they are and shell quoted the way custom checks substitute them into commands
+func credentialReplacer(passwords []string) *strings.Replacer {
+	var values []string
+	for _, password := range passwords {
+		if len(password) < minScrubbedLength {
+			continue
+		}
+		values = append(values, password, shellescape.Quote(password))
+	}
+	// longest first, so a password containing another one is removed whole
+	slices.SortFunc(values, func(a, b string) int {
+		if n := cmp.Compare(len(b), len(a)); n != 0 {
+			return n
+		}
+		return strings.Compare(a, b)
+	})
+	values = slices.Compact(values)
+
+	oldnew := make([]string, 0, 2*len(values))
+	for _, value := range values {
+		oldnew = append(oldnew, value, redacted)
+	}
+	return strings.NewReplacer(oldnew...)
+}
+
+// credentialScrubbers returns a replacer per team in results that removes the team's
+// known passwords, so they never reach the database through check output
+func credentialScrubbers(results []checks.Result) map[uint]*strings.Replacer {
+	scrubbers := make(map[uint]*strings.Replacer)
+	for _, result := range results {
+		if _, ok := scrubbers[result.TeamID]; ok {
+			continue
+		}
+		creds, err := db.GetAllTeamCredentials(result.TeamID)
+		if err != nil {
+			slog.Error("failed to load credentials to scrub from check output", "team", result.TeamID, "error", err)
+		}
+		passwords := make([]string, len(creds))
+		for i, c := range creds {
+			passwords[i] = c.Password
+		}
+		scrubbers[result.TeamID] = credentialReplacer(passwords)
+	}
+	return scrubbers
+}
+
+// ScrubResult removes the task's passwords from the result's check output, so
+// they never reach the results stream the runner pushes it to
+func (task *Task) ScrubResult(result *checks.Result) {
+	passwords := make([]string, len(task.Credentials))
+	for i, c := range task.Credentials {
+		passwords[i] = c.Password
+	}
+	scrub := credentialReplacer(passwords)
+	result.Error = scrub.Replace(result.Error)
+	result.Debug = scrub.Replace(result.Debug)
+	result.Item = scrub.Replace(result.Item)
+	for i := range result.Attempts {
+		result.Attempts[i].Error = scrub.Replace(result.Attempts[i].Error)
+		result.Attempts[i].Debug = scrub.Replace(result.Attempts[i].Debug)
+	}
+}
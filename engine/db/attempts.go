@@ -0,0 +1,36 @@
+package db
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+// CheckAttemptSchema is one try of a service check within a round. The last
+// attempt of a round is the one recorded in ServiceCheckSchema.
+type CheckAttemptSchema struct {
+	ID          uint
+	TeamID      uint `gorm:"index:idx_attempt_team_round"`
+	RoundID     uint `gorm:"index:idx_attempt_team_round"`
+	ServiceName string
+	Attempt     int
+	MaxAttempts int
+	Result      bool
+	Error       string
+	Debug       string
+	DurationMs  int64
+	RunnerID    string
+}
+
+// GetCheckAttemptsByTeam returns every recorded attempt for a service, ordered by round then attempt
+func GetCheckAttemptsByTeam(teamID uint, serviceName string) ([]CheckAttemptSchema, error) {
+	var attempts []CheckAttemptSchema
+	result := db.Table("check_attempt_schemas").Where("team_id = ? AND service_name = ?", teamID, serviceName).Order("round_id desc, attempt asc").Find(&attempts)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return attempts, nil
+		}
+		return nil, result.Error
+	}
+	return attempts, nil
+}
@@ -39,31 +39,57 @@ func Connect(connectURL string) {
 
 	slog.Info("Connected to DB")
 
+	// rounds scored before earned points existed need them filled in once the column is added
+	backfillEarned := db.Migrator().HasTable(&ServiceCheckSchema{}) && !db.Migrator().HasColumn(&ServiceCheckSchema{}, "Earned")
+
 	err = db.AutoMigrate(&AnnouncementSchema{},
-		&TeamSchema{}, &RoundSchema{}, &ServiceCheckSchema{}, &SLASchema{}, &ManualAdjustmentSchema{},
+		&TeamSchema{}, &RoundSchema{}, &ServiceCheckSchema{}, &CheckAttemptSchema{}, &SLASchema{}, &ManualAdjustmentSchema{},
 		&InjectSchema{}, &SubmissionSchema{}, &TeamServiceCheckSchema{},
 		// box schema must come first for automigrate to work
 		&VulnSchema{}, &BoxSchema{}, &VectorSchema{}, &AttackSchema{}, &CompetitionStateSchema{},
 		// credential schemas for PCR management
-		&OriginalCredentialSchema{}, &CredentialSchema{}, &PCRHistorySchema{})
+		&OriginalCredentialSchema{}, &CredentialSchema{}, &PCRHistorySchema{},
+		// koth ownership history
+		&KothOwnershipSchema{},
+		// tokens remote runners authenticate with
+		&RunnerTokenSchema{})
 	if err != nil {
 		log.Fatalln("Failed to auto migrate:", err)
 	}
 
+	// Checks scored before earned points existed only earned their points when they passed
+	if backfillEarned {
+		if err := db.Exec("UPDATE service_check_schemas SET earned = points WHERE result").Error; err != nil {
+			log.Fatalln("Failed to backfill earned points:", err)
+		}
+	}
+
 	// Create materialized views
 	createCumulativeScoresView()
 }
 
 // createCumulativeScoresView creates the materialized view for cumulative scores.
 func createCumulativeScoresView() {
+	// Views created before earned points existed summed points of passing checks, drop them to be recreated
+	var stale int64
+	err := db.Raw("SELECT COUNT(*) FROM pg_matviews WHERE matviewname = 'cumulative_scores' AND definition NOT LIKE '%earned%'").Scan(&stale).Error
+	if err != nil {
+		log.Fatalln("Failed to inspect cumulative_scores materialized view:", err)
+	}
+	if stale > 0 {
+		if err := db.Exec("DROP MATERIALIZED VIEW cumulative_scores").Error; err != nil {
+			log.Fatalln("Failed to drop stale cumulative_scores materialized view:", err)
+		}
+	}
+
 	// Create the materialized view if it doesn't exist
 	// If it does exist, CREATE won't refresh it, so we do that separately
-	err := db.Exec(`
+	err = db.Exec(`
 		CREATE MATERIALIZED VIEW IF NOT EXISTS cumulative_scores AS
 		SELECT DISTINCT 
 			round_id, 
 			team_id, 
-			SUM(CASE WHEN result = '1' THEN points ELSE 0 END) 
+			SUM(earned) 
 				OVER(PARTITION BY team_id ORDER BY round_id) as cumulative_points
 		FROM service_check_schemas 
 		ORDER BY team_id, round_id
@@ -149,8 +175,8 @@ func AddTeams(conf *config.ConfigSettings) error {
 }
 
 func ResetScores() error {
-	// truncate servicecheckschemas, slaschemas, and roundschemas with cascade
-	if err := db.Exec("TRUNCATE TABLE service_check_schemas, round_schemas, sla_schemas CASCADE").Error; err != nil {
+	// truncate servicecheckschemas, checkattemptschemas, slaschemas, kothownershipschemas, and roundschemas with cascade
+	if err := db.Exec("TRUNCATE TABLE service_check_schemas, check_attempt_schemas, round_schemas, sla_schemas, koth_ownership_schemas CASCADE").Error; err != nil {
 		return err
 	}
 
@@ -0,0 +1,40 @@
+package db
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+// KothOwnershipSchema records who held a koth box during a round
+// TeamID is 0 when the box was unowned or contested
+type KothOwnershipSchema struct {
+	ID          uint
+	RoundID     uint `gorm:"index"`
+	Round       RoundSchema
+	ServiceName string
+	TeamID      uint
+	Points      int
+	Error       string
+	Debug       string
+}
+
+func CreateKothOwnerships(ownerships []KothOwnershipSchema) error {
+	if len(ownerships) == 0 {
+		return nil
+	}
+	return db.Table("koth_ownership_schemas").Create(&ownerships).Error
+}
+
+// GetKothOwnerships returns the full ownership history, newest round first
+func GetKothOwnerships() ([]KothOwnershipSchema, error) {
+	var ownerships []KothOwnershipSchema
+	result := db.Table("koth_ownership_schemas").Order("round_id desc, service_name").Find(&ownerships)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return ownerships, nil
+		}
+		return nil, result.Error
+	}
+	return ownerships, nil
+}
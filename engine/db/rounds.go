@@ -13,6 +13,7 @@ type RoundSchema struct {
 	StartTime time.Time
 	Checks    []ServiceCheckSchema `gorm:"foreignKey:RoundID"`
 	SLAs      []SLASchema          `gorm:"foreignKey:RoundID"`
+	Attempts  []CheckAttemptSchema `gorm:"foreignKey:RoundID"`
 }
 
 // this is so when we create a new round, we can add checks to it
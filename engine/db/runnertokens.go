@@ -0,0 +1,66 @@
+package db
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// RunnerTokenSchema is a token a remote runner authenticates to the runner API
+// with. Only a hash of the token is stored; the runner reports under Name.
+type RunnerTokenSchema struct {
+	ID        uint
+	Name      string
+	TokenHash string `gorm:"uniqueIndex"`
+	CreatedAt time.Time
+	Revoked   bool
+	RevokedAt time.Time
+}
+
+func CreateRunnerToken(token RunnerTokenSchema) (RunnerTokenSchema, error) {
+	result := db.Table("runner_token_schemas").Create(&token)
+	if result.Error != nil {
+		return RunnerTokenSchema{}, result.Error
+	}
+	return token, nil
+}
+
+// GetRunnerTokens returns every runner token, revoked ones included, newest first
+func GetRunnerTokens() ([]RunnerTokenSchema, error) {
+	var tokens []RunnerTokenSchema
+	result := db.Table("runner_token_schemas").Order("id desc").Find(&tokens)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return tokens, nil
+		}
+		return nil, result.Error
+	}
+	return tokens, nil
+}
+
+// GetActiveRunnerToken returns the token with the given hash unless it was revoked
+func GetActiveRunnerToken(tokenHash string) (RunnerTokenSchema, error) {
+	var token RunnerTokenSchema
+	result := db.Table("runner_token_schemas").Where("token_hash = ? AND revoked = ?", tokenHash, false).First(&token)
+	return token, result.Error
+}
+
+// RunnerTokenNameInUse reports whether an unrevoked token already reports under name
+func RunnerTokenNameInUse(name string) (bool, error) {
+	var count int64
+	result := db.Table("runner_token_schemas").Where("name = ? AND revoked = ?", name, false).Count(&count)
+	return count > 0, result.Error
+}
+
+// RevokeRunnerToken stops a token from being accepted and returns it
+func RevokeRunnerToken(id uint) (RunnerTokenSchema, error) {
+	var token RunnerTokenSchema
+	if result := db.Table("runner_token_schemas").First(&token, id); result.Error != nil {
+		return token, result.Error
+	}
+	token.Revoked = true
+	token.RevokedAt = time.Now()
+	result := db.Table("runner_token_schemas").Where("id = ?", id).Updates(map[string]any{"revoked": true, "revoked_at": token.RevokedAt})
+	return token, result.Error
+}
@@ -6,6 +6,8 @@ import (
 	"math"
 	"slices"
 
+	"quotient/engine/checks"
+
 	"gorm.io/gorm"
 )
 
@@ -15,14 +17,21 @@ type ServiceCheckSchema struct {
 	Round       RoundSchema
 	ServiceName string
 	Points      int
+	Earned      int // points awarded for the round, part of Points for a partially passing check
 	Result      bool
 	Error       string // error
 	Debug       string // informational
+	State       string // empty for a normal pass/fail, otherwise why there is no real result (ex. no_result)
+	Excluded    bool   // excluded from uptime and SLA accounting
+	RootCause   string // for a dependency_down result, the check that failed first
+	Item        string // the url, record, command, query or file the check tested
+
+	Attempts []CheckAttemptSchema `gorm:"-"` // every try made in the round, filled in by GetServiceAllChecksByTeam
 }
 
 func GetServiceCheckSumByTeam() (map[uint]any, error) {
 	result := make(map[uint]any)
-	rows, err := db.Model(ServiceCheckSchema{}).Select("team_id, sum(points) as total").Group("team_id").Having("result = ?", true).Rows()
+	rows, err := db.Model(ServiceCheckSchema{}).Select("team_id, sum(earned) as total").Group("team_id").Rows()
 
 	if err != nil {
 		return nil, err
@@ -136,6 +145,18 @@ func GetServiceAllChecksByTeam(teamID uint, serviceID string) ([]ServiceCheckSch
 		}
 		return nil, result.Error
 	}
+
+	attempts, err := GetCheckAttemptsByTeam(teamID, serviceID)
+	if err != nil {
+		return nil, err
+	}
+	byRound := make(map[uint][]CheckAttemptSchema)
+	for _, attempt := range attempts {
+		byRound[attempt.RoundID] = append(byRound[attempt.RoundID], attempt)
+	}
+	for i := range checks {
+		checks[i].Attempts = byRound[checks[i].RoundID]
+	}
 	return checks, nil
 }
 
@@ -144,12 +165,19 @@ type Uptime struct {
 	TotalChecks  int
 }
 
+// LastResult is how a check scored the last round it counted in
+type LastResult struct {
+	Passed bool
+	Earned int
+}
+
 func LoadUptimes(uptimePerService *map[uint]map[string]Uptime) error {
 	rows, err := db.Raw(`
 		SELECT team_id, service_name, 
 			   SUM(CASE WHEN result = true THEN 1 ELSE 0 END) as passed_checks, 
 			   COUNT(*) as total_checks 
 		FROM service_check_schemas 
+		WHERE excluded IS NOT TRUE
 		GROUP BY team_id, service_name
 	`).Rows()
 	if err != nil {
@@ -179,6 +207,36 @@ func LoadUptimes(uptimePerService *map[uint]map[string]Uptime) error {
 	return nil
 }
 
+// LoadLastResults loads how each team's check scored the last round it counted in
+func LoadLastResults(lastResultPerService *map[uint]map[string]LastResult) error {
+	rows, err := db.Raw(`
+		SELECT DISTINCT ON (team_id, service_name) team_id, service_name, result, earned
+		FROM service_check_schemas
+		WHERE excluded IS NOT TRUE
+		ORDER BY team_id, service_name, round_id DESC
+	`).Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var teamID uint
+		var serviceName string
+		var result LastResult
+
+		if err := rows.Scan(&teamID, &serviceName, &result.Passed, &result.Earned); err != nil {
+			return err
+		}
+
+		if (*lastResultPerService)[teamID] == nil {
+			(*lastResultPerService)[teamID] = make(map[string]LastResult)
+		}
+		(*lastResultPerService)[teamID][serviceName] = result
+	}
+	return nil
+}
+
 type ServiceScoreData struct {
 	TeamID       uint
 	ServiceName  string
@@ -195,7 +253,7 @@ func GetServiceScores() ([]ServiceScoreData, error) {
 
 	// First get the total points per service per team
 	pointsRows, err := db.Raw(`
-		SELECT team_id, service_name, SUM(CASE WHEN result = true THEN points ELSE 0 END) as total_points
+		SELECT team_id, service_name, SUM(earned) as total_points
 		FROM service_check_schemas
 		GROUP BY team_id, service_name
 	`).Rows()
@@ -276,8 +334,16 @@ func GetServiceScores() ([]ServiceScoreData, error) {
 	return results, nil
 }
 
-func LoadSLAs(slaPerService *map[uint]map[string]int, slaThreshold int) error {
-	rows, err := db.Table("service_check_schemas").Select("team_id, service_name, result").Rows()
+// LoadSLAs rebuilds the SLA state of every check by replaying its rounds through
+// its rule, the same way the engine records them. Checks without a rule use
+// fallback. Rounds not run because a dependency was down only count when
+// slaOnDependencyDown is set.
+func LoadSLAs(slaPerService *map[uint]map[string]checks.SlaState, rules map[string]checks.SlaRule, fallback checks.SlaRule, slaOnDependencyDown bool) error {
+	query := db.Table("service_check_schemas").Select("team_id, service_name, result").Where("excluded IS NOT TRUE")
+	if !slaOnDependencyDown {
+		query = query.Where("state IS DISTINCT FROM ?", "dependency_down")
+	}
+	rows, err := query.Order("round_id").Rows()
 	if err != nil {
 		return err
 	}
@@ -293,14 +359,16 @@ func LoadSLAs(slaPerService *map[uint]map[string]int, slaThreshold int) error {
 		}
 
 		if (*slaPerService)[teamID] == nil {
-			(*slaPerService)[teamID] = make(map[string]int)
+			(*slaPerService)[teamID] = make(map[string]checks.SlaState)
 		}
 
-		if result {
-			(*slaPerService)[teamID][serviceName] = 0
-		} else {
-			(*slaPerService)[teamID][serviceName] = ((*slaPerService)[teamID][serviceName] + 1) % slaThreshold
+		rule, ok := rules[serviceName]
+		if !ok {
+			rule = fallback
 		}
+		state := (*slaPerService)[teamID][serviceName]
+		rule.Record(&state, result)
+		(*slaPerService)[teamID][serviceName] = state
 	}
 	return nil
 }
@@ -115,10 +115,18 @@ func UpdateTeam(teamID uint, identifier string, active bool) error {
 	return nil
 }
 
+func UpdateTeamToken(teamID uint, token string) error {
+	result := db.Table("team_schemas").Where("id = ?", teamID).Update("token", token)
+	if result.Error != nil {
+		return result.Error
+	}
+	return nil
+}
+
 func GetTeamScore(teamID uint) (int, int, int, error) {
 	// get service points
 	servicePoints := 0
-	rows, err := db.Raw("SELECT COALESCE(SUM(points), 0) FROM service_check_schemas WHERE team_id = ? and result = 't'", teamID).Rows()
+	rows, err := db.Raw("SELECT COALESCE(SUM(earned), 0) FROM service_check_schemas WHERE team_id = ?", teamID).Rows()
 	if err != nil {
 		return 0, 0, 0, err
 	}
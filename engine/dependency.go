@@ -0,0 +1,111 @@
+package engine
+
+import (
+	"slices"
+
+	"quotient/engine/checks"
+)
+
+type dependencyKey struct {
+	team    uint
+	service string
+}
+
+// blockedTask is a task that is not run because a check it depends on is down,
+// rootCause being the first check down in the chain
+type blockedTask struct {
+	task      Task
+	rootCause string
+}
+
+// dependencyGate holds back the tasks of a round whose dependencies have a task
+// for the same team that round, until every such dependency has reported. A
+// check whose dependency failed is not run at all and is recorded as dependency
+// down instead. Dependencies that were not scheduled don't hold anything back.
+type dependencyGate struct {
+	dependsOn map[string][]string
+	pending   map[dependencyKey]bool   // checks of the round that have not reported
+	down      map[dependencyKey]string // checks down this round -> root cause
+	held      []Task
+}
+
+func newDependencyGate(dependsOn map[string][]string, tasks []Task) *dependencyGate {
+	gate := &dependencyGate{
+		dependsOn: dependsOn,
+		pending:   make(map[dependencyKey]bool, len(tasks)),
+		down:      make(map[dependencyKey]string),
+	}
+	for _, task := range tasks {
+		gate.pending[dependencyKey{task.TeamID, task.ServiceName}] = true
+	}
+	for _, task := range tasks {
+		if gate.waiting(task) {
+			gate.held = append(gate.held, task)
+		}
+	}
+	return gate
+}
+
+// holds reports whether the task waits on its dependencies before it is sent out
+func (g *dependencyGate) holds(task Task) bool {
+	return slices.ContainsFunc(g.held, func(held Task) bool { return held.ID == task.ID })
+}
+
+// waiting reports whether a dependency of the task has yet to report this round
+func (g *dependencyGate) waiting(task Task) bool {
+	for _, dep := range g.dependsOn[task.ServiceName] {
+		if g.pending[dependencyKey{task.TeamID, dep}] {
+			return true
+		}
+	}
+	return false
+}
+
+// report records the result of a check, returning the held tasks now free to
+// run and those that won't run because a dependency is down
+func (g *dependencyGate) report(result checks.Result) ([]Task, []blockedTask) {
+	key := dependencyKey{result.TeamID, result.ServiceName}
+	delete(g.pending, key)
+	switch {
+	case result.State == checks.StateDependencyDown:
+		g.down[key] = result.RootCause
+	case !result.Status && result.State == "":
+		g.down[key] = result.ServiceName
+	}
+
+	// a blocked check is down too, which can release or block the checks after it
+	var ready []Task
+	var blocked []blockedTask
+	for released := true; released; {
+		released = false
+		for i := 0; i < len(g.held); i++ {
+			task := g.held[i]
+			if task.TeamID != result.TeamID || g.waiting(task) {
+				continue
+			}
+			g.held = slices.Delete(g.held, i, i+1)
+			i--
+			released = true
+
+			if root, ok := g.rootCause(task); ok {
+				key := dependencyKey{task.TeamID, task.ServiceName}
+				delete(g.pending, key)
+				g.down[key] = root
+				blocked = append(blocked, blockedTask{task: task, rootCause: root})
+			} else {
+				ready = append(ready, task)
+			}
+		}
+	}
+	return ready, blocked
+}
+
+// rootCause returns what the first dependency of the task that is down is blamed on
+func (g *dependencyGate) rootCause(task Task) (string, bool) {
+	for _, dep := range g.dependsOn[task.ServiceName] {
+		if root, ok := g.down[dependencyKey{task.TeamID, dep}]; ok {
+			return root, true
+		}
+	}
+	return "", false
+}
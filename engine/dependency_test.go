@@ -0,0 +1,84 @@
+package engine
+
+import (
+	"strconv"
+	"testing"
+
+	"quotient/engine/checks"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func dependencyTask(team uint, service string) Task {
+	return Task{ID: service + "-" + strconv.Itoa(int(team)), TeamID: team, ServiceName: service}
+}
+
+func TestDependencyGate(t *testing.T) {
+	dependsOn := map[string][]string{
+		"web01-web":  {"web01-ping"},
+		"web01-smtp": {"web01-dns"},
+		"web01-dns":  {"web01-ping"},
+	}
+	tasks := []Task{
+		dependencyTask(1, "web01-ping"),
+		dependencyTask(1, "web01-web"),
+		dependencyTask(1, "web01-dns"),
+		dependencyTask(1, "web01-smtp"),
+		dependencyTask(2, "web01-ping"),
+		dependencyTask(2, "web01-web"),
+	}
+	gate := newDependencyGate(dependsOn, tasks)
+
+	// only the checks without a dependency in the round go out first
+	var held []bool
+	for _, task := range tasks {
+		held = append(held, gate.holds(task))
+	}
+	assert.Equal(t, []bool{false, true, true, true, false, true}, held)
+
+	// a box that is down blocks every check depending on it, down the chain
+	ready, blocked := gate.report(checks.Result{TeamID: 1, ServiceName: "web01-ping", Status: false, Error: "host unreachable"})
+	assert.Empty(t, ready)
+	require.Len(t, blocked, 3)
+	for _, b := range blocked {
+		assert.Equal(t, uint(1), b.task.TeamID)
+		assert.Equal(t, "web01-ping", b.rootCause, "failures are blamed on the first check down in the chain")
+	}
+
+	// another team's box is up, its checks run
+	ready, blocked = gate.report(checks.Result{TeamID: 2, ServiceName: "web01-ping", Status: true})
+	assert.Empty(t, blocked)
+	assert.Equal(t, []Task{dependencyTask(2, "web01-web")}, ready)
+}
+
+func TestDependencyGateReleasesAfterEveryDependency(t *testing.T) {
+	dependsOn := map[string][]string{"web01-web": {"web01-ping", "web01-dns"}}
+	tasks := []Task{dependencyTask(1, "web01-ping"), dependencyTask(1, "web01-dns"), dependencyTask(1, "web01-web")}
+	gate := newDependencyGate(dependsOn, tasks)
+
+	ready, blocked := gate.report(checks.Result{TeamID: 1, ServiceName: "web01-ping", Status: true})
+	assert.Empty(t, ready, "still waiting on dns")
+	assert.Empty(t, blocked)
+
+	ready, blocked = gate.report(checks.Result{TeamID: 1, ServiceName: "web01-dns", Status: false})
+	assert.Empty(t, ready)
+	require.Len(t, blocked, 1)
+	assert.Equal(t, "web01-dns", blocked[0].rootCause)
+}
+
+func TestDependencyGateOnlyFailures(t *testing.T) {
+	dependsOn := map[string][]string{"web01-web": {"web01-ping"}}
+
+	// a dependency that was not scheduled holds nothing back
+	gate := newDependencyGate(dependsOn, []Task{dependencyTask(1, "web01-web")})
+	assert.False(t, gate.holds(dependencyTask(1, "web01-web")))
+
+	// a dependency that did not report or did not run is not blamed
+	for _, state := range []string{checks.StateNoResult, checks.StateSkipped} {
+		gate := newDependencyGate(dependsOn, []Task{dependencyTask(1, "web01-ping"), dependencyTask(1, "web01-web")})
+		ready, blocked := gate.report(checks.Result{TeamID: 1, ServiceName: "web01-ping", Status: false, State: state})
+		assert.Empty(t, blocked, state)
+		assert.Len(t, ready, 1, state)
+	}
+}
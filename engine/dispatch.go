@@ -0,0 +1,75 @@
+package engine
+
+import (
+	"cmp"
+	"context"
+	"log/slog"
+	"math/rand"
+	"slices"
+	"time"
+)
+
+// dispatchSlack is kept free at the end of the round on top of a check's own
+// timeout, for a runner to claim the task and report its result
+const dispatchSlack = 5 * time.Second
+
+// scheduledTask is a task waiting to be enqueued at an offset from the start of
+// the round
+type scheduledTask struct {
+	task   Task
+	offset time.Duration
+}
+
+// scheduleTasks shuffles the tasks of a round and picks when each is sent out
+// within window according to the dispatch mode. budgets holds the longest each
+// task may run; no task is sent so late that it could not finish before the round
+// ends. The result is ordered by offset.
+func scheduleTasks(mode string, window time.Duration, tasks []Task, budgets []time.Duration) []scheduledTask {
+	scheduled := make([]scheduledTask, len(tasks))
+	latest := make([]time.Duration, len(tasks))
+	for i, task := range tasks {
+		scheduled[i].task = task
+		latest[i] = max(window-budgets[i]-dispatchSlack, 0)
+	}
+	// #nosec G404 -- non-crypto randomization of check order
+	rand.Shuffle(len(scheduled), func(i, j int) {
+		scheduled[i], scheduled[j] = scheduled[j], scheduled[i]
+		latest[i], latest[j] = latest[j], latest[i]
+	})
+
+	for i := range scheduled {
+		switch mode {
+		case "random":
+			if latest[i] > 0 {
+				scheduled[i].offset = time.Duration(rand.Int63n(int64(latest[i]) + 1)) // #nosec G404 -- non-crypto randomization of check timing
+			}
+		case "spread":
+			scheduled[i].offset = min(window*time.Duration(i)/time.Duration(len(scheduled)), latest[i])
+		}
+	}
+
+	slices.SortStableFunc(scheduled, func(a, b scheduledTask) int {
+		return cmp.Compare(a.offset, b.offset)
+	})
+	return scheduled
+}
+
+// dispatchTasks enqueues every task once its offset from start has passed,
+// returning when all of them are enqueued or ctx ends. A task that could not be
+// enqueued is scored like any other check that never reported back.
+func (se *ScoringEngine) dispatchTasks(ctx context.Context, start time.Time, scheduled []scheduledTask) {
+	for _, s := range scheduled {
+		if wait := time.Until(start.Add(s.offset)); wait > 0 {
+			timer := time.NewTimer(wait)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case <-timer.C:
+			}
+		}
+		if err := se.Transport.EnqueueTask(ctx, s.task); err != nil {
+			slog.Error("failed to enqueue service task", "error", err, "team_id", s.task.TeamID, "service_name", s.task.ServiceName)
+		}
+	}
+}
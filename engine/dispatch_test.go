@@ -0,0 +1,108 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func dispatchFixture(n int, budget time.Duration) ([]Task, []time.Duration) {
+	tasks := make([]Task, n)
+	budgets := make([]time.Duration, n)
+	for i := range tasks {
+		tasks[i] = Task{ID: fmt.Sprintf("task-%d", i), TeamID: uint(i%4 + 1), ServiceName: fmt.Sprintf("svc-%d", i/4)}
+		budgets[i] = budget
+	}
+	return tasks, budgets
+}
+
+func TestScheduleTasksBurst(t *testing.T) {
+	tasks, budgets := dispatchFixture(20, 10*time.Second)
+	scheduled := scheduleTasks("burst", time.Minute, tasks, budgets)
+
+	require.Len(t, scheduled, len(tasks))
+	ids := make(map[string]bool)
+	for _, s := range scheduled {
+		assert.Zero(t, s.offset)
+		ids[s.task.ID] = true
+	}
+	assert.Len(t, ids, len(tasks), "every task should be scheduled exactly once")
+}
+
+func TestScheduleTasksStaysInsideTheRound(t *testing.T) {
+	window := time.Minute
+	budget := 20 * time.Second
+	latest := window - budget - dispatchSlack
+
+	for _, mode := range []string{"random", "spread"} {
+		t.Run(mode, func(t *testing.T) {
+			tasks, budgets := dispatchFixture(40, budget)
+			scheduled := scheduleTasks(mode, window, tasks, budgets)
+
+			require.Len(t, scheduled, len(tasks))
+			for i, s := range scheduled {
+				assert.GreaterOrEqual(t, s.offset, time.Duration(0))
+				assert.LessOrEqual(t, s.offset, latest, "a task must still be able to finish before the round ends")
+				if i > 0 {
+					assert.GreaterOrEqual(t, s.offset, scheduled[i-1].offset, "tasks should be ordered by offset")
+				}
+			}
+			assert.Greater(t, scheduled[len(scheduled)-1].offset, time.Duration(0), "tasks should not all go out at once")
+		})
+	}
+}
+
+func TestScheduleTasksSpreadEvenly(t *testing.T) {
+	tasks, budgets := dispatchFixture(4, 0)
+	scheduled := scheduleTasks("spread", 40*time.Second, tasks, budgets)
+
+	offsets := make([]time.Duration, 0, len(scheduled))
+	for _, s := range scheduled {
+		offsets = append(offsets, s.offset)
+	}
+	assert.Equal(t, []time.Duration{0, 10 * time.Second, 20 * time.Second, 30 * time.Second}, offsets)
+}
+
+func TestScheduleTasksWithoutRoomGoOutImmediately(t *testing.T) {
+	tasks, budgets := dispatchFixture(5, 2*time.Minute)
+	for _, mode := range []string{"random", "spread"} {
+		for _, s := range scheduleTasks(mode, time.Minute, tasks, budgets) {
+			assert.Zero(t, s.offset, "%s: a task longer than the round should be sent at once", mode)
+		}
+	}
+}
+
+func TestDispatchTasksStopsWithTheRound(t *testing.T) {
+	transport := NewMemoryTransport()
+	se := &ScoringEngine{Transport: transport}
+	deadline := time.Now().Add(time.Minute)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		se.dispatchTasks(ctx, time.Now(), []scheduledTask{
+			{task: Task{ID: "now", Deadline: deadline}},
+			{task: Task{ID: "later", Deadline: deadline}, offset: time.Hour},
+		})
+		close(done)
+	}()
+
+	require.Eventually(t, func() bool {
+		task, _, err := transport.ClaimTask(context.Background(), "runner-1", nil, time.Minute, 10*time.Millisecond)
+		return err == nil && task != nil && task.ID == "now"
+	}, time.Second, 10*time.Millisecond)
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("dispatch should stop when the round ends")
+	}
+	task, _, err := transport.ClaimTask(context.Background(), "runner-1", nil, time.Minute, 10*time.Millisecond)
+	require.NoError(t, err)
+	assert.Nil(t, task, "tasks due after the round ended should not be sent")
+}
@@ -3,10 +3,10 @@ package engine
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
 	"math/rand"
-	"os"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -17,6 +17,7 @@ import (
 	"quotient/engine/config"
 	"quotient/engine/db"
 
+	"github.com/google/uuid"
 	"github.com/redis/go-redis/v9"
 )
 
@@ -25,13 +26,24 @@ type ScoringEngine struct {
 	CredentialsMutex      map[uint]*sync.Mutex
 	UptimePerService      map[uint]map[string]db.Uptime
 	uptimeMu              sync.RWMutex
-	SlaPerService         map[uint]map[string]int
+	SlaPerService         map[uint]map[string]checks.SlaState
 	EnginePauseWg         *sync.WaitGroup
 	IsEnginePaused        bool
 	CurrentRound          uint
 	NextRoundStartTime    time.Time
 	CurrentRoundStartTime time.Time
-	RedisClient           *redis.Client
+	Transport             Transport
+
+	// LastResultPerService is how each check scored the last round it ran in,
+	// carried into the rounds an interval check skips
+	LastResultPerService map[uint]map[string]db.LastResult
+	// lastRunPerService is when each check last ran, for checks with an interval
+	lastRunPerService map[string]time.Time
+	// runsPerService counts the rounds each check ran in, for rotating through its items
+	runsPerService map[string]uint
+
+	// RedisClient is nil when the runners are embedded and Redis is not used
+	RedisClient *redis.Client
 
 	// Concurrency control for materialized view refresh
 	Refreshing atomic.Bool
@@ -46,25 +58,34 @@ func NewEngine(conf *config.ConfigSettings, configPath string) *ScoringEngine {
 		panic(fmt.Sprintf("Failed to validate initial config: %v", err))
 	}
 
-	redisAddr := os.Getenv("REDIS_ADDR")
-	if redisAddr == "" {
-		redisAddr = "quotient_redis:6379"
-	}
-	rdb := redis.NewClient(&redis.Options{
-		Addr:     redisAddr,
-		Password: os.Getenv("REDIS_PASSWORD"),
-	})
-	if err := rdb.Ping(context.Background()).Err(); err != nil {
-		panic(fmt.Sprintf("Failed to connect to Redis: %v", err))
-	}
-
 	se := &ScoringEngine{
-		Config:           conf,
-		CredentialsMutex: make(map[uint]*sync.Mutex),
-		UptimePerService: make(map[uint]map[string]db.Uptime),
-		SlaPerService:    make(map[uint]map[string]int),
-		RedisClient:      rdb,
-		configPath:       configPath,
+		Config:               conf,
+		CredentialsMutex:     make(map[uint]*sync.Mutex),
+		UptimePerService:     make(map[uint]map[string]db.Uptime),
+		SlaPerService:        make(map[uint]map[string]checks.SlaState),
+		LastResultPerService: make(map[uint]map[string]db.LastResult),
+		lastRunPerService:    make(map[string]time.Time),
+		runsPerService:       make(map[string]uint),
+		configPath:           configPath,
+	}
+
+	// embedded runners get their tasks in process, so the engine can run without Redis
+	if n := EmbeddedRunners(); n > 0 {
+		slog.Info("Running checks with embedded runners, Redis is not used", "runners", n)
+		se.Transport = NewMemoryTransport()
+	} else {
+		rdb := NewRedisClient()
+		if err := rdb.Ping(context.Background()).Err(); err != nil {
+			panic(fmt.Sprintf("Failed to connect to Redis: %v", err))
+		}
+		if !SigningEnabled() {
+			slog.Warn("TASK_SIGNING_KEY is not set, tasks are enqueued unsigned and anyone who can write to Redis can run commands on the runners")
+		}
+		if !EncryptionEnabled() {
+			slog.Warn("TASK_ENCRYPTION_KEY is not set, team credentials are sent to runners in plaintext")
+		}
+		se.RedisClient = rdb
+		se.Transport = NewRedisTransport(rdb)
 	}
 
 	// Start watching config file for changes
@@ -86,9 +107,12 @@ func (se *ScoringEngine) Start() {
 	if err := db.LoadUptimes(&se.UptimePerService); err != nil {
 		slog.Error("failed to load uptimes", "error", err)
 	}
+	if err := db.LoadLastResults(&se.LastResultPerService); err != nil {
+		slog.Error("failed to load last check results", "error", err)
+	}
 	se.uptimeMu.Unlock()
 
-	if err := db.LoadSLAs(&se.SlaPerService, se.Config.MiscSettings.SlaThreshold); err != nil {
+	if err := db.LoadSLAs(&se.SlaPerService, se.Config.SlaRules(), se.Config.SlaRule(), se.Config.MiscSettings.SlaOnDependencyDown); err != nil {
 		slog.Error("failed to load SLAs", "error", err)
 	}
 
@@ -98,6 +122,12 @@ func (se *ScoringEngine) Start() {
 		slog.Error("failed to load credential files into teams", "error", err)
 	}
 
+	if se.Config.RequiredSettings.EventType == "koth" {
+		if err := se.EnsureKothTokens(); err != nil {
+			slog.Error("failed to generate koth tokens", "error", err)
+		}
+	}
+
 	// start paused if configured
 	se.IsEnginePaused = false
 	se.EnginePauseWg = &sync.WaitGroup{}
@@ -108,19 +138,8 @@ func (se *ScoringEngine) Start() {
 
 	se.NextRoundStartTime = time.Time{}
 
-	redisAddr := os.Getenv("REDIS_ADDR")
-	if redisAddr == "" {
-		redisAddr = "quotient_redis:6379"
-	}
-
-	rdb := redis.NewClient(&redis.Options{
-		Addr:     redisAddr,
-		Password: os.Getenv("REDIS_PASSWORD"),
-	})
-
-	events := rdb.Subscribe(context.Background(), "events")
-	defer events.Close()
-	eventsChannel := events.Channel()
+	eventsChannel, closeEvents := se.Transport.Subscribe(context.Background())
+	defer closeEvents()
 
 	// engine loop
 	go func() {
@@ -128,9 +147,9 @@ func (se *ScoringEngine) Start() {
 			slog.Info("Queueing up for round", "round", se.CurrentRound)
 			se.EnginePauseWg.Wait()
 			select {
-			case msg := <-eventsChannel:
-				slog.Info("Received message", "message", msg.Payload)
-				if msg.Payload == "reset" {
+			case event := <-eventsChannel:
+				slog.Info("Received message", "message", event)
+				if event == "reset" {
 					slog.Info("Engine loop reset event received while waiting, quitting...")
 					return
 				} else {
@@ -159,39 +178,28 @@ func (se *ScoringEngine) Start() {
 				slog.Info(fmt.Sprintf("Round %d complete", se.CurrentRound))
 				se.CurrentRound++
 
-				se.RedisClient.Publish(context.Background(), "events", "round_finish")
+				se.Transport.Publish(context.Background(), "round_finish")
 				slog.Info(fmt.Sprintf("Round %d will start in %s, sleeping...", se.CurrentRound, time.Until(se.NextRoundStartTime).String()))
 				time.Sleep(time.Until(se.NextRoundStartTime))
 			}
 		}
 	}()
-	waitForReset()
+	se.waitForReset()
 	slog.Info("Restarting scoring...")
 }
 
-func waitForReset() {
+func (se *ScoringEngine) waitForReset() {
 	// wait for a signal to reset the engine
 	// this will block until the engine is reset
 	// this is a blocking call
 	// the engine will be reset and the loop will start again
 
-	redisAddr := os.Getenv("REDIS_ADDR")
-	if redisAddr == "" {
-		redisAddr = "quotient_redis:6379"
-	}
-
-	rdb := redis.NewClient(&redis.Options{
-		Addr:     redisAddr,
-		Password: os.Getenv("REDIS_PASSWORD"),
-	})
+	eventsChannel, closeEvents := se.Transport.Subscribe(context.Background())
+	defer closeEvents()
 
-	events := rdb.Subscribe(context.Background(), "events")
-	defer events.Close()
-	eventsChannel := events.Channel()
-
-	for msg := range eventsChannel {
-		slog.Info("Received message", "message", msg.Payload)
-		if msg.Payload == "reset" {
+	for event := range eventsChannel {
+		slog.Info("Received message", "message", event)
+		if event == "reset" {
 			slog.Info("Reset event received, quitting...")
 			return
 		} else {
@@ -222,59 +230,46 @@ func (se *ScoringEngine) GetActiveTasks() (map[string]any, error) {
 		"success":     []any{},
 		"failed":      []any{},
 		"all_runners": []any{},
+		"runners":     []RunnerInfo{},
 	}
 
-	// Get all task keys using a single pattern
-	allKeys, err := se.RedisClient.Keys(ctx, "task:*").Result()
+	// Use map as a set to track unique runner IDs
+	runnersSet := make(map[string]struct{})
+
+	// Registered runners, including ones that stopped heartbeating
+	registered, err := se.Transport.Runners(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get tasks: %w", err)
+		return nil, err
 	}
-
-	// Skip if no keys found
-	if len(allKeys) == 0 {
-		return result, nil
+	result["runners"] = registered
+	for _, r := range registered {
+		runnersSet[r.ID] = struct{}{}
 	}
 
-	// Use a single pipeline to get all task data in one round-trip
-	pipe := se.RedisClient.Pipeline()
-	cmds := make(map[string]*redis.StringCmd, len(allKeys))
-
-	// Queue up all GET commands at once
-	for _, key := range allKeys {
-		cmds[key] = pipe.Get(ctx, key)
+	// Tasks claimed by a runner but not yet acknowledged are running
+	running, err := se.Transport.RunningTasks(ctx)
+	if err != nil {
+		return nil, err
 	}
-
-	// Execute the pipeline
-	_, err = pipe.Exec(ctx)
-	if err != nil && err != redis.Nil {
-		return nil, fmt.Errorf("failed to execute pipeline: %w", err)
+	for _, task := range running {
+		runnersSet[task.RunnerID] = struct{}{}
+		result["running"] = append(result["running"].([]any), task)
 	}
 
-	// Use map as a set to track unique runner IDs
-	runnersSet := make(map[string]struct{})
-
-	// Process all results
-	for _, cmd := range cmds {
-		value, err := cmd.Result()
-		if err != nil {
-			continue // Skip if we can't get the task details
-		}
-
-		// Parse the value as JSON directly into a checks.Result
-		var taskStatus checks.Result
-		if err := json.Unmarshal([]byte(value), &taskStatus); err != nil {
-			continue // Skip if we can't parse the JSON
-		}
-
+	// Results pushed in the last few minutes are shown as recently completed
+	recent, err := se.Transport.RecentResults(ctx, time.Now().Add(-3*time.Minute))
+	if err != nil {
+		return nil, err
+	}
+	for _, taskStatus := range recent {
 		// Add runner ID to set if available
 		if taskStatus.RunnerID != "" {
 			runnersSet[taskStatus.RunnerID] = struct{}{}
 		}
 
-		// Categorize by status - directly using the status as map key for cleaner code
-		if statusKey := taskStatus.StatusText; statusKey == "running" || statusKey == "success" || statusKey == "failed" {
-			result[statusKey] = append(result[statusKey].([]any), taskStatus)
-		}
+		statusKey := map[bool]string{true: "success", false: "failed"}[taskStatus.Status]
+		taskStatus.StatusText = statusKey
+		result[statusKey] = append(result[statusKey].([]any), taskStatus)
 	}
 
 	// Convert runners set to slice in one go
@@ -301,7 +296,7 @@ func (se *ScoringEngine) ResumeEngine() {
 
 // ResetScores resets the engine to the initial state and stops the engine
 func (se *ScoringEngine) ResetScores() error {
-	slog.Info("Resetting scores and clearing Redis queues")
+	slog.Info("Resetting scores and clearing task queues")
 
 	// Reset the database
 	if err := db.ResetScores(); err != nil {
@@ -309,62 +304,265 @@ func (se *ScoringEngine) ResetScores() error {
 		return fmt.Errorf("failed to reset scores: %v", err)
 	}
 
-	// Flush Redis queues
-	ctx := context.Background()
-	keysToDelete := []string{"tasks", "results"}
-	for _, key := range keysToDelete {
-		if err := se.RedisClient.Del(ctx, key).Err(); err != nil {
-			slog.Error("Failed to clear Redis queue", "queue", key, "error", err)
-			return fmt.Errorf("failed to clear Redis queue %s: %v", key, err)
-		}
+	// Flush task queues
+	if err := se.Transport.Reset(context.Background()); err != nil {
+		slog.Error("Failed to clear task queues", "error", err)
+		return fmt.Errorf("failed to clear task queues: %v", err)
 	}
 
 	// Reset engine state
-	se.RedisClient.Publish(context.Background(), "events", "reset")
+	se.Transport.Publish(context.Background(), "reset")
 
 	se.CurrentRound = 1
 	se.uptimeMu.Lock()
 	se.UptimePerService = make(map[uint]map[string]db.Uptime)
+	se.LastResultPerService = make(map[uint]map[string]db.LastResult)
 	se.uptimeMu.Unlock()
-	se.SlaPerService = make(map[uint]map[string]int)
-	slog.Info("Scores reset and Redis queues cleared successfully")
+	se.SlaPerService = make(map[uint]map[string]checks.SlaState)
+	se.lastRunPerService = make(map[string]time.Time)
+	se.runsPerService = make(map[string]uint)
+	slog.Info("Scores reset and task queues cleared successfully")
+
+	return nil
+}
 
+// EnsureKothTokens gives every team without an ownership token a random one
+func (se *ScoringEngine) EnsureKothTokens() error {
+	teams, err := db.GetTeams()
+	if err != nil {
+		return fmt.Errorf("failed to get teams: %v", err)
+	}
+	for _, team := range teams {
+		if team.Token != "" {
+			continue
+		}
+		if err := db.UpdateTeamToken(team.ID, uuid.New().String()); err != nil {
+			return fmt.Errorf("failed to set token for team %d: %v", team.ID, err)
+		}
+	}
 	return nil
 }
 
 // perform a round of koth
 func (se *ScoringEngine) koth() error {
-	// enginePauseWg.Wait()
+	se.scheduleNextRound()
+
+	eventsChannel, closeEvents := se.Transport.Subscribe(context.Background())
+	defer closeEvents()
+
+	teams, err := db.GetTeams()
+	if err != nil {
+		slog.Error("failed to get teams:", "error", err)
+		return err
+	}
+
+	// only active teams can take ownership of a box
+	tokens := make(map[uint]string)
+	for _, team := range teams {
+		if team.Active && team.Token != "" {
+			tokens[team.ID] = team.Token
+		}
+	}
+	if len(tokens) == 0 {
+		slog.Warn("No active teams have ownership tokens, no box can be owned this round", "round", se.CurrentRound)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Until(se.NextRoundStartTime))
+	defer cancel()
+
+	se.warnIfNoHealthyRunners(ctx)
+
+	// Clear any stale tasks from previous rounds before enqueuing new ones
+	se.Transport.ClearStaleTasks(ctx)
+
+	// 1) Enqueue one task per koth check; the boxes are shared so there is no team
+	probes := 0
+	tracker := newRoundTracker()
+	for _, r := range se.Config.AllChecks() {
+		k, ok := r.(*checks.Koth)
+		if !ok || !k.Runnable() {
+			continue
+		}
+		check := *k
+		check.Tokens = tokens
+		data, err := json.Marshal(&check)
+		if err != nil {
+			slog.Error("failed to marshal koth check definition", "error", err)
+			continue
+		}
+
+		task := Task{
+			ID:          uuid.New().String(),
+			ServiceType: check.GetType(),
+			ServiceName: check.GetName(),
+			RoundID:     se.CurrentRound,
+			Deadline:    se.NextRoundStartTime,
+			Attempts:    check.GetAttempts(),
+			CheckData:   data,
+			Tags:        check.GetTags(),
+		}
+		if err := se.Transport.EnqueueTask(ctx, task); err != nil {
+			slog.Error("failed to enqueue koth task", "error", err)
+			continue
+		}
+		tracker.add(task.ID, checks.Result{
+			ServiceName: check.GetName(),
+			ServiceType: check.GetType(),
+			RoundID:     se.CurrentRound,
+		})
+		probes++
+	}
+	slog.Info("Enqueued koth probes", "count", probes)
 
-	// do koth stuff
+	// 2) Collect results from the runners
+	results, err := se.collectResults(ctx, eventsChannel, tracker, newDependencyGate(nil, nil))
+	if err != nil {
+		return err
+	}
+	// a box nobody could probe is unowned for the round
+	results = append(results, tracker.missing()...)
+
+	// 3) Award each box to its owner
+	se.processKothResults(results)
 	return nil
 }
 
-// perform a round of rvb
-func (se *ScoringEngine) rvb() error {
-	// reassign the next round start time with jitter
+func (se *ScoringEngine) processKothResults(results []checks.Result) {
+	if len(results) == 0 {
+		slog.Warn("No koth results collected for round", "round", se.CurrentRound)
+		return
+	}
+
+	dbResults := []db.ServiceCheckSchema{}
+	ownerships := []db.KothOwnershipSchema{}
+	for _, result := range results {
+		ownership := db.KothOwnershipSchema{
+			RoundID:     uint(se.CurrentRound),
+			ServiceName: sanitizeDBString(result.ServiceName),
+			Error:       sanitizeDBString(result.Error),
+			Debug:       sanitizeDBString(result.Debug),
+		}
+		if result.Status && result.OwnerID != 0 {
+			ownership.TeamID = result.OwnerID
+			ownership.Points = result.Points
+			dbResults = append(dbResults, db.ServiceCheckSchema{
+				TeamID:      result.OwnerID,
+				RoundID:     uint(se.CurrentRound),
+				ServiceName: sanitizeDBString(result.ServiceName),
+				Points:      result.Points,
+				Earned:      result.Points,
+				Result:      true,
+				Debug:       sanitizeDBString(result.Debug),
+			})
+		}
+		ownerships = append(ownerships, ownership)
+	}
+
+	round := db.RoundSchema{
+		ID:        uint(se.CurrentRound),
+		StartTime: se.CurrentRoundStartTime,
+		Checks:    dbResults,
+	}
+	if _, err := db.CreateRound(round); err != nil {
+		slog.Error("failed to create round:", "round", se.CurrentRound, "error", err)
+		return
+	}
+	if err := db.CreateKothOwnerships(ownerships); err != nil {
+		slog.Error("failed to save koth ownership", "round", se.CurrentRound, "error", err)
+	}
+
+	slog.Debug("Successfully processed koth results for round", "round", se.CurrentRound, "owned", len(dbResults), "total", len(ownerships))
+	se.refreshScores()
+}
+
+// scheduleNextRound reassigns the next round start time with jitter
+func (se *ScoringEngine) scheduleNextRound() {
 	// double the jitter and subtract it to get a random number between -jitter and jitter
 	randomJitter := rand.Intn(2*se.Config.MiscSettings.Jitter) - se.Config.MiscSettings.Jitter // #nosec G404 -- non-crypto randomization for timing jitter
 	jitter := time.Duration(randomJitter) * time.Second
 	se.NextRoundStartTime = time.Now().Add(time.Duration(se.Config.MiscSettings.Delay) * time.Second).Add(jitter)
 
 	slog.Info(fmt.Sprintf("round should take %s", time.Until(se.NextRoundStartTime).String()))
+}
+
+// collectResults waits for the results of the current round until every task in
+// the tracker has reported or the round ends, returning whatever arrived. It only
+// returns an error on reset.
+func (se *ScoringEngine) collectResults(ctx context.Context, eventsChannel <-chan string, tracker *roundTracker, gate *dependencyGate) ([]checks.Result, error) {
+	expected := tracker.remaining()
+	results := make([]checks.Result, 0, expected)
+	timeoutCtx, cancel := context.WithTimeout(ctx, time.Until(se.NextRoundStartTime))
+	defer cancel()
+
+	i := 0
+	for i < expected {
+		select {
+		case event := <-eventsChannel:
+			slog.Info("Received message", "message", event)
+			if event == "reset" {
+				slog.Info("Reset event received, quitting...")
+				return nil, fmt.Errorf("reset event received")
+			} else {
+				continue
+			}
+		default:
+			// wait in short steps so a reset is noticed before the round ends
+			remaining := time.Until(se.NextRoundStartTime)
+			if remaining <= 0 {
+				slog.Warn("Timeout waiting for results", "remaining", expected-i, "collected", i, "expected", expected)
+				return results, nil
+			}
+			result, err := se.Transport.ReadResult(timeoutCtx, min(remaining, time.Second))
+			if errors.Is(err, ErrNoResult) {
+				continue
+			} else if errors.Is(err, errMalformedResult) {
+				slog.Error("Failed to unmarshal check result", "error", err)
+				continue
+			} else if err != nil {
+				// Check if the timeout context has expired
+				if timeoutCtx.Err() != nil {
+					slog.Warn("Round deadline exceeded while waiting for results", "remaining", expected-i, "collected", i, "expected", expected, "error", err)
+					return results, nil
+				}
+				slog.Error("Failed to fetch results:", "error", err)
+				time.Sleep(2 * time.Second)
+				continue
+			}
+
+			if result.RoundID != se.CurrentRound {
+				slog.Warn("Ignoring out of round result", "receivedRound", result.RoundID, "currentRound", se.CurrentRound)
+				continue
+			}
+			if err := tracker.accept(result); err != nil {
+				slog.Warn("Rejecting check result", "reason", err, "task_id", result.TaskID, "runner_id", result.RunnerID, "team_id", result.TeamID, "service_name", result.ServiceName, "round", se.CurrentRound)
+				continue
+			}
+			results = append(results, result)
+			i++
+			slog.Debug("service check finished", "round_id", result.RoundID, "team_id", result.TeamID, "service_name", result.ServiceName, "result", result.Status, "debug", result.Debug, "error", result.Error)
 
-	//
-	redisAddr := os.Getenv("REDIS_ADDR")
-	if redisAddr == "" {
-		redisAddr = "quotient_redis:6379"
+			// checks waiting on this one run now, or not at all when it is down
+			ready, blocked := gate.report(result)
+			for _, task := range ready {
+				if err := se.Transport.EnqueueTask(ctx, task); err != nil {
+					slog.Error("failed to enqueue service task", "error", err, "team_id", task.TeamID, "service_name", task.ServiceName)
+				}
+			}
+			for _, b := range blocked {
+				results = append(results, tracker.dependencyDown(b.task.ID, b.rootCause))
+				i++
+			}
+		}
 	}
+	return results, nil
+}
 
-	rdb := redis.NewClient(&redis.Options{
-		Addr:     redisAddr,
-		Password: os.Getenv("REDIS_PASSWORD"),
-	})
+// perform a round of rvb
+func (se *ScoringEngine) rvb() error {
+	se.scheduleNextRound()
 
-	events := rdb.Subscribe(context.Background(), "events")
-	defer events.Close()
-	eventsChannel := events.Channel()
-	//
+	eventsChannel, closeEvents := se.Transport.Subscribe(context.Background())
+	defer closeEvents()
 
 	// do rvb stuff
 	teams, err := db.GetTeams()
@@ -374,6 +572,7 @@ func (se *ScoringEngine) rvb() error {
 	}
 
 	runners := 0
+	tracker := newRoundTracker()
 	ctx, cancel := context.WithTimeout(context.Background(), time.Until(se.NextRoundStartTime))
 	defer cancel()
 
@@ -388,20 +587,35 @@ func (se *ScoringEngine) rvb() error {
 		slog.Debug("Box configuration", "name", box.Name, "runners", len(box.Runners))
 	}
 
+	se.warnIfNoHealthyRunners(ctx)
+
 	// Clear any stale tasks from previous rounds before enqueuing new ones
-	staleTasks := se.RedisClient.LLen(ctx, "tasks").Val()
-	if staleTasks > 0 {
-		slog.Warn("Clearing stale tasks from queue", "count", staleTasks, "round", se.CurrentRound)
-		se.RedisClient.Del(ctx, "tasks")
+	se.Transport.ClearStaleTasks(ctx)
+
+	// checks with an interval only run in some rounds
+	now := time.Now()
+	due := make(map[string]bool)
+	for _, r := range allRunners {
+		if !r.Runnable() || r.GetType() == "Koth" {
+			continue
+		}
+		due[r.GetName()] = checkDue(r, se.CurrentRound, now, se.lastRunPerService[r.GetName()], time.Duration(se.Config.MiscSettings.Delay)*time.Second)
+		if due[r.GetName()] {
+			se.lastRunPerService[r.GetName()] = now
+			se.runsPerService[r.GetName()]++
+		}
 	}
 
 	// 1) Enqueue
+	var tasks []Task
+	var budgets []time.Duration // longest each task may run
+	var skipped []checks.Result
 	for _, team := range teams {
 		if !team.Active {
 			continue
 		}
 		for _, r := range allRunners {
-			if !r.Runnable() {
+			if !r.Runnable() || r.GetType() == "Koth" {
 				continue
 			}
 			enabled, err := db.IsTeamServiceEnabled(team.ID, r.GetName())
@@ -412,6 +626,10 @@ func (se *ScoringEngine) rvb() error {
 			if !enabled {
 				continue
 			}
+			if !due[r.GetName()] {
+				skipped = append(skipped, skippedResult(r, team.ID, se.CurrentRound))
+				continue
+			}
 			// serialize the entire check definition to JSON
 			data, err := json.Marshal(r)
 			if err != nil {
@@ -420,6 +638,7 @@ func (se *ScoringEngine) rvb() error {
 			}
 
 			task := Task{
+				ID:             uuid.New().String(),
 				TeamID:         team.ID,
 				TeamIdentifier: team.Identifier,
 				ServiceType:    r.GetType(),
@@ -428,6 +647,8 @@ func (se *ScoringEngine) rvb() error {
 				Deadline:       se.NextRoundStartTime,
 				Attempts:       r.GetAttempts(),
 				CheckData:      data, // the entire specialized struct
+				Tags:           r.GetTags(),
+				Run:            se.runsPerService[r.GetName()],
 			}
 
 			// Populate credentials if check needs them
@@ -452,73 +673,55 @@ func (se *ScoringEngine) rvb() error {
 				}
 			}
 
-			payload, err := json.Marshal(task)
-			if err != nil {
-				slog.Error("failed to marshal service task", "error", err)
-				continue
-			}
-			se.RedisClient.RPush(ctx, "tasks", payload)
+			tasks = append(tasks, task)
+			budgets = append(budgets, time.Duration(r.GetTimeout()*max(r.GetAttempts(), 1))*time.Second)
+			tracker.add(task.ID, checks.Result{
+				TeamID:      team.ID,
+				ServiceName: r.GetName(),
+				ServiceType: r.GetType(),
+				RoundID:     se.CurrentRound,
+				Points:      r.GetPoints(),
+			})
 			runners++
 		}
 	}
-	slog.Info("Enqueued checks", "count", runners)
-
-	// 2) Collect results from Redis
-	results := make([]checks.Result, 0, runners)
-	timeoutCtx, cancel := context.WithTimeout(ctx, time.Until(se.NextRoundStartTime))
-	defer cancel()
 
-	i := 0
-COLLECTION:
-	for i < runners {
-		select {
-		case msg := <-eventsChannel:
-			slog.Info("Received message", "message", msg.Payload)
-			if msg.Payload == "reset" {
-				slog.Info("Reset event received, quitting...")
-				return fmt.Errorf("reset event received")
-			} else {
-				continue
-			}
-		default:
-			val, err := se.RedisClient.BLPop(timeoutCtx, time.Until(se.NextRoundStartTime), "results").Result()
-			if err == redis.Nil {
-				slog.Warn("Timeout waiting for results", "remaining", runners-i, "collected", i, "expected", runners)
-				results = []checks.Result{}
-				break COLLECTION
-			} else if err != nil {
-				// Check if the timeout context has expired
-				if timeoutCtx.Err() != nil {
-					slog.Warn("Round deadline exceeded while waiting for results", "remaining", runners-i, "collected", i, "expected", runners, "error", err)
-					results = []checks.Result{}
-					break COLLECTION
-				}
-				slog.Error("Failed to fetch results from Redis:", "error", err)
-				time.Sleep(2 * time.Second)
-				continue
-			}
+	// checks wait for the checks they depend on to report before they are sent out
+	dependsOn := make(map[string][]string)
+	for _, r := range allRunners {
+		if deps := r.GetDependsOn(); len(deps) > 0 {
+			dependsOn[r.GetName()] = deps
+		}
+	}
+	gate := newDependencyGate(dependsOn, tasks)
+	var ready []Task
+	var readyBudgets []time.Duration
+	for i, task := range tasks {
+		if !gate.holds(task) {
+			ready = append(ready, task)
+			readyBudgets = append(readyBudgets, budgets[i])
+		}
+	}
 
-			// val[0] = "results", val[1] = JSON checks.Result
-			if len(val) < 2 {
-				slog.Warn("Malformed result from Redis", "val", val)
-				continue
-			}
-			raw := val[1]
+	// the burst is enqueued before collecting, later dispatches go out while results come in
+	scheduled := scheduleTasks(se.Config.MiscSettings.Dispatch, time.Until(se.NextRoundStartTime), ready, readyBudgets)
+	if se.Config.MiscSettings.Dispatch == "burst" {
+		se.dispatchTasks(ctx, time.Now(), scheduled)
+	} else {
+		go se.dispatchTasks(ctx, time.Now(), scheduled)
+	}
+	slog.Info("Scheduled checks", "count", runners, "dispatch", se.Config.MiscSettings.Dispatch)
 
-			var result checks.Result
-			if err := json.Unmarshal([]byte(raw), &result); err != nil {
-				slog.Error("Failed to unmarshal check result", "error", err)
-				continue
-			}
-			if result.RoundID != se.CurrentRound {
-				slog.Warn("Ignoring out of round result", "receivedRound", result.RoundID, "currentRound", se.CurrentRound)
-				continue
-			}
-			results = append(results, result)
-			i++
-			slog.Debug("service check finished", "round_id", result.RoundID, "team_id", result.TeamID, "service_name", result.ServiceName, "result", result.Status, "debug", result.Debug, "error", result.Error)
-		}
+	// 2) Collect results from the runners
+	results, err := se.collectResults(ctx, eventsChannel, tracker, gate)
+	if err != nil {
+		return err
 	}
+	if missing := tracker.missing(); len(missing) > 0 {
+		slog.Warn("Recording checks with no result", "count", len(missing), "policy", se.Config.MiscSettings.NoResultPolicy, "round", se.CurrentRound)
+		results = append(results, missing...)
+	}
+	results = append(results, skipped...)
 
 	// 3) Process all collected results
 	se.processCollectedResults(results)
@@ -542,17 +745,59 @@ func (se *ScoringEngine) processCollectedResults(results []checks.Result) {
 	}
 
 	dbResults := []db.ServiceCheckSchema{}
-
-	for _, result := range results {
+	dbAttempts := []db.CheckAttemptSchema{}
+	excluded := make([]bool, len(results))
+	earned := make([]int, len(results))
+	scrubbers := credentialScrubbers(results)
+
+	for i, result := range results {
+		if result.State == checks.StateNoResult {
+			switch se.Config.MiscSettings.NoResultPolicy {
+			case "up":
+				results[i].Status = true
+				earned[i] = result.Points
+			case "exclude":
+				excluded[i] = true
+			}
+		} else if result.State == checks.StateSkipped {
+			// a skipped round neither counts as a failure nor as a check, it scores
+			// like the check's last run
+			excluded[i] = true
+			last := se.lastResult(result.TeamID, result.ServiceName)
+			results[i].Status = last.Passed
+			earned[i] = last.Earned
+		} else {
+			earned[i] = results[i].EarnedPoints()
+		}
+		scrub := scrubbers[result.TeamID]
 		dbResults = append(dbResults, db.ServiceCheckSchema{
 			TeamID:      result.TeamID,
 			RoundID:     uint(se.CurrentRound),
 			ServiceName: sanitizeDBString(result.ServiceName),
 			Points:      result.Points,
-			Result:      result.Status,
-			Error:       sanitizeDBString(result.Error),
-			Debug:       sanitizeDBString(result.Debug),
+			Earned:      earned[i],
+			Result:      results[i].Status,
+			Error:       sanitizeDBString(scrub.Replace(result.Error)),
+			Debug:       sanitizeDBString(scrub.Replace(result.Debug)),
+			State:       result.State,
+			Excluded:    excluded[i],
+			RootCause:   result.RootCause,
+			Item:        sanitizeDBString(scrub.Replace(result.Item)),
 		})
+		for _, attempt := range result.Attempts {
+			dbAttempts = append(dbAttempts, db.CheckAttemptSchema{
+				TeamID:      result.TeamID,
+				RoundID:     uint(se.CurrentRound),
+				ServiceName: sanitizeDBString(result.ServiceName),
+				Attempt:     attempt.Number,
+				MaxAttempts: result.MaxAttempts,
+				Result:      attempt.Status,
+				Error:       sanitizeDBString(scrub.Replace(attempt.Error)),
+				Debug:       sanitizeDBString(scrub.Replace(attempt.Debug)),
+				DurationMs:  attempt.DurationMs,
+				RunnerID:    sanitizeDBString(attempt.RunnerID),
+			})
+		}
 	}
 
 	if len(dbResults) == 0 {
@@ -565,14 +810,19 @@ func (se *ScoringEngine) processCollectedResults(results []checks.Result) {
 		ID:        uint(se.CurrentRound),
 		StartTime: se.CurrentRoundStartTime,
 		Checks:    dbResults,
+		Attempts:  dbAttempts,
 	}
 	if _, err := db.CreateRound(round); err != nil {
 		slog.Error("failed to create round:", "round", se.CurrentRound, "error", err)
 		return
 	}
 
+	slaRules := se.Config.SlaRules()
 	se.uptimeMu.Lock()
-	for _, result := range results {
+	for i, result := range results {
+		if excluded[i] {
+			continue
+		}
 		// Update uptime and SLA maps
 		if _, ok := se.UptimePerService[result.TeamID]; !ok {
 			se.UptimePerService[result.TeamID] = make(map[string]db.Uptime)
@@ -581,33 +831,41 @@ func (se *ScoringEngine) processCollectedResults(results []checks.Result) {
 			se.UptimePerService[result.TeamID][result.ServiceName] = db.Uptime{}
 		}
 		newUptime := se.UptimePerService[result.TeamID][result.ServiceName]
+		if _, ok := se.LastResultPerService[result.TeamID]; !ok {
+			se.LastResultPerService[result.TeamID] = make(map[string]db.LastResult)
+		}
+		se.LastResultPerService[result.TeamID][result.ServiceName] = db.LastResult{Passed: result.Status, Earned: earned[i]}
 		if result.Status {
 			newUptime.PassedChecks++
 		}
 		newUptime.TotalChecks++
 		se.UptimePerService[result.TeamID][result.ServiceName] = newUptime
 
+		// rounds not run because a dependency was down count toward uptime, but only toward SLA when configured
+		if result.State == checks.StateDependencyDown && !se.Config.MiscSettings.SlaOnDependencyDown {
+			continue
+		}
+
 		if _, ok := se.SlaPerService[result.TeamID]; !ok {
-			se.SlaPerService[result.TeamID] = make(map[string]int)
+			se.SlaPerService[result.TeamID] = make(map[string]checks.SlaState)
 		}
-		if _, ok := se.SlaPerService[result.TeamID][result.ServiceName]; !ok {
-			se.SlaPerService[result.TeamID][result.ServiceName] = 0
+		// checks that were removed from the config since use the global SLA settings
+		rule, ok := slaRules[result.ServiceName]
+		if !ok {
+			rule = se.Config.SlaRule()
 		}
-		if result.Status {
-			se.SlaPerService[result.TeamID][result.ServiceName] = 0
-		} else {
-			se.SlaPerService[result.TeamID][result.ServiceName]++
-			if se.SlaPerService[result.TeamID][result.ServiceName] >= se.Config.MiscSettings.SlaThreshold {
-				sla := db.SLASchema{
-					TeamID:      result.TeamID,
-					ServiceName: result.ServiceName,
-					RoundID:     uint(se.CurrentRound),
-					Penalty:     se.Config.MiscSettings.SlaPenalty,
-				}
-				if _, err := db.CreateSLA(sla); err != nil {
-					slog.Error("failed to create SLA", "team", result.TeamID, "service", result.ServiceName, "error", err)
-				}
-				se.SlaPerService[result.TeamID][result.ServiceName] = 0
+		state := se.SlaPerService[result.TeamID][result.ServiceName]
+		penalty := rule.Record(&state, result.Status)
+		se.SlaPerService[result.TeamID][result.ServiceName] = state
+		if penalty > 0 {
+			sla := db.SLASchema{
+				TeamID:      result.TeamID,
+				ServiceName: result.ServiceName,
+				RoundID:     uint(se.CurrentRound),
+				Penalty:     penalty,
+			}
+			if _, err := db.CreateSLA(sla); err != nil {
+				slog.Error("failed to create SLA", "team", result.TeamID, "service", result.ServiceName, "error", err)
 			}
 		}
 	}
@@ -615,7 +873,11 @@ func (se *ScoringEngine) processCollectedResults(results []checks.Result) {
 
 	slog.Debug("Successfully processed results for round", "round", se.CurrentRound, "total", len(dbResults))
 
-	// Refresh materialized view asynchronously, but avoid concurrent refreshes
+	se.refreshScores()
+}
+
+// refreshScores refreshes the materialized view asynchronously, but avoids concurrent refreshes
+func (se *ScoringEngine) refreshScores() {
 	currentRound := se.CurrentRound
 	if se.Refreshing.CompareAndSwap(false, true) {
 		go func(round uint) {
@@ -2,7 +2,6 @@ package engine
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"sync"
 	"sync/atomic"
@@ -16,6 +15,8 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
 )
 
 // testTeamCounter provides unique team IDs across test runs
@@ -70,9 +71,14 @@ func newTestEngine(t *testing.T, redis *testutil.RedisContainer, slaThreshold in
 		Config:           conf,
 		CredentialsMutex: make(map[uint]*sync.Mutex),
 		UptimePerService: make(map[uint]map[string]db.Uptime),
-		SlaPerService:    make(map[uint]map[string]int),
+		SlaPerService:    make(map[uint]map[string]checks.SlaState),
+		Transport:        NewRedisTransport(redis.Client),
 		RedisClient:      redis.Client,
 		CurrentRound:     1,
+
+		LastResultPerService: make(map[uint]map[string]db.LastResult),
+		lastRunPerService:    make(map[string]time.Time),
+		runsPerService:       make(map[string]uint),
 	}
 }
 
@@ -204,7 +210,7 @@ func TestProcessCollectedResults_TriggersSLA(t *testing.T) {
 	}
 
 	// SLA counter should reset after triggering (this proves SLA was created)
-	assert.Equal(t, 0, engine.SlaPerService[team.ID]["failing-svc"],
+	assert.Equal(t, 0, engine.SlaPerService[team.ID]["failing-svc"].Streak,
 		"SLA counter should reset to 0 after SLA is triggered")
 
 	// Verify via team score which includes SLA penalties
@@ -250,7 +256,7 @@ func TestProcessCollectedResults_SLAResetsOnPass(t *testing.T) {
 	})
 
 	// Counter should be 0
-	assert.Equal(t, 0, engine.SlaPerService[team.ID]["svc"])
+	assert.Equal(t, 0, engine.SlaPerService[team.ID]["svc"].Streak)
 
 	// Fail twice more - still not at threshold
 	engine.CurrentRound = 4
@@ -263,7 +269,7 @@ func TestProcessCollectedResults_SLAResetsOnPass(t *testing.T) {
 	})
 
 	// Counter should be 2 (not triggered yet)
-	assert.Equal(t, 2, engine.SlaPerService[team.ID]["svc"],
+	assert.Equal(t, 2, engine.SlaPerService[team.ID]["svc"].Streak,
 		"SLA counter should be 2 after 2 consecutive failures")
 
 	// Verify no SLA via team score
@@ -303,7 +309,7 @@ func TestProcessCollectedResults_MultipleTeamsIndependent(t *testing.T) {
 	}
 
 	// Team 1 should have SLA (counter reset proves it triggered)
-	assert.Equal(t, 0, engine.SlaPerService[team1.ID]["svc"],
+	assert.Equal(t, 0, engine.SlaPerService[team1.ID]["svc"].Streak,
 		"Team 1 SLA counter should reset after triggering")
 
 	// Verify via team scores
@@ -317,12 +323,400 @@ func TestProcessCollectedResults_MultipleTeamsIndependent(t *testing.T) {
 	assert.Equal(t, 3, engine.UptimePerService[team2.ID]["svc"].TotalChecks)
 }
 
+func TestProcessKothResults_OwnerScores(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode")
+	}
+
+	redis := testutil.StartRedis(t)
+	defer redis.Close()
+
+	pg := testutil.StartPostgres(t)
+	defer pg.Close()
+	db.Connect(pg.ConnectionString())
+
+	redis.Client.FlushDB(context.Background())
+	db.ResetScores()
+
+	owner := createTestTeam(t, "Team Koth Owner", "01")
+	engine := newTestEngine(t, redis, 3)
+	engine.CurrentRoundStartTime = time.Now()
+
+	for round := uint(1); round <= 2; round++ {
+		engine.CurrentRound = round
+		engine.processKothResults([]checks.Result{
+			{ServiceName: "hill01-koth", Points: 15, RoundID: round, Status: true, OwnerID: owner.ID},
+			// an unowned hill scores for nobody
+			{ServiceName: "hill02-koth", Points: 15, RoundID: round},
+		})
+	}
+
+	servicePoints, _, _, err := db.GetTeamScore(owner.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 30, servicePoints, "every round a hill is owned scores for its owner")
+}
+
+func TestProcessCollectedResults_NoResultPolicy(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode")
+	}
+
+	redis := testutil.StartRedis(t)
+	defer redis.Close()
+
+	pg := testutil.StartPostgres(t)
+	defer pg.Close()
+	db.Connect(pg.ConnectionString())
+
+	redis.Client.FlushDB(context.Background())
+	db.ResetScores()
+
+	for _, tt := range []struct {
+		policy       string
+		expectPassed int
+		expectTotal  int
+		expectStreak int
+		expectEarned int
+	}{
+		{policy: "down", expectPassed: 0, expectTotal: 3, expectStreak: 0, expectEarned: 0},
+		{policy: "up", expectPassed: 3, expectTotal: 3, expectStreak: 0, expectEarned: 10},
+		{policy: "exclude", expectPassed: 0, expectTotal: 0, expectStreak: 0, expectEarned: 0},
+	} {
+		t.Run(tt.policy, func(t *testing.T) {
+			team := createTestTeam(t, "Team No Result "+tt.policy, "01")
+
+			engine := newTestEngine(t, redis, 3)
+			engine.Config.MiscSettings.NoResultPolicy = tt.policy
+			engine.CurrentRoundStartTime = time.Now()
+
+			for round := uint(1); round <= 3; round++ {
+				engine.CurrentRound = round
+				engine.processCollectedResults([]checks.Result{
+					{TeamID: team.ID, ServiceName: "svc", Points: 10, RoundID: round, State: checks.StateNoResult},
+				})
+			}
+
+			uptime := engine.UptimePerService[team.ID]["svc"]
+			assert.Equal(t, tt.expectPassed, uptime.PassedChecks)
+			assert.Equal(t, tt.expectTotal, uptime.TotalChecks)
+			assert.Equal(t, tt.expectStreak, engine.SlaPerService[team.ID]["svc"].Streak)
+
+			rows, err := db.GetServiceAllChecksByTeam(team.ID, "svc")
+			require.NoError(t, err)
+			require.Len(t, rows, 3)
+			for _, check := range rows {
+				assert.Equal(t, "no_result", check.State)
+				assert.Equal(t, tt.policy == "exclude", check.Excluded)
+				assert.Equal(t, tt.expectEarned, check.Earned)
+			}
+
+			servicePoints, _, _, err := db.GetTeamScore(team.ID)
+			require.NoError(t, err)
+			assert.Equal(t, 3*tt.expectEarned, servicePoints)
+		})
+	}
+}
+
+func TestProcessCollectedResults_SkippedRounds(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode")
+	}
+
+	redis := testutil.StartRedis(t)
+	defer redis.Close()
+
+	pg := testutil.StartPostgres(t)
+	defer pg.Close()
+	db.Connect(pg.ConnectionString())
+
+	redis.Client.FlushDB(context.Background())
+	db.ResetScores()
+
+	team := createTestTeam(t, "Team Skipped", "01")
+	engine := newTestEngine(t, redis, 2)
+	engine.CurrentRoundStartTime = time.Now()
+
+	// the check fails when it runs, then skips two rounds, passes, and skips again
+	for round, result := range []checks.Result{
+		{Status: false},
+		{State: checks.StateSkipped},
+		{State: checks.StateSkipped},
+		{Status: true},
+		{State: checks.StateSkipped},
+	} {
+		engine.CurrentRound = uint(round + 1)
+		result.TeamID = team.ID
+		result.ServiceName = "svc"
+		result.Points = 10
+		result.RoundID = engine.CurrentRound
+		engine.processCollectedResults([]checks.Result{result})
+	}
+
+	uptime := engine.UptimePerService[team.ID]["svc"]
+	assert.Equal(t, 1, uptime.PassedChecks, "skipped rounds should not count as checks")
+	assert.Equal(t, 2, uptime.TotalChecks)
+	assert.Equal(t, 0, engine.SlaPerService[team.ID]["svc"].Streak)
+
+	_, slaCount, _, err := db.GetTeamScore(team.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 0, slaCount, "skipped rounds should not add to the failure streak")
+
+	rows, err := db.GetServiceAllChecksByTeam(team.ID, "svc")
+	require.NoError(t, err)
+	require.Len(t, rows, 5)
+	// newest first, skipped rounds keep the result of the last run
+	assert.Equal(t, []bool{true, true, false, false, false}, []bool{rows[0].Result, rows[1].Result, rows[2].Result, rows[3].Result, rows[4].Result})
+	for _, check := range rows {
+		assert.Equal(t, check.State == checks.StateSkipped, check.Excluded)
+	}
+}
+
+func TestProcessCollectedResults_PartialCredit(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode")
+	}
+
+	redis := testutil.StartRedis(t)
+	defer redis.Close()
+
+	pg := testutil.StartPostgres(t)
+	defer pg.Close()
+	db.Connect(pg.ConnectionString())
+
+	redis.Client.FlushDB(context.Background())
+	db.ResetScores()
+
+	team := createTestTeam(t, "Team Partial", "01")
+	engine := newTestEngine(t, redis, 2)
+	engine.CurrentRoundStartTime = time.Now()
+
+	// three of four parts pass, then the check skips a round, then every part passes
+	for round, result := range []checks.Result{
+		{Parts: 4, PartsPassed: 3},
+		{State: checks.StateSkipped},
+		{Status: true, Parts: 4, PartsPassed: 4},
+	} {
+		engine.CurrentRound = uint(round + 1)
+		result.TeamID = team.ID
+		result.ServiceName = "svc"
+		result.Points = 20
+		result.RoundID = engine.CurrentRound
+		engine.processCollectedResults([]checks.Result{result})
+	}
+
+	rows, err := db.GetServiceAllChecksByTeam(team.ID, "svc")
+	require.NoError(t, err)
+	require.Len(t, rows, 3)
+	// newest first, skipped rounds keep the points of the last run
+	assert.Equal(t, []int{20, 15, 15}, []int{rows[0].Earned, rows[1].Earned, rows[2].Earned})
+
+	servicePoints, _, _, err := db.GetTeamScore(team.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 50, servicePoints)
+
+	uptime := engine.UptimePerService[team.ID]["svc"]
+	assert.Equal(t, 1, uptime.PassedChecks, "a partially passing check is down for uptime")
+}
+
+func TestProcessCollectedResults_DependencyDown(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode")
+	}
+
+	redis := testutil.StartRedis(t)
+	defer redis.Close()
+
+	pg := testutil.StartPostgres(t)
+	defer pg.Close()
+	db.Connect(pg.ConnectionString())
+
+	redis.Client.FlushDB(context.Background())
+	db.ResetScores()
+
+	for _, slaOnDependencyDown := range []bool{false, true} {
+		t.Run(fmt.Sprintf("sla=%v", slaOnDependencyDown), func(t *testing.T) {
+			team := createTestTeam(t, "Team Dependency", "01")
+
+			engine := newTestEngine(t, redis, 5)
+			engine.Config.MiscSettings.SlaOnDependencyDown = slaOnDependencyDown
+			engine.CurrentRoundStartTime = time.Now()
+
+			for round := uint(1); round <= 2; round++ {
+				engine.CurrentRound = round
+				engine.processCollectedResults([]checks.Result{
+					{TeamID: team.ID, ServiceName: "web", Points: 10, RoundID: round, State: checks.StateDependencyDown, RootCause: "ping"},
+				})
+			}
+
+			uptime := engine.UptimePerService[team.ID]["web"]
+			assert.Equal(t, 0, uptime.PassedChecks)
+			assert.Equal(t, 2, uptime.TotalChecks, "failures blamed on a dependency still count toward uptime")
+			expectStreak := 0
+			if slaOnDependencyDown {
+				expectStreak = 2
+			}
+			assert.Equal(t, expectStreak, engine.SlaPerService[team.ID]["web"].Streak)
+
+			rows, err := db.GetServiceAllChecksByTeam(team.ID, "web")
+			require.NoError(t, err)
+			require.Len(t, rows, 2)
+			for _, check := range rows {
+				assert.Equal(t, checks.StateDependencyDown, check.State)
+				assert.Equal(t, "ping", check.RootCause)
+			}
+		})
+	}
+}
+
+func TestProcessCollectedResults_PerServiceSlaRule(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode")
+	}
+
+	redis := testutil.StartRedis(t)
+	defer redis.Close()
+
+	pg := testutil.StartPostgres(t)
+	defer pg.Close()
+	db.Connect(pg.ConnectionString())
+
+	redis.Client.FlushDB(context.Background())
+	db.ResetScores()
+
+	team := createTestTeam(t, "Team SLA Rule", "01")
+
+	// the global rule would never trigger, the check's own rolling rule does
+	engine := newTestEngine(t, redis, 100)
+	engine.Config.Box = []config.Box{{Name: "box", Runners: []checks.Runner{
+		&checks.Tcp{Service: checks.Service{Name: "box-tcp", SlaPolicy: checks.SlaRolling, SlaThreshold: 2, SlaWindow: 3, SlaPenalty: 7}},
+	}}}
+	engine.CurrentRoundStartTime = time.Now()
+
+	for round, passed := range []bool{false, true, false, false, true, true} {
+		engine.CurrentRound = uint(round + 1)
+		engine.processCollectedResults([]checks.Result{
+			{TeamID: team.ID, ServiceName: "box-tcp", Status: passed, Points: 10, RoundID: engine.CurrentRound},
+		})
+	}
+
+	_, slaCount, _, err := db.GetTeamScore(team.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 1, slaCount, "expected 1 SLA violation from the rolling rule")
+
+	// restart recovery replays the rounds into the same state
+	loaded := make(map[uint]map[string]checks.SlaState)
+	require.NoError(t, db.LoadSLAs(&loaded, engine.Config.SlaRules(), engine.Config.SlaRule(), false))
+	assert.Equal(t, engine.SlaPerService[team.ID]["box-tcp"], loaded[team.ID]["box-tcp"])
+}
+
+// TestLoadState_RowsFromBeforeMigration tests restart recovery counts rounds
+// saved before the columns marking skipped rounds were added
+func TestLoadState_RowsFromBeforeMigration(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode")
+	}
+
+	redis := testutil.StartRedis(t)
+	defer redis.Close()
+
+	pg := testutil.StartPostgres(t)
+	defer pg.Close()
+	db.Connect(pg.ConnectionString())
+
+	redis.Client.FlushDB(context.Background())
+	db.ResetScores()
+
+	team := createTestTeam(t, "Team Migration", "01")
+
+	engine := newTestEngine(t, redis, 100)
+	engine.Config.Box = []config.Box{{Name: "box", Runners: []checks.Runner{
+		&checks.Tcp{Service: checks.Service{Name: "box-tcp", SlaThreshold: 2, SlaPenalty: 7}},
+	}}}
+	engine.CurrentRoundStartTime = time.Now()
+
+	for round, passed := range []bool{true, false, false} {
+		engine.CurrentRound = uint(round + 1)
+		engine.processCollectedResults([]checks.Result{
+			{TeamID: team.ID, ServiceName: "box-tcp", Status: passed, Points: 10, RoundID: engine.CurrentRound},
+		})
+	}
+
+	// the columns are NULL on rows that existed when they were added
+	conn, err := gorm.Open(postgres.Open(pg.ConnectionString()), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, conn.Exec("UPDATE service_check_schemas SET excluded = NULL, state = NULL WHERE team_id = ?", team.ID).Error)
+
+	uptimes := make(map[uint]map[string]db.Uptime)
+	require.NoError(t, db.LoadUptimes(&uptimes))
+	assert.Equal(t, db.Uptime{PassedChecks: 1, TotalChecks: 3}, uptimes[team.ID]["box-tcp"])
+
+	lastResults := make(map[uint]map[string]db.LastResult)
+	require.NoError(t, db.LoadLastResults(&lastResults))
+	assert.Contains(t, lastResults[team.ID], "box-tcp")
+	assert.Equal(t, db.LastResult{Passed: false, Earned: 0}, lastResults[team.ID]["box-tcp"])
+
+	slas := make(map[uint]map[string]checks.SlaState)
+	require.NoError(t, db.LoadSLAs(&slas, engine.Config.SlaRules(), engine.Config.SlaRule(), false))
+	assert.Equal(t, engine.SlaPerService[team.ID]["box-tcp"], slas[team.ID]["box-tcp"])
+}
+
+func TestProcessCollectedResults_SavesAttempts(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode")
+	}
+
+	redis := testutil.StartRedis(t)
+	defer redis.Close()
+
+	pg := testutil.StartPostgres(t)
+	defer pg.Close()
+	db.Connect(pg.ConnectionString())
+
+	redis.Client.FlushDB(context.Background())
+	db.ResetScores()
+
+	team := createTestTeam(t, "Team Attempts", "01")
+
+	engine := newTestEngine(t, redis, 3)
+	engine.CurrentRound = 1
+	engine.CurrentRoundStartTime = time.Now()
+
+	engine.processCollectedResults([]checks.Result{
+		{
+			TeamID:      team.ID,
+			ServiceName: "svc",
+			RoundID:     1,
+			Status:      true,
+			Points:      10,
+			MaxAttempts: 3,
+			Attempts: []checks.Attempt{
+				{Number: 1, Error: "connection refused", DurationMs: 12, RunnerID: "runner-a"},
+				{Number: 2, Error: "timeout", Debug: "round ended before check completed", DurationMs: 3000, RunnerID: "runner-a"},
+				{Number: 3, Status: true, DurationMs: 40, RunnerID: "runner-a"},
+			},
+		},
+	})
+
+	rows, err := db.GetServiceAllChecksByTeam(team.ID, "svc")
+	require.NoError(t, err)
+	require.Len(t, rows, 1)
+	require.Len(t, rows[0].Attempts, 3)
+	for i, attempt := range rows[0].Attempts {
+		assert.Equal(t, i+1, attempt.Attempt)
+		assert.Equal(t, 3, attempt.MaxAttempts)
+		assert.Equal(t, "runner-a", attempt.RunnerID)
+	}
+	assert.Equal(t, "connection refused", rows[0].Attempts[0].Error)
+	assert.Equal(t, int64(3000), rows[0].Attempts[1].DurationMs)
+	assert.True(t, rows[0].Attempts[2].Result)
+}
+
 // mockRunner is a simple runner for testing that always passes
 type mockRunner struct {
 	checks.Service
 }
 
-func (m *mockRunner) Run(teamID uint, identifier string, roundID uint, resultsChan chan checks.Result) {
+func (m *mockRunner) Run(ctx context.Context, teamID uint, identifier string, roundID uint, resultsChan chan checks.Result) {
 	resultsChan <- checks.Result{
 		TeamID:      teamID,
 		ServiceName: m.Name,
@@ -400,22 +794,18 @@ func TestRvb_EnqueuesTasksAndCollectsResults(t *testing.T) {
 			case <-stopRunner:
 				return
 			default:
-				val, err := redis.Client.BLPop(ctx, time.Second, "tasks").Result()
+				task, msgID, err := ClaimTask(ctx, redis.Client, "test-runner", nil, time.Minute, time.Second)
 				if err != nil {
+					t.Logf("Failed to claim task: %v", err)
 					continue
 				}
-				if len(val) < 2 {
-					continue
-				}
-
-				var task Task
-				if err := json.Unmarshal([]byte(val[1]), &task); err != nil {
-					t.Logf("Failed to unmarshal task: %v", err)
+				if task == nil {
 					continue
 				}
 
 				// Push a successful result
 				result := checks.Result{
+					TaskID:      task.ID,
 					TeamID:      task.TeamID,
 					ServiceName: task.ServiceName,
 					ServiceType: task.ServiceType,
@@ -423,8 +813,8 @@ func TestRvb_EnqueuesTasksAndCollectsResults(t *testing.T) {
 					Status:      true,
 					Points:      10,
 				}
-				resultJSON, _ := json.Marshal(result)
-				redis.Client.RPush(ctx, "results", resultJSON)
+				PushResult(ctx, redis.Client, result)
+				AckTask(ctx, redis.Client, msgID)
 			}
 		}
 	}()
@@ -505,16 +895,10 @@ func TestRvb_HandlesMultipleServices(t *testing.T) {
 			case <-stopRunner:
 				return
 			default:
-				val, err := redis.Client.BLPop(ctx, time.Second, "tasks").Result()
-				if err != nil {
+				task, msgID, err := ClaimTask(ctx, redis.Client, "test-runner", nil, time.Minute, time.Second)
+				if err != nil || task == nil {
 					continue
 				}
-				if len(val) < 2 {
-					continue
-				}
-
-				var task Task
-				json.Unmarshal([]byte(val[1]), &task)
 
 				// Return points based on service name
 				points := 10
@@ -525,6 +909,7 @@ func TestRvb_HandlesMultipleServices(t *testing.T) {
 				}
 
 				result := checks.Result{
+					TaskID:      task.ID,
 					TeamID:      task.TeamID,
 					ServiceName: task.ServiceName,
 					ServiceType: task.ServiceType,
@@ -532,8 +917,8 @@ func TestRvb_HandlesMultipleServices(t *testing.T) {
 					Status:      true,
 					Points:      points,
 				}
-				resultJSON, _ := json.Marshal(result)
-				redis.Client.RPush(ctx, "results", resultJSON)
+				PushResult(ctx, redis.Client, result)
+				AckTask(ctx, redis.Client, msgID)
 			}
 		}
 	}()
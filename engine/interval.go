@@ -0,0 +1,42 @@
+package engine
+
+import (
+	"time"
+
+	"quotient/engine/checks"
+	"quotient/engine/db"
+)
+
+// checkDue reports whether a check runs in round. A check with an interval in
+// seconds runs in the round closest to the interval passing since lastRun, since
+// rounds are jittered and rarely line up with it exactly.
+func checkDue(r checks.Runner, round uint, now time.Time, lastRun time.Time, delay time.Duration) bool {
+	if n := r.GetEveryRounds(); n > 1 && round > 0 && (round-1)%uint(n) != 0 {
+		return false
+	}
+	if interval := time.Duration(r.GetInterval()) * time.Second; interval > 0 && !lastRun.IsZero() {
+		return now.Sub(lastRun) >= interval-delay/2
+	}
+	return true
+}
+
+// skippedResult is recorded for a team's check in a round it was not scheduled
+// in. It is kept out of uptime and SLA accounting and scores like the check's last run.
+func skippedResult(r checks.Runner, teamID uint, round uint) checks.Result {
+	return checks.Result{
+		TeamID:      teamID,
+		ServiceName: r.GetName(),
+		ServiceType: r.GetType(),
+		RoundID:     round,
+		Points:      r.GetPoints(),
+		State:       checks.StateSkipped,
+		Debug:       "check is not scheduled to run this round",
+	}
+}
+
+// lastResult returns how a team's check scored the last round it ran in
+func (se *ScoringEngine) lastResult(teamID uint, serviceName string) db.LastResult {
+	se.uptimeMu.Lock()
+	defer se.uptimeMu.Unlock()
+	return se.LastResultPerService[teamID][serviceName]
+}
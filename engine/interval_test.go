@@ -0,0 +1,55 @@
+package engine
+
+import (
+	"testing"
+	"time"
+
+	"quotient/engine/checks"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckDueEveryRounds(t *testing.T) {
+	check := &checks.Tcp{Service: checks.Service{Name: "db01-sql", EveryRounds: 3}}
+
+	var ran []uint
+	for round := uint(1); round <= 9; round++ {
+		if checkDue(check, round, time.Now(), time.Time{}, time.Minute) {
+			ran = append(ran, round)
+		}
+	}
+	assert.Equal(t, []uint{1, 4, 7}, ran)
+}
+
+func TestCheckDueInterval(t *testing.T) {
+	check := &checks.Tcp{Service: checks.Service{Name: "dc01-winrm", Interval: 300}}
+	delay := time.Minute
+	start := time.Now()
+
+	assert.True(t, checkDue(check, 1, start, time.Time{}, delay), "a check that never ran is due")
+	assert.False(t, checkDue(check, 2, start.Add(time.Minute), start, delay))
+	assert.False(t, checkDue(check, 5, start.Add(4*time.Minute), start, delay))
+	// rounds are jittered, the round closest to the interval runs the check
+	assert.True(t, checkDue(check, 6, start.Add(4*time.Minute+45*time.Second), start, delay))
+	assert.True(t, checkDue(check, 6, start.Add(5*time.Minute+10*time.Second), start, delay))
+}
+
+func TestCheckDueWithoutInterval(t *testing.T) {
+	check := &checks.Tcp{Service: checks.Service{Name: "web01-tcp"}}
+	now := time.Now()
+	for round := uint(1); round <= 5; round++ {
+		assert.True(t, checkDue(check, round, now, now, time.Minute), "round %d", round)
+	}
+}
+
+func TestSkippedResult(t *testing.T) {
+	check := &checks.Tcp{Service: checks.Service{Name: "db01-sql", ServiceType: "Tcp", Points: 5, EveryRounds: 2}}
+	result := skippedResult(check, 3, 8)
+
+	assert.Equal(t, checks.StateSkipped, result.State)
+	assert.Equal(t, uint(3), result.TeamID)
+	assert.Equal(t, "db01-sql", result.ServiceName)
+	assert.Equal(t, uint(8), result.RoundID)
+	assert.Equal(t, 5, result.Points)
+	assert.Empty(t, result.TaskID, "skipped checks are never enqueued")
+}
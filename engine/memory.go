@@ -0,0 +1,350 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"quotient/engine/checks"
+)
+
+// MemoryTransport passes tasks and results between the engine and runners running
+// in the same process. It follows the same rules as the Redis streams: a claimed
+// task stays with its runner until acknowledged, and is handed to another runner
+// once it has gone unextended for longer than the visibility timeout.
+type MemoryTransport struct {
+	mu sync.Mutex
+
+	// queued holds the tasks no runner has claimed yet, per task queue
+	queued map[string][]memoryTask
+	// claimed holds the tasks runners are working on, by claim ID
+	claimed map[string]*memoryClaim
+	// results holds the results the engine has not read yet, oldest first
+	results []checks.Result
+	// recent holds the results pushed lately, oldest first, for the task view
+	recent      []memoryResult
+	runners     map[string]RunnerInfo
+	subscribers map[chan string]struct{}
+	nextID      uint64
+
+	// changed is closed and replaced whenever a task or result is added, waking
+	// everyone waiting for one
+	changed chan struct{}
+}
+
+type memoryTask struct {
+	id       string
+	task     Task
+	enqueued time.Time
+}
+
+type memoryClaim struct {
+	memoryTask
+	queue    string
+	consumer string
+	// lastSeen is when the claim was made or last extended
+	lastSeen time.Time
+}
+
+type memoryResult struct {
+	result checks.Result
+	pushed time.Time
+}
+
+func NewMemoryTransport() *MemoryTransport {
+	return &MemoryTransport{
+		queued:      make(map[string][]memoryTask),
+		claimed:     make(map[string]*memoryClaim),
+		runners:     make(map[string]RunnerInfo),
+		subscribers: make(map[chan string]struct{}),
+		changed:     make(chan struct{}),
+	}
+}
+
+// notify wakes everyone waiting for a task or result. The caller holds mu.
+func (t *MemoryTransport) notify() {
+	close(t.changed)
+	t.changed = make(chan struct{})
+}
+
+// wait blocks until something changes, ctx is done or the deadline passes. It
+// reports whether it is worth looking again.
+func wait(ctx context.Context, changed <-chan struct{}, deadline time.Time) bool {
+	timer := time.NewTimer(time.Until(deadline))
+	defer timer.Stop()
+	select {
+	case <-changed:
+		return true
+	case <-timer.C:
+		return false
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func (t *MemoryTransport) EnqueueTask(ctx context.Context, task Task) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.nextID++
+	queue := TaskQueue(task.Tags)
+	t.queued[queue] = append(t.queued[queue], memoryTask{
+		id:       strconv.FormatUint(t.nextID, 10),
+		task:     task,
+		enqueued: time.Now(),
+	})
+	t.notify()
+	return nil
+}
+
+// ClaimTask hands the next task to a runner carrying the given tags, reclaiming
+// tasks abandoned by another runner first. It waits up to block for a new task and
+// returns a nil task when there is nothing to do.
+func (t *MemoryTransport) ClaimTask(ctx context.Context, consumer string, tags []string, visibility time.Duration, block time.Duration) (*Task, Claim, error) {
+	deadline := time.Now().Add(block)
+	for {
+		t.mu.Lock()
+		claim, reclaimed := t.reclaim(consumer, tags, visibility)
+		if claim == nil {
+			claim = t.claimQueued(consumer, tags)
+		}
+		changed := t.changed
+		t.mu.Unlock()
+
+		if claim != nil {
+			if reclaimed {
+				slog.Warn("reclaimed task from unresponsive runner", "message_id", claim.id, "queue", claim.queue, "runner_id", consumer)
+			}
+			task := claim.task
+			if time.Now().After(task.Deadline) {
+				_ = t.AckTask(ctx, Claim{Queue: claim.queue, ID: claim.id})
+				return nil, Claim{}, fmt.Errorf("%w: task %s for round %d expired at %s", ErrTaskRefused, task.ID, task.RoundID, task.Deadline.Format(time.RFC3339))
+			}
+			return &task, Claim{Queue: claim.queue, ID: claim.id}, nil
+		}
+		if !wait(ctx, changed, deadline) {
+			return nil, Claim{}, ctx.Err()
+		}
+	}
+}
+
+// reclaim hands the task idle the longest past the visibility timeout to consumer.
+// The caller holds mu.
+func (t *MemoryTransport) reclaim(consumer string, tags []string, visibility time.Duration) (*memoryClaim, bool) {
+	var oldest *memoryClaim
+	for _, claim := range t.claimed {
+		if time.Since(claim.lastSeen) < visibility || !hasTags(tags, queueTags(claim.queue)) {
+			continue
+		}
+		if oldest == nil || claim.lastSeen.Before(oldest.lastSeen) {
+			oldest = claim
+		}
+	}
+	if oldest == nil {
+		return nil, false
+	}
+	oldest.consumer = consumer
+	oldest.lastSeen = time.Now()
+	return oldest, true
+}
+
+// claimQueued takes the first queued task consumer can serve. The caller holds mu.
+func (t *MemoryTransport) claimQueued(consumer string, tags []string) *memoryClaim {
+	for _, queue := range slices.Sorted(maps.Keys(t.queued)) {
+		tasks := t.queued[queue]
+		if len(tasks) == 0 || !hasTags(tags, queueTags(queue)) {
+			continue
+		}
+		claim := &memoryClaim{
+			memoryTask: tasks[0],
+			queue:      queue,
+			consumer:   consumer,
+			lastSeen:   time.Now(),
+		}
+		t.queued[queue] = tasks[1:]
+		t.claimed[claim.id] = claim
+		return claim
+	}
+	return nil
+}
+
+func (t *MemoryTransport) ExtendTask(ctx context.Context, consumer string, claim Claim) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if c, ok := t.claimed[claim.ID]; ok {
+		c.consumer = consumer
+		c.lastSeen = time.Now()
+	}
+	return nil
+}
+
+// ReleaseTask makes a claimed task look idle for the whole visibility timeout, so
+// the next runner to look reclaims it straight away
+func (t *MemoryTransport) ReleaseTask(ctx context.Context, consumer string, claim Claim, visibility time.Duration) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if c, ok := t.claimed[claim.ID]; ok {
+		c.lastSeen = time.Now().Add(-visibility)
+		t.notify()
+	}
+	return nil
+}
+
+func (t *MemoryTransport) AckTask(ctx context.Context, claim Claim) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.claimed, claim.ID)
+	return nil
+}
+
+func (t *MemoryTransport) PushResult(ctx context.Context, result checks.Result) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.results = append(t.results, result)
+	t.recent = append(t.recent, memoryResult{result: result, pushed: time.Now()})
+	if len(t.recent) > streamMaxLen {
+		t.recent = slices.Delete(t.recent, 0, len(t.recent)-streamMaxLen)
+	}
+	t.notify()
+	return nil
+}
+
+// ReadResult waits up to block for the next result from the runners. It returns
+// ErrNoResult when no result arrived in time.
+func (t *MemoryTransport) ReadResult(ctx context.Context, block time.Duration) (checks.Result, error) {
+	deadline := time.Now().Add(block)
+	for {
+		t.mu.Lock()
+		if len(t.results) > 0 {
+			result := t.results[0]
+			t.results = t.results[1:]
+			t.mu.Unlock()
+			return result, nil
+		}
+		changed := t.changed
+		t.mu.Unlock()
+
+		if !wait(ctx, changed, deadline) {
+			if ctx.Err() != nil {
+				return checks.Result{}, ctx.Err()
+			}
+			return checks.Result{}, ErrNoResult
+		}
+	}
+}
+
+// ClearStaleTasks drops tasks left over from previous rounds, whether they were
+// never picked up or are still held by a runner, before new ones are enqueued
+func (t *MemoryTransport) ClearStaleTasks(ctx context.Context) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for queue, tasks := range t.queued {
+		held := 0
+		for _, claim := range t.claimed {
+			if claim.queue == queue {
+				held++
+			}
+		}
+		if len(tasks)+held > 0 {
+			slog.Warn("Clearing stale tasks from queue", "queue", queue, "unclaimed", len(tasks), "unacknowledged", held)
+		}
+	}
+	clear(t.queued)
+	clear(t.claimed)
+}
+
+func (t *MemoryTransport) Reset(ctx context.Context) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	clear(t.queued)
+	clear(t.claimed)
+	t.results = nil
+	t.recent = nil
+	return nil
+}
+
+func (t *MemoryTransport) RunningTasks(ctx context.Context) ([]checks.Result, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	running := make([]checks.Result, 0, len(t.claimed))
+	for _, claim := range t.claimed {
+		running = append(running, runningTask(claim.task, claim.consumer, claim.enqueued))
+	}
+	return running, nil
+}
+
+func (t *MemoryTransport) RecentResults(ctx context.Context, since time.Time) ([]checks.Result, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var results []checks.Result
+	for _, r := range slices.Backward(t.recent) {
+		if r.pushed.Before(since) {
+			break
+		}
+		results = append(results, r.result)
+	}
+	return results, nil
+}
+
+// Publish hands the event to every subscriber, dropping it for subscribers that
+// have fallen behind
+func (t *MemoryTransport) Publish(ctx context.Context, event string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for sub := range t.subscribers {
+		select {
+		case sub <- event:
+		default:
+		}
+	}
+	return nil
+}
+
+func (t *MemoryTransport) Subscribe(ctx context.Context) (<-chan string, func()) {
+	events := make(chan string, 16)
+	t.mu.Lock()
+	t.subscribers[events] = struct{}{}
+	t.mu.Unlock()
+	return events, func() {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		if _, ok := t.subscribers[events]; ok {
+			delete(t.subscribers, events)
+			close(events)
+		}
+	}
+}
+
+func (t *MemoryTransport) Heartbeat(ctx context.Context, info RunnerInfo) error {
+	info.LastSeen = time.Now()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.runners[info.ID] = info
+	return nil
+}
+
+func (t *MemoryTransport) Deregister(ctx context.Context, runnerID string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.runners, runnerID)
+	return nil
+}
+
+func (t *MemoryTransport) Runners(ctx context.Context) ([]RunnerInfo, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	runners := make([]RunnerInfo, 0, len(t.runners))
+	for id, info := range t.runners {
+		if forget := checkHealth(&info); forget {
+			delete(t.runners, id)
+			continue
+		}
+		runners = append(runners, info)
+	}
+	sortRunners(runners)
+	return runners, nil
+}
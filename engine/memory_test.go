@@ -0,0 +1,187 @@
+package engine
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"quotient/engine/checks"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryTransportTaskLifecycle(t *testing.T) {
+	ctx := context.Background()
+	transport := NewMemoryTransport()
+
+	task := Task{ID: "task-1", TeamID: 1, ServiceName: "web01-web", RoundID: 1, Deadline: time.Now().Add(time.Minute)}
+	require.NoError(t, transport.EnqueueTask(ctx, task))
+
+	claimed, claim, err := transport.ClaimTask(ctx, "runner-1", nil, time.Minute, 10*time.Millisecond)
+	require.NoError(t, err)
+	require.NotNil(t, claimed)
+	assert.Equal(t, "task-1", claimed.ID)
+
+	// a claimed task shows as running and is not handed out again
+	running, err := transport.RunningTasks(ctx)
+	require.NoError(t, err)
+	require.Len(t, running, 1)
+	assert.Equal(t, "runner-1", running[0].RunnerID)
+	again, _, err := transport.ClaimTask(ctx, "runner-2", nil, time.Minute, 10*time.Millisecond)
+	require.NoError(t, err)
+	assert.Nil(t, again)
+
+	require.NoError(t, transport.PushResult(ctx, checks.Result{TaskID: "task-1", RoundID: 1, Status: true}))
+	require.NoError(t, transport.AckTask(ctx, claim))
+
+	result, err := transport.ReadResult(ctx, 10*time.Millisecond)
+	require.NoError(t, err)
+	assert.Equal(t, "task-1", result.TaskID)
+	_, err = transport.ReadResult(ctx, 10*time.Millisecond)
+	assert.ErrorIs(t, err, ErrNoResult)
+
+	running, err = transport.RunningTasks(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, running)
+	recent, err := transport.RecentResults(ctx, time.Now().Add(-time.Minute))
+	require.NoError(t, err)
+	assert.Len(t, recent, 1)
+}
+
+func TestMemoryTransportWaitsForTask(t *testing.T) {
+	ctx := context.Background()
+	transport := NewMemoryTransport()
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		_ = transport.EnqueueTask(ctx, Task{ID: "late", Deadline: time.Now().Add(time.Minute)})
+	}()
+
+	task, _, err := transport.ClaimTask(ctx, "runner-1", nil, time.Minute, time.Second)
+	require.NoError(t, err)
+	require.NotNil(t, task)
+	assert.Equal(t, "late", task.ID)
+}
+
+func TestMemoryTransportRouting(t *testing.T) {
+	ctx := context.Background()
+	transport := NewMemoryTransport()
+	deadline := time.Now().Add(time.Minute)
+
+	require.NoError(t, transport.EnqueueTask(ctx, Task{ID: "windows", Tags: []string{"windows-tools"}, Deadline: deadline}))
+
+	// a runner without the tag never sees the task
+	task, _, err := transport.ClaimTask(ctx, "plain", nil, time.Minute, 10*time.Millisecond)
+	require.NoError(t, err)
+	assert.Nil(t, task)
+
+	task, _, err = transport.ClaimTask(ctx, "tagged", []string{"subnet-a", "windows-tools"}, time.Minute, 10*time.Millisecond)
+	require.NoError(t, err)
+	require.NotNil(t, task)
+	assert.Equal(t, "windows", task.ID)
+}
+
+func TestMemoryTransportReclaim(t *testing.T) {
+	ctx := context.Background()
+	transport := NewMemoryTransport()
+	visibility := 50 * time.Millisecond
+
+	require.NoError(t, transport.EnqueueTask(ctx, Task{ID: "abandoned", Deadline: time.Now().Add(time.Minute)}))
+	_, claim, err := transport.ClaimTask(ctx, "dead", nil, visibility, 10*time.Millisecond)
+	require.NoError(t, err)
+
+	// an extended claim is left alone
+	time.Sleep(30 * time.Millisecond)
+	require.NoError(t, transport.ExtendTask(ctx, "dead", claim))
+	time.Sleep(30 * time.Millisecond)
+	task, _, err := transport.ClaimTask(ctx, "alive", nil, visibility, 0)
+	require.NoError(t, err)
+	assert.Nil(t, task)
+
+	// once the visibility timeout passes another runner takes it over
+	time.Sleep(visibility)
+	task, reclaimed, err := transport.ClaimTask(ctx, "alive", nil, visibility, 0)
+	require.NoError(t, err)
+	require.NotNil(t, task)
+	assert.Equal(t, "abandoned", task.ID)
+	assert.Equal(t, claim, reclaimed)
+
+	// a released task is reclaimed without waiting
+	require.NoError(t, transport.ReleaseTask(ctx, "alive", reclaimed, time.Minute))
+	task, _, err = transport.ClaimTask(ctx, "other", nil, time.Minute, 0)
+	require.NoError(t, err)
+	require.NotNil(t, task)
+	assert.Equal(t, "abandoned", task.ID)
+}
+
+func TestMemoryTransportRefusesExpiredTasks(t *testing.T) {
+	ctx := context.Background()
+	transport := NewMemoryTransport()
+
+	require.NoError(t, transport.EnqueueTask(ctx, Task{ID: "old", Deadline: time.Now().Add(-time.Second)}))
+	task, _, err := transport.ClaimTask(ctx, "runner-1", nil, time.Minute, 10*time.Millisecond)
+	assert.ErrorIs(t, err, ErrTaskRefused)
+	assert.Nil(t, task)
+
+	// the refused task is dropped rather than left to be reclaimed
+	running, err := transport.RunningTasks(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, running)
+}
+
+func TestMemoryTransportClearStaleTasks(t *testing.T) {
+	ctx := context.Background()
+	transport := NewMemoryTransport()
+	deadline := time.Now().Add(time.Minute)
+
+	require.NoError(t, transport.EnqueueTask(ctx, Task{ID: "held", Deadline: deadline}))
+	require.NoError(t, transport.EnqueueTask(ctx, Task{ID: "queued", Deadline: deadline}))
+	_, _, err := transport.ClaimTask(ctx, "runner-1", nil, time.Millisecond, 0)
+	require.NoError(t, err)
+
+	transport.ClearStaleTasks(ctx)
+	time.Sleep(5 * time.Millisecond)
+	task, _, err := transport.ClaimTask(ctx, "runner-2", nil, time.Millisecond, 0)
+	require.NoError(t, err)
+	assert.Nil(t, task)
+}
+
+func TestMemoryTransportEvents(t *testing.T) {
+	ctx := context.Background()
+	transport := NewMemoryTransport()
+
+	first, closeFirst := transport.Subscribe(ctx)
+	second, closeSecond := transport.Subscribe(ctx)
+	defer closeSecond()
+
+	require.NoError(t, transport.Publish(ctx, "reset"))
+	assert.Equal(t, "reset", <-first)
+	assert.Equal(t, "reset", <-second)
+
+	closeFirst()
+	_, open := <-first
+	assert.False(t, open)
+	require.NoError(t, transport.Publish(ctx, "round_finish"))
+	assert.Equal(t, "round_finish", <-second)
+}
+
+func TestMemoryTransportRunners(t *testing.T) {
+	ctx := context.Background()
+	transport := NewMemoryTransport()
+
+	require.NoError(t, transport.Heartbeat(ctx, RunnerInfo{ID: "embedded-2"}))
+	require.NoError(t, transport.Heartbeat(ctx, RunnerInfo{ID: "embedded-1"}))
+
+	runners, err := transport.Runners(ctx)
+	require.NoError(t, err)
+	require.Len(t, runners, 2)
+	assert.Equal(t, "embedded-1", runners[0].ID)
+	assert.True(t, runners[0].Healthy)
+
+	require.NoError(t, transport.Deregister(ctx, "embedded-1"))
+	runners, err = transport.Runners(ctx)
+	require.NoError(t, err)
+	require.Len(t, runners, 1)
+	assert.Equal(t, "embedded-2", runners[0].ID)
+}
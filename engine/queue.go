@@ -0,0 +1,389 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"quotient/engine/checks"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Tasks and results travel over Redis streams. Runners read tasks through the
+// runners consumer group and only acknowledge a task once its result has been
+// pushed, so a task claimed by a runner that dies is handed to another runner
+// once it has sat unacknowledged for longer than the visibility timeout.
+//
+// Tasks for checks that require runner tags go to a separate stream per set of
+// tags, and runners only read the streams whose tags they all carry.
+const (
+	TaskStream   = "tasks"
+	ResultStream = "results"
+	RunnerGroup  = "runners"
+	engineGroup  = "engine"
+
+	// taskQueuesKey is the Redis set of every tagged task stream in use
+	taskQueuesKey = "task_queues"
+
+	// approximate number of entries kept in each stream
+	streamMaxLen = 10000
+)
+
+var errMalformedResult = errors.New("malformed check result")
+
+// reclaimCursors holds, per task stream, where the last scan for abandoned tasks
+// stopped. Each scan only looks at part of the pending list, so successive calls
+// pick up from there.
+var (
+	reclaimCursors   = map[string]string{}
+	reclaimCursorsMu sync.Mutex
+)
+
+// Claim identifies a task a runner has taken off a task stream
+type Claim struct {
+	Queue string
+	ID    string
+}
+
+// TaskQueue returns the task stream for checks requiring the given runner tags
+func TaskQueue(tags []string) string {
+	if len(tags) == 0 {
+		return TaskStream
+	}
+	return TaskStream + ":" + strings.Join(slices.Sorted(slices.Values(tags)), ",")
+}
+
+// queueTags returns the runner tags required by the tasks on a task stream
+func queueTags(queue string) []string {
+	_, tags, found := strings.Cut(queue, ":")
+	if !found || tags == "" {
+		return nil
+	}
+	return strings.Split(tags, ",")
+}
+
+// taskQueues returns every task stream in use, untagged first
+func taskQueues(ctx context.Context, rdb *redis.Client) ([]string, error) {
+	tagged, err := rdb.SMembers(ctx, taskQueuesKey).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get task queues: %w", err)
+	}
+	slices.Sort(tagged)
+	return append([]string{TaskStream}, tagged...), nil
+}
+
+// RunnerQueues returns the task streams a runner carrying the given tags can serve
+func RunnerQueues(ctx context.Context, rdb *redis.Client, tags []string) ([]string, error) {
+	queues, err := taskQueues(ctx, rdb)
+	if err != nil {
+		return nil, err
+	}
+	return slices.DeleteFunc(queues, func(queue string) bool {
+		return !hasTags(tags, queueTags(queue))
+	}), nil
+}
+
+// hasTags reports whether have includes every tag in want
+func hasTags(have []string, want []string) bool {
+	for _, tag := range want {
+		if !slices.Contains(have, tag) {
+			return false
+		}
+	}
+	return true
+}
+
+func ensureGroup(ctx context.Context, rdb *redis.Client, stream string, group string) error {
+	err := rdb.XGroupCreateMkStream(ctx, stream, group, "0").Err()
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return fmt.Errorf("failed to create consumer group %s on %s: %w", group, stream, err)
+	}
+	return nil
+}
+
+// EnsureStreams creates the untagged task stream, the result stream and their
+// consumer groups if they do not exist yet
+func EnsureStreams(ctx context.Context, rdb *redis.Client) error {
+	if err := ensureGroup(ctx, rdb, TaskStream, RunnerGroup); err != nil {
+		return err
+	}
+	return ensureGroup(ctx, rdb, ResultStream, engineGroup)
+}
+
+func isNoGroup(err error) bool {
+	return err != nil && strings.HasPrefix(err.Error(), "NOGROUP")
+}
+
+// ClaimTask hands the next task to a runner carrying the given tags. Tasks left
+// unacknowledged by another runner for longer than visibility are reclaimed first,
+// otherwise it waits up to block for a new task. It returns a nil task when there
+// is nothing to do. The returned claim must be passed to AckTask once the result
+// has been pushed.
+func ClaimTask(ctx context.Context, rdb *redis.Client, consumer string, tags []string, visibility time.Duration, block time.Duration) (*Task, Claim, error) {
+	queues, err := RunnerQueues(ctx, rdb, tags)
+	if err != nil {
+		return nil, Claim{}, err
+	}
+	ensureQueues := func() error {
+		for _, queue := range queues {
+			if err := ensureGroup(ctx, rdb, queue, RunnerGroup); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	var msg *redis.XMessage
+	var claim Claim
+	for _, queue := range queues {
+		reclaimCursorsMu.Lock()
+		cursor, ok := reclaimCursors[queue]
+		if !ok {
+			cursor = "0-0"
+		}
+		msgs, next, err := rdb.XAutoClaim(ctx, &redis.XAutoClaimArgs{
+			Stream:   queue,
+			Group:    RunnerGroup,
+			Consumer: consumer,
+			MinIdle:  visibility,
+			Start:    cursor,
+			Count:    1,
+		}).Result()
+		if err == nil {
+			reclaimCursors[queue] = next
+		}
+		reclaimCursorsMu.Unlock()
+		if isNoGroup(err) {
+			return nil, Claim{}, ensureQueues()
+		} else if err != nil {
+			return nil, Claim{}, fmt.Errorf("failed to reclaim task: %w", err)
+		}
+		if len(msgs) > 0 {
+			slog.Warn("reclaimed task from unresponsive runner", "message_id", msgs[0].ID, "queue", queue, "runner_id", consumer)
+			msg, claim = &msgs[0], Claim{Queue: queue, ID: msgs[0].ID}
+			break
+		}
+	}
+
+	if msg == nil {
+		streams := make([]string, 0, 2*len(queues))
+		streams = append(streams, queues...)
+		for range queues {
+			streams = append(streams, ">")
+		}
+		res, err := rdb.XReadGroup(ctx, &redis.XReadGroupArgs{
+			Group:    RunnerGroup,
+			Consumer: consumer,
+			Streams:  streams,
+			Count:    1,
+			Block:    block,
+		}).Result()
+		if err == redis.Nil {
+			return nil, Claim{}, nil
+		} else if isNoGroup(err) {
+			return nil, Claim{}, ensureQueues()
+		} else if err != nil {
+			return nil, Claim{}, fmt.Errorf("failed to read task: %w", err)
+		}
+		for _, stream := range res {
+			if len(stream.Messages) > 0 {
+				msg, claim = &stream.Messages[0], Claim{Queue: stream.Stream, ID: stream.Messages[0].ID}
+				break
+			}
+		}
+		if msg == nil {
+			return nil, Claim{}, nil
+		}
+	}
+
+	var task Task
+	payload, _ := msg.Values["payload"].(string)
+	signature, _ := msg.Values["signature"].(string)
+	if err := verifyTask([]byte(payload), signature); err != nil {
+		// whoever wrote the task is not the engine, it must never run
+		_ = AckTask(ctx, rdb, claim)
+		return nil, Claim{}, fmt.Errorf("%w: message %s in %s: %v", ErrTaskRefused, msg.ID, claim.Queue, err)
+	}
+	if err := json.Unmarshal([]byte(payload), &task); err != nil {
+		// a malformed task will never succeed, so drop it instead of letting it be reclaimed forever
+		_ = AckTask(ctx, rdb, claim)
+		return nil, Claim{}, fmt.Errorf("invalid task format: %w", err)
+	}
+	if time.Now().After(task.Deadline) {
+		_ = AckTask(ctx, rdb, claim)
+		return nil, Claim{}, fmt.Errorf("%w: task %s for round %d expired at %s", ErrTaskRefused, task.ID, task.RoundID, task.Deadline.Format(time.RFC3339))
+	}
+	if err := OpenCredentials(&task); err != nil {
+		_ = AckTask(ctx, rdb, claim)
+		return nil, Claim{}, fmt.Errorf("%w: task %s: %v", ErrTaskRefused, task.ID, err)
+	}
+	return &task, claim, nil
+}
+
+// ExtendTask resets the idle time of a claimed task so that it is not reclaimed
+// while its runner is still working on it
+func ExtendTask(ctx context.Context, rdb *redis.Client, consumer string, claim Claim) error {
+	return rdb.XClaimJustID(ctx, &redis.XClaimArgs{
+		Stream:   claim.Queue,
+		Group:    RunnerGroup,
+		Consumer: consumer,
+		Messages: []string{claim.ID},
+	}).Err()
+}
+
+// ReleaseTask hands a claimed task back before its visibility timeout runs out, by
+// marking it as idle for that long so the next runner to look reclaims it straight away
+func ReleaseTask(ctx context.Context, rdb *redis.Client, consumer string, claim Claim, visibility time.Duration) error {
+	return rdb.Do(ctx, "XCLAIM", claim.Queue, RunnerGroup, consumer, 0, claim.ID,
+		"IDLE", visibility.Milliseconds(), "JUSTID").Err()
+}
+
+// AckTask marks a claimed task as done
+func AckTask(ctx context.Context, rdb *redis.Client, claim Claim) error {
+	return rdb.XAck(ctx, claim.Queue, RunnerGroup, claim.ID).Err()
+}
+
+// PushResult hands a check result back to the engine
+func PushResult(ctx context.Context, rdb *redis.Client, result checks.Result) error {
+	payload, err := json.Marshal(result)
+	if err != nil {
+		return fmt.Errorf("failed to marshal result: %w", err)
+	}
+	return rdb.XAdd(ctx, &redis.XAddArgs{
+		Stream: ResultStream,
+		MaxLen: streamMaxLen,
+		Approx: true,
+		Values: map[string]any{"payload": payload},
+	}).Err()
+}
+
+// EnqueueTask hands a task to the runners carrying the tags it requires
+func EnqueueTask(ctx context.Context, rdb *redis.Client, task Task) error {
+	if err := sealCredentials(&task); err != nil {
+		return err
+	}
+	payload, err := json.Marshal(task)
+	if err != nil {
+		return fmt.Errorf("failed to marshal task: %w", err)
+	}
+	queue := TaskQueue(task.Tags)
+	if queue != TaskStream {
+		added, err := rdb.SAdd(ctx, taskQueuesKey, queue).Result()
+		if err != nil {
+			return fmt.Errorf("failed to register task queue %s: %w", queue, err)
+		}
+		if added > 0 {
+			if err := ensureGroup(ctx, rdb, queue, RunnerGroup); err != nil {
+				return err
+			}
+		}
+	}
+	return rdb.XAdd(ctx, &redis.XAddArgs{
+		Stream: queue,
+		MaxLen: streamMaxLen,
+		Approx: true,
+		Values: map[string]any{"payload": payload, "signature": signTask(payload)},
+	}).Err()
+}
+
+// ReadResult waits up to block for the next result from the runners. It returns
+// ErrNoResult when no result arrived in time.
+func (t *RedisTransport) ReadResult(ctx context.Context, block time.Duration) (checks.Result, error) {
+	var result checks.Result
+	streams, err := t.rdb.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group:    engineGroup,
+		Consumer: engineGroup,
+		Streams:  []string{ResultStream, ">"},
+		Count:    1,
+		Block:    block,
+	}).Result()
+	if isNoGroup(err) {
+		if err := EnsureStreams(ctx, t.rdb); err != nil {
+			return result, err
+		}
+		return result, ErrNoResult
+	} else if err != nil {
+		return result, err
+	}
+	if len(streams) == 0 || len(streams[0].Messages) == 0 {
+		return result, ErrNoResult
+	}
+
+	msg := streams[0].Messages[0]
+	// results are only read once, late or malformed ones are not retried
+	t.rdb.XAck(ctx, ResultStream, engineGroup, msg.ID)
+	payload, _ := msg.Values["payload"].(string)
+	if err := json.Unmarshal([]byte(payload), &result); err != nil {
+		return result, fmt.Errorf("%w: %v", errMalformedResult, err)
+	}
+	return result, nil
+}
+
+// ClearStaleTasks drops tasks left over from previous rounds, whether they were
+// never picked up or are still held by a runner, before new ones are enqueued
+func (t *RedisTransport) ClearStaleTasks(ctx context.Context) {
+	if err := EnsureStreams(ctx, t.rdb); err != nil {
+		slog.Error("failed to set up task streams", "error", err)
+		return
+	}
+	queues, err := taskQueues(ctx, t.rdb)
+	if err != nil {
+		slog.Error("failed to list task queues", "error", err)
+		return
+	}
+
+	for _, queue := range queues {
+		pending, err := t.pendingTasks(ctx, queue)
+		if err != nil {
+			slog.Error("failed to read pending tasks", "queue", queue, "error", err)
+		} else if len(pending) > 0 {
+			ids := make([]string, len(pending))
+			for i, p := range pending {
+				ids[i] = p.ID
+			}
+			t.rdb.XAck(ctx, queue, RunnerGroup, ids...)
+		}
+
+		if groups, err := t.rdb.XInfoGroups(ctx, queue).Result(); err == nil {
+			for _, g := range groups {
+				if g.Name == RunnerGroup && g.Lag+int64(len(pending)) > 0 {
+					slog.Warn("Clearing stale tasks from queue", "queue", queue, "unclaimed", g.Lag, "unacknowledged", len(pending))
+				}
+			}
+		}
+		t.rdb.XTrimMaxLen(ctx, queue, 0)
+	}
+}
+
+// pendingTasks returns the tasks on a stream that runners have claimed but not acknowledged
+func (t *RedisTransport) pendingTasks(ctx context.Context, queue string) ([]redis.XPendingExt, error) {
+	pending, err := t.rdb.XPendingExt(ctx, &redis.XPendingExtArgs{
+		Stream: queue,
+		Group:  RunnerGroup,
+		Start:  "-",
+		End:    "+",
+		Count:  streamMaxLen,
+	}).Result()
+	if isNoGroup(err) {
+		return nil, nil
+	}
+	return pending, err
+}
+
+// streamTime returns the time a stream entry was added, taken from its ID
+func streamTime(id string) time.Time {
+	ms, _, _ := strings.Cut(id, "-")
+	n, err := strconv.ParseInt(ms, 10, 64)
+	if err != nil {
+		return time.Time{}
+	}
+	return time.UnixMilli(n)
+}
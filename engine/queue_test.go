@@ -0,0 +1,24 @@
+package engine
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTaskQueueRouting(t *testing.T) {
+	// untagged checks share the default stream
+	assert.Equal(t, TaskStream, TaskQueue(nil))
+	assert.Nil(t, queueTags(TaskStream))
+
+	// the same set of tags always maps to the same stream
+	queue := TaskQueue([]string{"windows-tools", "subnet-a"})
+	assert.Equal(t, "tasks:subnet-a,windows-tools", queue)
+	assert.Equal(t, queue, TaskQueue([]string{"subnet-a", "windows-tools"}))
+	assert.Equal(t, []string{"subnet-a", "windows-tools"}, queueTags(queue))
+
+	// a runner serves a stream only when it carries every tag of it
+	assert.True(t, hasTags([]string{"ipv6", "subnet-a", "windows-tools"}, queueTags(queue)))
+	assert.False(t, hasTags([]string{"subnet-a"}, queueTags(queue)))
+	assert.True(t, hasTags(nil, queueTags(TaskStream)))
+}
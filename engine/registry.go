@@ -0,0 +1,130 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// runnerRegistryKey is the Redis hash of runner ID -> RunnerInfo
+	runnerRegistryKey = "runners"
+
+	// HeartbeatInterval is how often runners refresh their registration
+	HeartbeatInterval = 5 * time.Second
+
+	// a runner that has not heartbeated for this long is considered dead
+	runnerDeadAfter = 3 * HeartbeatInterval
+
+	// dead runners are dropped from the registry after this long
+	runnerForgetAfter = time.Hour
+)
+
+// RunnerInfo is what a runner reports about itself on every heartbeat
+type RunnerInfo struct {
+	ID         string    `json:"id"`
+	Hostname   string    `json:"hostname"`
+	Version    string    `json:"version"`
+	CheckTypes []string  `json:"check_types"`
+	Tags       []string  `json:"tags,omitempty"`
+	InFlight   int       `json:"in_flight"`
+	Refused    int       `json:"refused"` // tasks dropped for a bad signature or a passed deadline
+	Workers    int       `json:"workers"` // most tasks the runner works on at once
+	StartedAt  time.Time `json:"started_at"`
+	LastSeen   time.Time `json:"last_seen"`
+
+	// Healthy is computed by the engine from LastSeen when the registry is read
+	Healthy bool `json:"healthy"`
+}
+
+// Heartbeat registers the runner or refreshes its registration
+func Heartbeat(ctx context.Context, rdb *redis.Client, info RunnerInfo) error {
+	info.LastSeen = time.Now()
+	payload, err := json.Marshal(info)
+	if err != nil {
+		return fmt.Errorf("failed to marshal runner info: %w", err)
+	}
+	return rdb.HSet(ctx, runnerRegistryKey, info.ID, payload).Err()
+}
+
+// Deregister removes a runner that is shutting down cleanly, so it is not
+// reported as dead
+func Deregister(ctx context.Context, rdb *redis.Client, runnerID string) error {
+	return rdb.HDel(ctx, runnerRegistryKey, runnerID).Err()
+}
+
+// GetRunners returns every registered runner sorted by ID, marking the ones that
+// stopped heartbeating as unhealthy and forgetting those that have been gone a while
+func GetRunners(ctx context.Context, rdb *redis.Client) ([]RunnerInfo, error) {
+	entries, err := rdb.HGetAll(ctx, runnerRegistryKey).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get runner registry: %w", err)
+	}
+
+	runners := make([]RunnerInfo, 0, len(entries))
+	for id, payload := range entries {
+		var info RunnerInfo
+		if err := json.Unmarshal([]byte(payload), &info); err != nil {
+			slog.Warn("dropping malformed runner registration", "runner_id", id, "error", err)
+			rdb.HDel(ctx, runnerRegistryKey, id)
+			continue
+		}
+		if forget := checkHealth(&info); forget {
+			rdb.HDel(ctx, runnerRegistryKey, id)
+			continue
+		}
+		runners = append(runners, info)
+	}
+	sortRunners(runners)
+	return runners, nil
+}
+
+// checkHealth marks a runner that stopped heartbeating as unhealthy, and reports
+// whether it has been gone long enough to be forgotten
+func checkHealth(info *RunnerInfo) bool {
+	since := time.Since(info.LastSeen)
+	info.Healthy = since <= runnerDeadAfter
+	return since > runnerForgetAfter
+}
+
+func sortRunners(runners []RunnerInfo) {
+	slices.SortFunc(runners, func(a, b RunnerInfo) int {
+		return strings.Compare(a.ID, b.ID)
+	})
+}
+
+// warnIfNoHealthyRunners logs before a round starts when no runner could pick up
+// its tasks, or when no runner carries the tags some check requires
+func (se *ScoringEngine) warnIfNoHealthyRunners(ctx context.Context) {
+	runners, err := se.Transport.Runners(ctx)
+	if err != nil {
+		slog.Error("failed to check runner health", "error", err)
+		return
+	}
+	healthy := slices.DeleteFunc(runners, func(r RunnerInfo) bool {
+		return !r.Healthy
+	})
+	if len(healthy) == 0 {
+		slog.Warn("No healthy runners are registered, checks this round will likely get no result", "round", se.CurrentRound)
+		return
+	}
+
+	warned := make(map[string]bool)
+	for _, check := range se.Config.AllChecks() {
+		tags := check.GetTags()
+		queue := TaskQueue(tags)
+		if len(tags) == 0 || warned[queue] || !check.Runnable() {
+			continue
+		}
+		if !slices.ContainsFunc(healthy, func(r RunnerInfo) bool { return hasTags(r.Tags, tags) }) {
+			slog.Warn("No healthy runner carries the tags required by a check", "service", check.GetName(), "tags", tags, "round", se.CurrentRound)
+			warned[queue] = true
+		}
+	}
+}
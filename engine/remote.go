@@ -0,0 +1,22 @@
+package engine
+
+// Remote runners that cannot reach Redis lease tasks from the web server instead.
+// These are the request and response bodies of that runner API; tasks and results
+// travel in the same JSON as on the Redis streams.
+
+// LeaseRequest asks the runner API for the next task a runner can serve
+type LeaseRequest struct {
+	Tags []string `json:"tags,omitempty"`
+	// VisibilitySeconds is how long the runner may go without extending the lease
+	// before the task is handed to another runner
+	VisibilitySeconds int `json:"visibility_seconds,omitempty"`
+	// WaitSeconds is how long the server may hold the request open waiting for a task
+	WaitSeconds int `json:"wait_seconds,omitempty"`
+}
+
+// Lease is a task handed to a remote runner. The lease ID stands in for the claim
+// when the runner extends, releases or reports on the task.
+type Lease struct {
+	ID   string `json:"lease"`
+	Task Task   `json:"task"`
+}
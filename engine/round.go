@@ -0,0 +1,90 @@
+package engine
+
+import (
+	"cmp"
+	"errors"
+	"slices"
+
+	"quotient/engine/checks"
+)
+
+var (
+	errUnknownTask   = errors.New("result for a task that was not enqueued this round")
+	errDuplicateTask = errors.New("duplicate result for a task that already reported")
+	errTaskMismatch  = errors.New("result does not match the team and service of its task")
+)
+
+// roundTracker keeps the set of tasks the engine is still waiting on during a
+// round, so that each enqueued task is counted at most once
+type roundTracker struct {
+	pending  map[string]checks.Result // task ID -> placeholder result
+	received map[string]bool
+}
+
+func newRoundTracker() *roundTracker {
+	return &roundTracker{
+		pending:  make(map[string]checks.Result),
+		received: make(map[string]bool),
+	}
+}
+
+// add registers an enqueued task along with the result to record if it never reports back
+func (rt *roundTracker) add(taskID string, placeholder checks.Result) {
+	placeholder.TaskID = taskID
+	rt.pending[taskID] = placeholder
+}
+
+// accept marks the task of a result as reported, rejecting results for unknown
+// tasks, tasks that already reported, or results claiming a different team or service
+func (rt *roundTracker) accept(result checks.Result) error {
+	if rt.received[result.TaskID] {
+		return errDuplicateTask
+	}
+	expected, ok := rt.pending[result.TaskID]
+	if !ok {
+		return errUnknownTask
+	}
+	if expected.TeamID != result.TeamID || expected.ServiceName != result.ServiceName {
+		return errTaskMismatch
+	}
+	delete(rt.pending, result.TaskID)
+	rt.received[result.TaskID] = true
+	return nil
+}
+
+// dependencyDown records a task that was not run because rootCause, a check it
+// depends on, is down this round
+func (rt *roundTracker) dependencyDown(taskID string, rootCause string) checks.Result {
+	result := rt.pending[taskID]
+	delete(rt.pending, taskID)
+	rt.received[taskID] = true
+	result.State = checks.StateDependencyDown
+	result.RootCause = rootCause
+	result.Error = "dependency down"
+	result.Debug = "not run, " + rootCause + " is down this round"
+	return result
+}
+
+func (rt *roundTracker) remaining() int {
+	return len(rt.pending)
+}
+
+// missing returns a "no result" entry for every task that has not reported,
+// so that a slow runner is recorded instead of silently dropped
+func (rt *roundTracker) missing() []checks.Result {
+	missing := make([]checks.Result, 0, len(rt.pending))
+	for _, p := range rt.pending {
+		p.Status = false
+		p.State = checks.StateNoResult
+		p.Error = "no result"
+		p.Debug = "no runner reported a result for this check before the round ended"
+		missing = append(missing, p)
+	}
+	slices.SortFunc(missing, func(a, b checks.Result) int {
+		if a.TeamID != b.TeamID {
+			return cmp.Compare(a.TeamID, b.TeamID)
+		}
+		return cmp.Compare(a.ServiceName, b.ServiceName)
+	})
+	return missing
+}
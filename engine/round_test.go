@@ -0,0 +1,72 @@
+package engine
+
+import (
+	"testing"
+
+	"quotient/engine/checks"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRoundTrackerRejectsUnsolicitedResults(t *testing.T) {
+	tracker := newRoundTracker()
+	tracker.add("task-web-1", checks.Result{TeamID: 1, ServiceName: "box01-web", Points: 10, RoundID: 4})
+	tracker.add("task-ssh-1", checks.Result{TeamID: 1, ServiceName: "box01-ssh", Points: 5, RoundID: 4})
+	tracker.add("task-web-2", checks.Result{TeamID: 2, ServiceName: "box01-web", Points: 10, RoundID: 4})
+	require.Equal(t, 3, tracker.remaining())
+
+	// a real result is accepted once
+	require.NoError(t, tracker.accept(checks.Result{TaskID: "task-web-1", TeamID: 1, ServiceName: "box01-web", Status: true}))
+	assert.Equal(t, 2, tracker.remaining())
+
+	// a retried runner pushing the same task again is rejected
+	assert.ErrorIs(t, tracker.accept(checks.Result{TaskID: "task-web-1", TeamID: 1, ServiceName: "box01-web", Status: true}), errDuplicateTask)
+
+	// results for tasks that were never enqueued are rejected
+	assert.ErrorIs(t, tracker.accept(checks.Result{TaskID: "forged", TeamID: 2, ServiceName: "box01-web", Status: true}), errUnknownTask)
+	assert.ErrorIs(t, tracker.accept(checks.Result{TeamID: 2, ServiceName: "box01-web", Status: true}), errUnknownTask)
+
+	// a result cannot stand in for another team's task
+	assert.ErrorIs(t, tracker.accept(checks.Result{TaskID: "task-web-2", TeamID: 1, ServiceName: "box01-web", Status: true}), errTaskMismatch)
+	assert.Equal(t, 2, tracker.remaining())
+}
+
+func TestRoundTrackerMissing(t *testing.T) {
+	tracker := newRoundTracker()
+	tracker.add("b", checks.Result{TeamID: 2, ServiceName: "box01-web", Points: 10, RoundID: 4})
+	tracker.add("a", checks.Result{TeamID: 1, ServiceName: "box01-ssh", Points: 5, RoundID: 4})
+	tracker.add("c", checks.Result{TeamID: 1, ServiceName: "box01-web", Points: 10, RoundID: 4})
+	require.NoError(t, tracker.accept(checks.Result{TaskID: "c", TeamID: 1, ServiceName: "box01-web", Status: true}))
+
+	missing := tracker.missing()
+	require.Len(t, missing, 2)
+	for _, m := range missing {
+		assert.Equal(t, checks.StateNoResult, m.State)
+		assert.False(t, m.Status)
+		assert.Equal(t, uint(4), m.RoundID)
+	}
+	assert.Equal(t, "a", missing[0].TaskID)
+	assert.Equal(t, 5, missing[0].Points)
+	assert.Equal(t, uint(2), missing[1].TeamID)
+}
+
+func TestRoundTrackerDependencyDown(t *testing.T) {
+	tracker := newRoundTracker()
+	tracker.add("web", checks.Result{TeamID: 1, ServiceName: "box01-web", Points: 10, RoundID: 4})
+
+	result := tracker.dependencyDown("web", "box01-ping")
+	assert.Equal(t, checks.Result{
+		TaskID:      "web",
+		TeamID:      1,
+		ServiceName: "box01-web",
+		Points:      10,
+		RoundID:     4,
+		State:       checks.StateDependencyDown,
+		RootCause:   "box01-ping",
+		Error:       "dependency down",
+		Debug:       "not run, box01-ping is down this round",
+	}, result)
+	assert.Equal(t, 0, tracker.remaining())
+	assert.ErrorIs(t, tracker.accept(checks.Result{TaskID: "web", TeamID: 1, ServiceName: "box01-web"}), errDuplicateTask, "a check that was not run has no result to report")
+}
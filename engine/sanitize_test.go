@@ -4,6 +4,8 @@ import (
 	"testing"
 	"unicode/utf8"
 
+	"quotient/engine/checks"
+
 	"github.com/stretchr/testify/assert"
 	"pgregory.net/rapid"
 )
@@ -173,3 +175,57 @@ func TestPropertySanitizeIsIdempotent(t *testing.T) {
 		assert.Equal(t, result1, result2, "sanitization should be idempotent")
 	})
 }
+
+// TestCredentialReplacer verifies known passwords are scrubbed from check output
+func TestCredentialReplacer(t *testing.T) {
+	replacer := credentialReplacer([]string{"hunter22", "it's-a-secret", "abc", "hunter2222"})
+
+	// as substituted into a custom check command, plainly and shell quoted
+	assert.Equal(t, "sshpass -p [redacted] ssh admin@10.100.11.2", replacer.Replace("sshpass -p hunter22 ssh admin@10.100.11.2"))
+	assert.Equal(t, "login [redacted] failed", replacer.Replace(`login 'it'"'"'s-a-secret' failed`))
+	assert.Equal(t, "password [redacted] rejected", replacer.Replace("password it's-a-secret rejected"))
+
+	// a password containing another is removed whole
+	assert.Equal(t, "[redacted]", replacer.Replace("hunter2222"))
+
+	// very short passwords are left alone rather than mangling the output
+	assert.Equal(t, "abc def", replacer.Replace("abc def"))
+
+	// no credentials, nothing to scrub
+	assert.Equal(t, "output", credentialReplacer(nil).Replace("output"))
+}
+
+// TestTaskScrubResult verifies a runner's result carries none of the task's passwords
+func TestTaskScrubResult(t *testing.T) {
+	task := Task{Credentials: []Credential{{Username: "admin", Password: "hunter22"}, {Username: "root", Password: "rotated-mid-round"}}}
+	result := checks.Result{
+		Error: "login failed",
+		Debug: "creds used were admin:hunter22",
+		Item:  "/login?password=rotated-mid-round",
+		Attempts: []checks.Attempt{
+			{Number: 1, Error: "auth rejected for rotated-mid-round", Debug: "creds used were root:rotated-mid-round"},
+		},
+	}
+
+	task.ScrubResult(&result)
+	assert.Equal(t, "login failed", result.Error)
+	assert.Equal(t, "creds used were admin:[redacted]", result.Debug)
+	assert.Equal(t, "/login?password=[redacted]", result.Item)
+	assert.Equal(t, "auth rejected for [redacted]", result.Attempts[0].Error)
+	assert.Equal(t, "creds used were root:[redacted]", result.Attempts[0].Debug)
+}
+
+// TestPropertyCredentialReplacerRemovesPasswords verifies no scrubbed password survives
+func TestPropertyCredentialReplacerRemovesPasswords(t *testing.T) {
+	rapid.Check(t, func(t *rapid.T) {
+		// upper case only, so the password can never be part of the redaction marker itself
+		password := rapid.StringMatching(`[A-Z0-9!@#$%^&*' ]{4,20}`).Draw(t, "password")
+		prefix := rapid.String().Draw(t, "prefix")
+		suffix := rapid.String().Draw(t, "suffix")
+
+		result := credentialReplacer([]string{password}).Replace(prefix + password + suffix)
+
+		// Property: the password never survives scrubbing
+		assert.NotContains(t, result, password, "scrubbed output must not contain the password")
+	})
+}
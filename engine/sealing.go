@@ -0,0 +1,98 @@
+package engine
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// encryptionKey is the AES-256 key derived from TASK_ENCRYPTION_KEY, which only the
+// engine and runners hold
+func encryptionKey() []byte {
+	secret := os.Getenv("TASK_ENCRYPTION_KEY")
+	if secret == "" {
+		return nil
+	}
+	key := sha256.Sum256([]byte(secret))
+	return key[:]
+}
+
+// EncryptionEnabled reports whether task credentials are encrypted
+func EncryptionEnabled() bool {
+	return encryptionKey() != nil
+}
+
+func credentialCipher(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// sealCredentials moves the credentials of a task into SealedCredentials, encrypted,
+// so that they are never written to Redis in plaintext. Tasks are left as they are
+// when encryption is disabled.
+func sealCredentials(task *Task) error {
+	key := encryptionKey()
+	if key == nil || len(task.Credentials) == 0 {
+		return nil
+	}
+	plaintext, err := json.Marshal(task.Credentials)
+	if err != nil {
+		return fmt.Errorf("failed to marshal credentials: %w", err)
+	}
+	aead, err := credentialCipher(key)
+	if err != nil {
+		return fmt.Errorf("failed to set up credential encryption: %w", err)
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	// the task ID is bound in so sealed credentials cannot be moved onto another task
+	sealed := aead.Seal(nonce, nonce, plaintext, []byte(task.ID))
+	task.SealedCredentials = base64.StdEncoding.EncodeToString(sealed)
+	task.Credentials = nil
+	return nil
+}
+
+// OpenCredentials decrypts the sealed credentials of a task back into Credentials
+func OpenCredentials(task *Task) error {
+	if task.SealedCredentials == "" {
+		return nil
+	}
+	key := encryptionKey()
+	if key == nil {
+		return errors.New("task credentials are encrypted but TASK_ENCRYPTION_KEY is not set")
+	}
+	sealed, err := base64.StdEncoding.DecodeString(task.SealedCredentials)
+	if err != nil {
+		return errors.New("task credentials are malformed")
+	}
+	aead, err := credentialCipher(key)
+	if err != nil {
+		return fmt.Errorf("failed to set up credential encryption: %w", err)
+	}
+	if len(sealed) < aead.NonceSize() {
+		return errors.New("task credentials are malformed")
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, ciphertext, []byte(task.ID))
+	if err != nil {
+		return errors.New("task credentials could not be decrypted")
+	}
+	var creds []Credential
+	if err := json.Unmarshal(plaintext, &creds); err != nil {
+		return fmt.Errorf("failed to unmarshal credentials: %w", err)
+	}
+	task.Credentials = creds
+	task.SealedCredentials = ""
+	return nil
+}
@@ -0,0 +1,59 @@
+package engine
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSealCredentials(t *testing.T) {
+	creds := []Credential{{Username: "admin", Password: "hunter22"}}
+
+	t.Run("disabled", func(t *testing.T) {
+		t.Setenv("TASK_ENCRYPTION_KEY", "")
+		task := Task{ID: "task-1", Credentials: creds}
+		require.NoError(t, sealCredentials(&task))
+		assert.Equal(t, creds, task.Credentials)
+		assert.Empty(t, task.SealedCredentials)
+	})
+
+	t.Run("round trip", func(t *testing.T) {
+		t.Setenv("TASK_ENCRYPTION_KEY", "test-encryption-key")
+		task := Task{ID: "task-1", Credentials: creds}
+		require.NoError(t, sealCredentials(&task))
+		assert.Nil(t, task.Credentials)
+
+		// nothing about the credentials is left readable in the payload
+		payload, err := json.Marshal(task)
+		require.NoError(t, err)
+		assert.NotContains(t, string(payload), "hunter22")
+		assert.NotContains(t, string(payload), "admin")
+
+		var received Task
+		require.NoError(t, json.Unmarshal(payload, &received))
+		require.NoError(t, OpenCredentials(&received))
+		assert.Equal(t, creds, received.Credentials)
+	})
+
+	t.Run("bound to the task", func(t *testing.T) {
+		t.Setenv("TASK_ENCRYPTION_KEY", "test-encryption-key")
+		task := Task{ID: "task-1", Credentials: creds}
+		require.NoError(t, sealCredentials(&task))
+
+		other := Task{ID: "task-2", SealedCredentials: task.SealedCredentials}
+		assert.Error(t, OpenCredentials(&other))
+	})
+
+	t.Run("wrong or missing key", func(t *testing.T) {
+		t.Setenv("TASK_ENCRYPTION_KEY", "test-encryption-key")
+		task := Task{ID: "task-1", Credentials: creds}
+		require.NoError(t, sealCredentials(&task))
+
+		t.Setenv("TASK_ENCRYPTION_KEY", "another-key")
+		assert.Error(t, OpenCredentials(&task))
+		t.Setenv("TASK_ENCRYPTION_KEY", "")
+		assert.Error(t, OpenCredentials(&task))
+	})
+}
@@ -0,0 +1,57 @@
+package engine
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+)
+
+// ErrTaskRefused is returned by ClaimTask for a task a runner must not run: one that
+// is unsigned, fails verification or is past its deadline. Refused tasks are dropped.
+var ErrTaskRefused = errors.New("task refused")
+
+// signingKey is the HMAC key shared by the engine and runners through TASK_SIGNING_KEY
+func signingKey() []byte {
+	return []byte(os.Getenv("TASK_SIGNING_KEY"))
+}
+
+// SigningEnabled reports whether tasks are signed and verified
+func SigningEnabled() bool {
+	return len(signingKey()) > 0
+}
+
+// signTask returns the signature of a task payload, or "" when signing is disabled.
+// The payload carries the task deadline, so a signed task cannot be kept alive past it.
+func signTask(payload []byte) string {
+	key := signingKey()
+	if len(key) == 0 {
+		return ""
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// verifyTask checks the signature of a task payload. Any task is accepted when
+// signing is disabled.
+func verifyTask(payload []byte, signature string) error {
+	key := signingKey()
+	if len(key) == 0 {
+		return nil
+	}
+	if signature == "" {
+		return errors.New("task is not signed")
+	}
+	got, err := hex.DecodeString(signature)
+	if err != nil {
+		return errors.New("task signature is malformed")
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write(payload)
+	if !hmac.Equal(got, mac.Sum(nil)) {
+		return errors.New("task signature does not match")
+	}
+	return nil
+}
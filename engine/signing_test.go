@@ -0,0 +1,33 @@
+package engine
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTaskSigning(t *testing.T) {
+	payload := []byte(`{"id":"task-1","service_type":"Custom","check_data":{"Command":"true"}}`)
+
+	t.Run("disabled", func(t *testing.T) {
+		t.Setenv("TASK_SIGNING_KEY", "")
+		assert.False(t, SigningEnabled())
+		assert.Empty(t, signTask(payload))
+		assert.NoError(t, verifyTask(payload, ""))
+	})
+
+	t.Run("enabled", func(t *testing.T) {
+		t.Setenv("TASK_SIGNING_KEY", "test-signing-key")
+		assert.True(t, SigningEnabled())
+
+		signature := signTask(payload)
+		assert.NoError(t, verifyTask(payload, signature))
+
+		// unsigned, tampered, malformed, and signed with another key
+		assert.Error(t, verifyTask(payload, ""))
+		assert.Error(t, verifyTask([]byte(`{"id":"task-1","service_type":"Custom","check_data":{"Command":"id"}}`), signature))
+		assert.Error(t, verifyTask(payload, "not-hex"))
+		t.Setenv("TASK_SIGNING_KEY", "another-key")
+		assert.Error(t, verifyTask(payload, signature))
+	})
+}
@@ -12,6 +12,7 @@ type Credential struct {
 }
 
 type Task struct {
+	ID             string          `json:"id"`              // Unique per enqueued task, echoed back in checks.Result
 	TeamID         uint            `json:"team_id"`         // Numeric identifier for the team
 	TeamIdentifier string          `json:"team_identifier"` // Human-readable identifier for the team
 	ServiceType    string          `json:"service_type"`
@@ -21,4 +22,9 @@ type Task struct {
 	Attempts       int             `json:"attempts"`
 	CheckData      json.RawMessage `json:"check_data"`
 	Credentials    []Credential    `json:"credentials,omitempty"`
+	Tags           []string        `json:"tags,omitempty"` // Runner tags required to run the check
+	Run            uint            `json:"run,omitempty"`  // How many times the check has run, counting this task
+
+	// Credentials encrypted with TASK_ENCRYPTION_KEY, replacing Credentials while the task is in Redis
+	SealedCredentials string `json:"sealed_credentials,omitempty"`
 }
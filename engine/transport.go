@@ -0,0 +1,235 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"quotient/engine/checks"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// eventsChannel is where the engine announces round ends and resets
+const eventsChannel = "events"
+
+// ErrNoResult is returned by ReadResult when no result arrived in time
+var ErrNoResult = errors.New("no result")
+
+// Transport carries tasks from the engine to the runners and their results back,
+// and keeps track of which runners are alive. Redis is used when the runners are
+// separate processes; MemoryTransport serves runners embedded in the engine.
+type Transport interface {
+	// EnqueueTask hands a task to the runners carrying the tags it requires
+	EnqueueTask(ctx context.Context, task Task) error
+	// ReadResult waits up to block for the next result from the runners
+	ReadResult(ctx context.Context, block time.Duration) (checks.Result, error)
+	// ClearStaleTasks drops tasks left over from previous rounds
+	ClearStaleTasks(ctx context.Context)
+	// Reset drops every task and result
+	Reset(ctx context.Context) error
+	// RunningTasks returns the tasks claimed by a runner but not yet acknowledged
+	RunningTasks(ctx context.Context) ([]checks.Result, error)
+	// RecentResults returns the results pushed since the given time, newest first
+	RecentResults(ctx context.Context, since time.Time) ([]checks.Result, error)
+	// Publish announces an event such as "reset" to every subscriber
+	Publish(ctx context.Context, event string) error
+	// Subscribe returns the events published from now on, until close is called
+	Subscribe(ctx context.Context) (events <-chan string, close func())
+	// Runners returns every registered runner sorted by ID
+	Runners(ctx context.Context) ([]RunnerInfo, error)
+
+	ClaimTask(ctx context.Context, consumer string, tags []string, visibility time.Duration, block time.Duration) (*Task, Claim, error)
+	ExtendTask(ctx context.Context, consumer string, claim Claim) error
+	ReleaseTask(ctx context.Context, consumer string, claim Claim, visibility time.Duration) error
+	AckTask(ctx context.Context, claim Claim) error
+	PushResult(ctx context.Context, result checks.Result) error
+	Heartbeat(ctx context.Context, info RunnerInfo) error
+	Deregister(ctx context.Context, runnerID string) error
+}
+
+// EmbeddedRunners returns how many runners the engine runs in its own process, set
+// with EMBEDDED_RUNNERS. Tasks then never leave the process and Redis is not used.
+func EmbeddedRunners() int {
+	n, err := strconv.Atoi(os.Getenv("EMBEDDED_RUNNERS"))
+	if err != nil || n < 0 {
+		return 0
+	}
+	return n
+}
+
+// RedisAddr returns the address of the Redis server shared by the engine and runners
+func RedisAddr() string {
+	if addr := os.Getenv("REDIS_ADDR"); addr != "" {
+		return addr
+	}
+	return "quotient_redis:6379"
+}
+
+// NewRedisClient connects to the Redis server set with REDIS_ADDR and REDIS_PASSWORD
+func NewRedisClient() *redis.Client {
+	return redis.NewClient(&redis.Options{
+		Addr:     RedisAddr(),
+		Password: os.Getenv("REDIS_PASSWORD"),
+	})
+}
+
+// RedisTransport passes tasks and results over Redis streams
+type RedisTransport struct {
+	rdb *redis.Client
+}
+
+func NewRedisTransport(rdb *redis.Client) *RedisTransport {
+	return &RedisTransport{rdb: rdb}
+}
+
+func (t *RedisTransport) EnqueueTask(ctx context.Context, task Task) error {
+	return EnqueueTask(ctx, t.rdb, task)
+}
+
+func (t *RedisTransport) ClaimTask(ctx context.Context, consumer string, tags []string, visibility time.Duration, block time.Duration) (*Task, Claim, error) {
+	return ClaimTask(ctx, t.rdb, consumer, tags, visibility, block)
+}
+
+func (t *RedisTransport) ExtendTask(ctx context.Context, consumer string, claim Claim) error {
+	return ExtendTask(ctx, t.rdb, consumer, claim)
+}
+
+func (t *RedisTransport) ReleaseTask(ctx context.Context, consumer string, claim Claim, visibility time.Duration) error {
+	return ReleaseTask(ctx, t.rdb, consumer, claim, visibility)
+}
+
+func (t *RedisTransport) AckTask(ctx context.Context, claim Claim) error {
+	return AckTask(ctx, t.rdb, claim)
+}
+
+func (t *RedisTransport) PushResult(ctx context.Context, result checks.Result) error {
+	return PushResult(ctx, t.rdb, result)
+}
+
+func (t *RedisTransport) Heartbeat(ctx context.Context, info RunnerInfo) error {
+	return Heartbeat(ctx, t.rdb, info)
+}
+
+func (t *RedisTransport) Deregister(ctx context.Context, runnerID string) error {
+	return Deregister(ctx, t.rdb, runnerID)
+}
+
+func (t *RedisTransport) Runners(ctx context.Context) ([]RunnerInfo, error) {
+	return GetRunners(ctx, t.rdb)
+}
+
+func (t *RedisTransport) Publish(ctx context.Context, event string) error {
+	return t.rdb.Publish(ctx, eventsChannel, event).Err()
+}
+
+func (t *RedisTransport) Subscribe(ctx context.Context) (<-chan string, func()) {
+	sub := t.rdb.Subscribe(ctx, eventsChannel)
+	events := make(chan string)
+	done := make(chan struct{})
+	go func() {
+		defer close(events)
+		for msg := range sub.Channel() {
+			select {
+			case events <- msg.Payload:
+			case <-done:
+				return
+			}
+		}
+	}()
+	return events, func() {
+		close(done)
+		sub.Close()
+	}
+}
+
+// Reset deletes every task stream and the result stream, then recreates them empty
+func (t *RedisTransport) Reset(ctx context.Context) error {
+	queues, err := taskQueues(ctx, t.rdb)
+	if err != nil {
+		return err
+	}
+	keysToDelete := append(queues, taskQueuesKey, ResultStream)
+	for _, key := range keysToDelete {
+		if err := t.rdb.Del(ctx, key).Err(); err != nil {
+			return fmt.Errorf("failed to clear Redis queue %s: %w", key, err)
+		}
+	}
+	return EnsureStreams(ctx, t.rdb)
+}
+
+func (t *RedisTransport) RunningTasks(ctx context.Context) ([]checks.Result, error) {
+	queues, err := taskQueues(ctx, t.rdb)
+	if err != nil {
+		return nil, err
+	}
+	var running []checks.Result
+	for _, queue := range queues {
+		pending, err := t.pendingTasks(ctx, queue)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get pending tasks: %w", err)
+		}
+		if len(pending) == 0 {
+			continue
+		}
+
+		// Use a single pipeline to get all task data in one round-trip
+		pipe := t.rdb.Pipeline()
+		cmds := make([]*redis.XMessageSliceCmd, len(pending))
+		for i, p := range pending {
+			cmds[i] = pipe.XRangeN(ctx, queue, p.ID, p.ID, 1)
+		}
+		if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+			return nil, fmt.Errorf("failed to execute pipeline: %w", err)
+		}
+
+		for i, p := range pending {
+			msgs, err := cmds[i].Result()
+			if err != nil || len(msgs) == 0 {
+				continue // Skip tasks trimmed from the stream since they were claimed
+			}
+			payload, _ := msgs[0].Values["payload"].(string)
+			var task Task
+			if err := json.Unmarshal([]byte(payload), &task); err != nil {
+				continue // Skip if we can't parse the JSON
+			}
+			running = append(running, runningTask(task, p.Consumer, streamTime(p.ID)))
+		}
+	}
+	return running, nil
+}
+
+func (t *RedisTransport) RecentResults(ctx context.Context, since time.Time) ([]checks.Result, error) {
+	msgs, err := t.rdb.XRevRangeN(ctx, ResultStream, "+", strconv.FormatInt(since.UnixMilli(), 10), 1000).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get recent results: %w", err)
+	}
+	results := make([]checks.Result, 0, len(msgs))
+	for _, msg := range msgs {
+		payload, _ := msg.Values["payload"].(string)
+		var result checks.Result
+		if err := json.Unmarshal([]byte(payload), &result); err != nil {
+			continue // Skip if we can't parse the JSON
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// runningTask describes a task a runner is working on, for the admin task view
+func runningTask(task Task, runnerID string, started time.Time) checks.Result {
+	return checks.Result{
+		TaskID:      task.ID,
+		TeamID:      task.TeamID,
+		ServiceName: task.ServiceName,
+		ServiceType: task.ServiceType,
+		RoundID:     task.RoundID,
+		RunnerID:    runnerID,
+		StartTime:   started.Format(time.RFC3339),
+		StatusText:  "running",
+	}
+}
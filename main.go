@@ -1,14 +1,18 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"log"
 	"log/slog"
 	"os"
+	"os/signal"
+	"syscall"
 
 	"quotient/engine"
 	"quotient/engine/config"
 	"quotient/engine/db"
+	"quotient/runner/worker"
 	"quotient/www"
 )
 
@@ -52,6 +56,25 @@ func main() {
 		log.Fatalln("Failed to add teams to DB:", err)
 	}
 
+	// ctx is cancelled on SIGTERM, draining the embedded runners before exiting
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer stop()
+
+	// run the checks in this process when no separate runners are deployed
+	runnerConf := worker.ConfigFromEnv()
+	runnerConf.Version = "embedded"
+	embedded := make(chan struct{})
+	go func() {
+		defer close(embedded)
+		worker.RunEmbedded(ctx, se.Transport, runnerConf, engine.EmbeddedRunners())
+	}()
+	go func() {
+		<-ctx.Done()
+		slog.Info("signal received, draining embedded runners")
+		<-embedded
+		os.Exit(0)
+	}()
+
 	// start engine, restart if it stops
 	go func() {
 		for {
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"bytes"
+	"log/slog"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"syscall"
+)
+
+// forwardSignals relays termination signals received by the reaper's parent process
+// to the runner it forked. The runner is started in its own session, so a docker stop
+// would otherwise tear it down without giving it a chance to drain.
+func forwardSignals() {
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGTERM, os.Interrupt)
+	for sig := range sigs {
+		for _, pid := range childPIDs() {
+			if err := syscall.Kill(pid, sig.(syscall.Signal)); err != nil {
+				slog.Error("failed to forward signal to runner", "pid", pid, "signal", sig, "error", err)
+			}
+		}
+	}
+}
+
+// childPIDs returns the processes whose parent is this process
+func childPIDs() []int {
+	stats, err := filepath.Glob("/proc/[0-9]*/stat")
+	if err != nil {
+		return nil
+	}
+
+	self := os.Getpid()
+	var pids []int
+	for _, path := range stats {
+		stat, err := os.ReadFile(path) // #nosec G304 -- paths come from the /proc glob above
+		if err != nil {
+			continue
+		}
+		// the command name can contain spaces, so the fields after it start past the last ')'
+		end := bytes.LastIndexByte(stat, ')')
+		if end < 0 {
+			continue
+		}
+		fields := bytes.Fields(stat[end+1:])
+		if len(fields) < 2 {
+			continue
+		}
+		ppid, err := strconv.Atoi(string(fields[1]))
+		if err != nil || ppid != self {
+			continue
+		}
+		pid, err := strconv.Atoi(filepath.Base(filepath.Dir(path)))
+		if err != nil {
+			continue
+		}
+		pids = append(pids, pid)
+	}
+	return pids
+}
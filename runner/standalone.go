@@ -0,0 +1,214 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+
+	"quotient/engine"
+	"quotient/engine/checks"
+	"quotient/engine/config"
+	"quotient/runner/worker"
+)
+
+// standalone mode runs a single check and prints its result, without Redis
+var standalone struct {
+	taskFile       string
+	configPath     string
+	box            string
+	service        string
+	teamID         uint
+	teamIdentifier string
+	round          uint
+	timeout        time.Duration
+}
+
+func parseFlags() {
+	flag.StringVar(&standalone.taskFile, "task", "", "Run a single task read from this JSON file, or - for stdin, and print its result")
+	flag.StringVar(&standalone.configPath, "config", "./config/event.conf", "Event config to find the check selected with -box and -service in")
+	flag.StringVar(&standalone.box, "box", "", "Box of the check to run once and print the result of")
+	flag.StringVar(&standalone.service, "service", "", "Service of the check to run once, by its name (box-display) or display name")
+	flag.UintVar(&standalone.teamID, "team-id", 1, "Team ID to run the check for")
+	flag.StringVar(&standalone.teamIdentifier, "team-identifier", "", "Team identifier substituted into the check's target")
+	flag.UintVar(&standalone.round, "round", 1, "Round number passed to the check")
+	flag.DurationVar(&standalone.timeout, "timeout", time.Minute, "How long every attempt of the check may take altogether")
+	flag.Parse()
+}
+
+func standaloneRequested() bool {
+	return standalone.taskFile != "" || standalone.box != "" || standalone.service != ""
+}
+
+// runStandalone runs one check through the same path as tasks from Redis and prints
+// the full result to stdout. It exits 0 when the check passed.
+func runStandalone() int {
+	var task *engine.Task
+	var err error
+	if standalone.taskFile != "" {
+		task, err = readTask(standalone.taskFile)
+	} else {
+		task, err = taskFromConfig()
+	}
+	if err != nil {
+		slog.Error("failed to load task", "error", err)
+		return 2
+	}
+
+	runner, err := worker.CreateRunner(task)
+	if err != nil {
+		slog.Error("failed to create runner", "error", err)
+		return 2
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer stop()
+
+	w := worker.New(nil, worker.Config{ID: "standalone", MaxPerTarget: worker.DefaultMaxPerTarget})
+	result, finished := w.RunCheck(ctx, runner, task)
+	if !finished {
+		slog.Error("interrupted before the check finished")
+		return 2
+	}
+
+	out := json.NewEncoder(os.Stdout)
+	out.SetIndent("", "  ")
+	if err := out.Encode(result); err != nil {
+		slog.Error("failed to print result", "error", err)
+		return 2
+	}
+	if !result.Status {
+		return 1
+	}
+	return 0
+}
+
+// readTask loads a task as the engine enqueues it, from a file or stdin
+func readTask(path string) (*engine.Task, error) {
+	var raw []byte
+	var err error
+	if path == "-" {
+		raw, err = io.ReadAll(os.Stdin)
+	} else {
+		raw, err = os.ReadFile(path) // #nosec G304 -- path is given by the operator on the command line
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read task: %w", err)
+	}
+
+	var task engine.Task
+	if err := json.Unmarshal(raw, &task); err != nil {
+		return nil, fmt.Errorf("invalid task format: %w", err)
+	}
+	if err := engine.OpenCredentials(&task); err != nil {
+		return nil, err
+	}
+	if task.ID == "" {
+		task.ID = "standalone"
+	}
+	if task.Attempts == 0 {
+		task.Attempts = 1
+	}
+	// a task copied out of Redis is usually past its round, give it a fresh deadline
+	if time.Until(task.Deadline) < time.Second {
+		task.Deadline = time.Now().Add(standalone.timeout)
+	}
+	return &task, nil
+}
+
+// taskFromConfig builds the task the engine would enqueue for the selected check
+func taskFromConfig() (*engine.Task, error) {
+	if standalone.box == "" || standalone.service == "" {
+		return nil, errors.New("both -box and -service are required to select a check")
+	}
+
+	conf := config.ConfigSettings{}
+	if err := conf.SetConfig(standalone.configPath); err != nil {
+		return nil, err
+	}
+
+	boxIndex := slices.IndexFunc(conf.Box, func(b config.Box) bool { return b.Name == standalone.box })
+	if boxIndex < 0 {
+		return nil, fmt.Errorf("no box named %q in %s", standalone.box, standalone.configPath)
+	}
+	box := conf.Box[boxIndex]
+	checkIndex := slices.IndexFunc(box.Runners, func(r checks.Runner) bool {
+		return r.GetName() == standalone.service || r.GetName() == box.Name+"-"+standalone.service
+	})
+	if checkIndex < 0 {
+		return nil, fmt.Errorf("no service %q on box %q", standalone.service, box.Name)
+	}
+	check := box.Runners[checkIndex]
+
+	if strings.Contains(check.GetTarget(), "_") && standalone.teamIdentifier == "" {
+		return nil, fmt.Errorf("target %s is templated per team, -team-identifier is required", check.GetTarget())
+	}
+
+	data, err := json.Marshal(check)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal check definition: %w", err)
+	}
+	task := &engine.Task{
+		ID:             "standalone",
+		TeamID:         standalone.teamID,
+		TeamIdentifier: standalone.teamIdentifier,
+		ServiceType:    check.GetType(),
+		ServiceName:    check.GetName(),
+		RoundID:        standalone.round,
+		Deadline:       time.Now().Add(standalone.timeout),
+		Attempts:       check.GetAttempts(),
+		CheckData:      data,
+		Tags:           check.GetTags(),
+	}
+
+	// without the database only the original credentials from the credlist files are known
+	for _, credlist := range check.GetCredlists() {
+		creds, err := readCredlist(filepath.Join(filepath.Dir(standalone.configPath), "credlists"), credlist)
+		if err != nil {
+			return nil, err
+		}
+		task.Credentials = append(task.Credentials, creds...)
+	}
+	if len(check.GetCredlists()) > 0 && len(task.Credentials) == 0 {
+		return nil, fmt.Errorf("check %s requires credentials but its credlists are empty", check.GetName())
+	}
+	return task, nil
+}
+
+// readCredlist reads the username,password rows of a credlist file
+func readCredlist(dir string, name string) ([]engine.Credential, error) {
+	root, err := os.OpenRoot(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open credlist directory: %w", err)
+	}
+	defer root.Close()
+	file, err := root.Open(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open credlist %s: %w", name, err)
+	}
+	defer file.Close()
+
+	records, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read credlist %s: %w", name, err)
+	}
+	var creds []engine.Credential
+	for _, record := range records {
+		if len(record) != 2 {
+			continue
+		}
+		creds = append(creds, engine.Credential{Username: record[0], Password: record[1]})
+	}
+	return creds, nil
+}
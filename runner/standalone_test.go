@@ -0,0 +1,50 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadTask(t *testing.T) {
+	standalone.timeout = time.Minute
+	path := filepath.Join(t.TempDir(), "task.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{
+		"team_id": 3,
+		"team_identifier": "03",
+		"service_type": "Tcp",
+		"service_name": "web01-tcp",
+		"deadline": "2020-01-01T00:00:00Z",
+		"check_data": {"Port": 22}
+	}`), 0o600))
+
+	task, err := readTask(path)
+	require.NoError(t, err)
+	assert.Equal(t, "standalone", task.ID)
+	assert.Equal(t, 1, task.Attempts)
+	assert.Equal(t, "03", task.TeamIdentifier)
+	// a deadline from a past round is replaced so the check gets to run
+	assert.WithinDuration(t, time.Now().Add(time.Minute), task.Deadline, 5*time.Second)
+
+	_, err = readTask(filepath.Join(t.TempDir(), "missing.json"))
+	assert.Error(t, err)
+}
+
+func TestReadCredlist(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "users.credlist"), []byte("alice,password1\nbob,password2\n"), 0o600))
+
+	creds, err := readCredlist(dir, "users.credlist")
+	require.NoError(t, err)
+	require.Len(t, creds, 2)
+	assert.Equal(t, "alice", creds[0].Username)
+	assert.Equal(t, "password2", creds[1].Password)
+
+	// credlists cannot be read from outside their directory
+	_, err = readCredlist(dir, "../users.credlist")
+	assert.Error(t, err)
+}
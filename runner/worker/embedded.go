@@ -0,0 +1,58 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+
+	"quotient/engine"
+)
+
+// RunEmbedded runs n runners inside the engine until ctx is cancelled, draining
+// them first. An engine reset drains them too, the way it does separate runners,
+// after which they start again with a clean slate.
+func RunEmbedded(ctx context.Context, transport engine.Transport, conf Config, n int) {
+	if n == 0 {
+		return
+	}
+	events, closeEvents := transport.Subscribe(ctx)
+	defer closeEvents()
+
+	for ctx.Err() == nil {
+		runCtx, drain := context.WithCancel(ctx)
+		var running sync.WaitGroup
+		for i := range n {
+			runnerConf := conf
+			runnerConf.ID = fmt.Sprintf("embedded-%d", i+1)
+			running.Add(1)
+			go func() {
+				defer running.Done()
+				New(transport, runnerConf).Run(runCtx)
+			}()
+		}
+
+		waitForReset(ctx, events)
+		slog.Info("draining embedded runners", "runners", n)
+		drain()
+		running.Wait()
+	}
+}
+
+// waitForReset returns once the engine publishes a reset or ctx is cancelled
+func waitForReset(ctx context.Context, events <-chan string) {
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				<-ctx.Done()
+				return
+			}
+			if event == "reset" {
+				return
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package worker
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"quotient/engine"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunEmbeddedDrainsOnResetAndShutdown(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	transport := engine.NewMemoryTransport()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		RunEmbedded(ctx, transport, Config{Workers: 1, VisibilityTimeout: time.Minute}, 2)
+	}()
+
+	var started time.Time
+	require.Eventually(t, func() bool {
+		runners, err := transport.Runners(ctx)
+		if err != nil || len(runners) != 2 {
+			return false
+		}
+		started = runners[0].StartedAt
+		return true
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// a reset drains the runners and starts them again
+	require.NoError(t, transport.Publish(ctx, "reset"))
+	assert.Eventually(t, func() bool {
+		runners, err := transport.Runners(ctx)
+		return err == nil && len(runners) == 2 && runners[0].StartedAt.After(started)
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// shutting down drains them for good
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("embedded runners did not drain")
+	}
+	runners, err := transport.Runners(context.Background())
+	require.NoError(t, err)
+	assert.Empty(t, runners)
+}
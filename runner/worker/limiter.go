@@ -0,0 +1,43 @@
+package worker
+
+import (
+	"context"
+	"sync"
+)
+
+// targetLimiter caps how many checks run against the same target at once
+type targetLimiter struct {
+	max   int // zero means no limit
+	mu    sync.Mutex
+	slots map[string]chan struct{}
+}
+
+func newTargetLimiter(max int) *targetLimiter {
+	return &targetLimiter{
+		max:   max,
+		slots: make(map[string]chan struct{}),
+	}
+}
+
+// acquire waits for a free slot on target and returns the function that frees it,
+// or the context error if the context ends first
+func (l *targetLimiter) acquire(ctx context.Context, target string) (func(), error) {
+	if l.max <= 0 {
+		return func() {}, nil
+	}
+
+	l.mu.Lock()
+	sem, ok := l.slots[target]
+	if !ok {
+		sem = make(chan struct{}, l.max)
+		l.slots[target] = sem
+	}
+	l.mu.Unlock()
+
+	select {
+	case sem <- struct{}{}:
+		return func() { <-sem }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
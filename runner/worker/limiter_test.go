@@ -0,0 +1,43 @@
+package worker
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTargetLimiterCapsPerTarget(t *testing.T) {
+	limiter := newTargetLimiter(2)
+	ctx := context.Background()
+
+	release1, err := limiter.acquire(ctx, "10.100.11.2")
+	require.NoError(t, err)
+	_, err = limiter.acquire(ctx, "10.100.11.2")
+	require.NoError(t, err)
+
+	// other targets are not affected by a busy one
+	_, err = limiter.acquire(ctx, "10.100.12.2")
+	require.NoError(t, err)
+
+	// a third check against the busy target waits until the round would end
+	waitCtx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	_, err = limiter.acquire(waitCtx, "10.100.11.2")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// and gets through once a slot is freed
+	release1()
+	_, err = limiter.acquire(ctx, "10.100.11.2")
+	assert.NoError(t, err)
+}
+
+func TestTargetLimiterUnlimited(t *testing.T) {
+	limiter := newTargetLimiter(0)
+	for range 100 {
+		_, err := limiter.acquire(context.Background(), "10.100.11.2")
+		require.NoError(t, err)
+	}
+}
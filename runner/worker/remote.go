@@ -0,0 +1,165 @@
+package worker
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"quotient/engine"
+	"quotient/engine/checks"
+)
+
+// errUnauthorized is returned when the server does not accept the runner token,
+// because it is wrong or has been revoked
+var errUnauthorized = errors.New("runner token was not accepted by the server")
+
+// RemoteTransport leases tasks from the runner API of the web server, for runners
+// that cannot reach Redis. Leases stand in for the claims on the task streams.
+type RemoteTransport struct {
+	serverURL string
+	token     string
+	client    *http.Client
+
+	mu sync.Mutex
+	// leases maps the ID of every task being worked on to its lease, so that its
+	// result is reported against it
+	leases map[string]string
+}
+
+func NewRemoteTransport(serverURL string, token string) *RemoteTransport {
+	return &RemoteTransport{
+		serverURL: strings.TrimSuffix(serverURL, "/"),
+		token:     token,
+		// the server holds lease requests open for a while waiting for a task
+		client: &http.Client{Timeout: time.Minute},
+		leases: make(map[string]string),
+	}
+}
+
+// post sends body to the runner API and decodes a successful response into out. It
+// returns the response status, with an error for anything but 200 and 204.
+func (t *RemoteTransport) post(ctx context.Context, path string, body any, out any) (int, error) {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return 0, fmt.Errorf("failed to marshal request: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.serverURL+path, bytes.NewReader(payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Authorization", "Bearer "+t.token)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		if out != nil {
+			if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+				return resp.StatusCode, fmt.Errorf("invalid response from %s: %w", path, err)
+			}
+		}
+		return resp.StatusCode, nil
+	case http.StatusNoContent:
+		return resp.StatusCode, nil
+	case http.StatusUnauthorized:
+		return resp.StatusCode, errUnauthorized
+	}
+
+	var failure struct {
+		Error string `json:"error"`
+	}
+	raw, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	if json.Unmarshal(raw, &failure) != nil || failure.Error == "" {
+		failure.Error = resp.Status
+	}
+	return resp.StatusCode, errors.New(failure.Error)
+}
+
+func leasePath(leaseID string, action string) string {
+	return "/api/runner/tasks/" + url.PathEscape(leaseID) + "/" + action
+}
+
+func (t *RemoteTransport) ClaimTask(ctx context.Context, consumer string, tags []string, visibility time.Duration, block time.Duration) (*engine.Task, engine.Claim, error) {
+	req := engine.LeaseRequest{
+		Tags:              tags,
+		VisibilitySeconds: int(visibility.Seconds()),
+		WaitSeconds:       max(int(block.Seconds()), 1),
+	}
+	var lease engine.Lease
+	status, err := t.post(ctx, "/api/runner/tasks/lease", req, &lease)
+	if status == http.StatusUnprocessableEntity {
+		return nil, engine.Claim{}, fmt.Errorf("%w: %s", engine.ErrTaskRefused, strings.TrimPrefix(err.Error(), engine.ErrTaskRefused.Error()+": "))
+	} else if err != nil {
+		return nil, engine.Claim{}, fmt.Errorf("failed to lease task: %w", err)
+	}
+	if status == http.StatusNoContent {
+		return nil, engine.Claim{}, nil
+	}
+
+	t.mu.Lock()
+	t.leases[lease.Task.ID] = lease.ID
+	t.mu.Unlock()
+	return &lease.Task, engine.Claim{ID: lease.ID}, nil
+}
+
+func (t *RemoteTransport) ExtendTask(ctx context.Context, consumer string, claim engine.Claim) error {
+	_, err := t.post(ctx, leasePath(claim.ID, "extend"), nil, nil)
+	return err
+}
+
+func (t *RemoteTransport) ReleaseTask(ctx context.Context, consumer string, claim engine.Claim, visibility time.Duration) error {
+	t.forget(claim)
+	_, err := t.post(ctx, leasePath(claim.ID, "release"), nil, nil)
+	return err
+}
+
+func (t *RemoteTransport) AckTask(ctx context.Context, claim engine.Claim) error {
+	t.forget(claim)
+	_, err := t.post(ctx, leasePath(claim.ID, "ack"), nil, nil)
+	return err
+}
+
+// forget drops the lease of a task that is no longer being worked on
+func (t *RemoteTransport) forget(claim engine.Claim) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for taskID, leaseID := range t.leases {
+		if leaseID == claim.ID {
+			delete(t.leases, taskID)
+		}
+	}
+}
+
+func (t *RemoteTransport) PushResult(ctx context.Context, result checks.Result) error {
+	t.mu.Lock()
+	leaseID, ok := t.leases[result.TaskID]
+	t.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("no lease held for task %s", result.TaskID)
+	}
+	_, err := t.post(ctx, leasePath(leaseID, "result"), result, nil)
+	return err
+}
+
+func (t *RemoteTransport) Heartbeat(ctx context.Context, info engine.RunnerInfo) error {
+	_, err := t.post(ctx, "/api/runner/heartbeat", info, nil)
+	return err
+}
+
+func (t *RemoteTransport) Deregister(ctx context.Context, runnerID string) error {
+	_, err := t.post(ctx, "/api/runner/deregister", nil, nil)
+	return err
+}
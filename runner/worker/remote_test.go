@@ -0,0 +1,83 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"quotient/engine"
+	"quotient/engine/checks"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRemoteTransportLeasesTasks(t *testing.T) {
+	var reported checks.Result
+	leases := 0
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/runner/tasks/lease", func(w http.ResponseWriter, r *http.Request) {
+		var req engine.LeaseRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		assert.Equal(t, []string{"dmz"}, req.Tags)
+		assert.Equal(t, 30, req.VisibilitySeconds)
+		assert.Positive(t, req.WaitSeconds)
+
+		leases++
+		switch leases {
+		case 1:
+			w.WriteHeader(http.StatusNoContent)
+		case 2:
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			json.NewEncoder(w).Encode(map[string]any{"error": "task refused: task-1 expired"})
+		default:
+			json.NewEncoder(w).Encode(engine.Lease{ID: "lease-1", Task: engine.Task{ID: "task-2", ServiceType: "Tcp"}})
+		}
+	})
+	mux.HandleFunc("POST /api/runner/tasks/{lease}/result", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "lease-1", r.PathValue("lease"))
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&reported))
+		w.WriteHeader(http.StatusNoContent)
+	})
+	mux.HandleFunc("POST /api/runner/tasks/{lease}/ack", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	transport := NewRemoteTransport(server.URL+"/", "secret")
+
+	task, _, err := transport.ClaimTask(ctx, "remote-1", []string{"dmz"}, 30*time.Second, 0)
+	require.NoError(t, err)
+	assert.Nil(t, task, "no task should be leased when the server has none")
+
+	_, _, err = transport.ClaimTask(ctx, "remote-1", []string{"dmz"}, 30*time.Second, 0)
+	require.ErrorIs(t, err, engine.ErrTaskRefused)
+	assert.Equal(t, "task refused: task-1 expired", err.Error())
+
+	task, claim, err := transport.ClaimTask(ctx, "remote-1", []string{"dmz"}, 30*time.Second, 0)
+	require.NoError(t, err)
+	require.NotNil(t, task)
+	assert.Equal(t, "task-2", task.ID)
+	assert.Equal(t, "lease-1", claim.ID)
+
+	require.NoError(t, transport.PushResult(ctx, checks.Result{TaskID: "task-2", Status: true}))
+	assert.Equal(t, "task-2", reported.TaskID)
+	assert.True(t, reported.Status)
+
+	require.NoError(t, transport.AckTask(ctx, claim))
+	assert.Error(t, transport.PushResult(ctx, checks.Result{TaskID: "task-2"}), "results need a lease")
+
+	_, _, err = NewRemoteTransport(server.URL, "wrong").ClaimTask(ctx, "remote-1", nil, 30*time.Second, 0)
+	assert.ErrorIs(t, err, errUnauthorized)
+}
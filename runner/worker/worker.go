@@ -0,0 +1,506 @@
+// Package worker claims check tasks from the engine, runs them and reports their
+// results. It runs in the runner containers, and inside the engine itself when
+// the runners are embedded.
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"quotient/engine"
+	"quotient/engine/checks"
+	"quotient/engine/config"
+)
+
+const (
+	// DefaultWorkers is how many checks a runner runs at once unless configured
+	DefaultWorkers = 20
+
+	// DefaultMaxPerTarget is how many checks run against the same target at once
+	// unless configured
+	DefaultMaxPerTarget = 4
+
+	// DefaultVisibilityTimeout is how long a claimed task may go without a sign of
+	// life before another runner takes it over
+	DefaultVisibilityTimeout = 30 * time.Second
+)
+
+// how long a draining runner waits for a cancelled check to clean up after itself
+var drainTimeout = 5 * time.Second
+
+// Config describes a runner as it registers with the engine
+type Config struct {
+	ID string
+	// Tags advertised by this runner; it only takes tasks whose required tags it all carries
+	Tags []string
+	// Workers is the number of checks run at once
+	Workers int
+	// MaxPerTarget limits how many checks run against the same target at once, zero for no limit
+	MaxPerTarget      int
+	VisibilityTimeout time.Duration
+	Version           string
+}
+
+// Transport is how a runner gets its tasks and hands back their results: Redis,
+// the engine's in-process queue, or the runner API of the web server
+type Transport interface {
+	ClaimTask(ctx context.Context, consumer string, tags []string, visibility time.Duration, block time.Duration) (*engine.Task, engine.Claim, error)
+	ExtendTask(ctx context.Context, consumer string, claim engine.Claim) error
+	ReleaseTask(ctx context.Context, consumer string, claim engine.Claim, visibility time.Duration) error
+	AckTask(ctx context.Context, claim engine.Claim) error
+	PushResult(ctx context.Context, result checks.Result) error
+	Heartbeat(ctx context.Context, info engine.RunnerInfo) error
+	Deregister(ctx context.Context, runnerID string) error
+}
+
+// Worker runs the checks handed to one runner
+type Worker struct {
+	transport Transport
+	conf      Config
+
+	// limits how many checks run against the same target at once
+	targets *targetLimiter
+
+	// number of tasks this runner is currently working on
+	inFlight atomic.Int64
+
+	// number of tasks this runner refused to run, reported in heartbeats
+	refused atomic.Int64
+}
+
+// ConfigFromEnv reads a runner's settings from the RUNNER_* environment variables,
+// falling back to the defaults for unset or invalid ones
+func ConfigFromEnv() Config {
+	conf := Config{
+		ID:                os.Getenv("RUNNER_ID"),
+		Workers:           DefaultWorkers,
+		MaxPerTarget:      DefaultMaxPerTarget,
+		VisibilityTimeout: DefaultVisibilityTimeout,
+	}
+
+	// Use environment variable RUNNER_ID if set, otherwise use hostname
+	if conf.ID == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			hostname = "unknown"
+		}
+		conf.ID = hostname
+	}
+
+	if v := os.Getenv("TASK_VISIBILITY_TIMEOUT"); v != "" {
+		seconds, err := strconv.Atoi(v)
+		if err != nil || seconds <= 0 {
+			slog.Error("invalid TASK_VISIBILITY_TIMEOUT, using default", "value", v, "default", conf.VisibilityTimeout)
+		} else {
+			conf.VisibilityTimeout = time.Duration(seconds) * time.Second
+		}
+	}
+
+	if v := os.Getenv("RUNNER_WORKERS"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			slog.Error("invalid RUNNER_WORKERS, using default", "value", v, "default", conf.Workers)
+		} else {
+			conf.Workers = n
+		}
+	}
+
+	if v := os.Getenv("RUNNER_MAX_PER_TARGET"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			slog.Error("invalid RUNNER_MAX_PER_TARGET, using default", "value", v, "default", conf.MaxPerTarget)
+		} else {
+			conf.MaxPerTarget = n
+		}
+	}
+
+	tags, err := config.NormalizeTags(strings.Split(os.Getenv("RUNNER_TAGS"), ","))
+	if err != nil {
+		slog.Error("ignoring invalid RUNNER_TAGS", "error", err)
+	}
+	conf.Tags = tags
+	return conf
+}
+
+func New(transport Transport, conf Config) *Worker {
+	return &Worker{
+		transport: transport,
+		conf:      conf,
+		targets:   newTargetLimiter(conf.MaxPerTarget),
+	}
+}
+
+// Run claims and runs tasks until ctx is cancelled. The checks still running are
+// then cancelled and their tasks handed back, before the runner deregisters.
+func (w *Worker) Run(ctx context.Context) {
+	heartbeatDone := make(chan struct{})
+	go func() {
+		defer close(heartbeatDone)
+		w.heartbeat(ctx)
+	}()
+
+	// Each task holds a worker slot until its result is pushed. While every slot is
+	// taken the runner stops claiming tasks, leaving them to runners with capacity.
+	pool := make(chan struct{}, w.conf.Workers)
+	var running sync.WaitGroup
+	for ctx.Err() == nil {
+		select {
+		case pool <- struct{}{}:
+		case <-ctx.Done():
+			continue
+		}
+
+		task, claim, err := w.nextTask(ctx)
+		if ctx.Err() != nil {
+			<-pool
+			if task != nil {
+				w.releaseTask(context.WithoutCancel(ctx), task, claim)
+			}
+			continue
+		}
+		if errors.Is(err, engine.ErrTaskRefused) {
+			<-pool
+			w.refused.Add(1)
+			slog.Error("refused task", "error", err)
+			continue
+		}
+		if err != nil {
+			<-pool
+			slog.Error("error getting task", "error", err)
+			time.Sleep(time.Second)
+			continue
+		}
+		if task == nil {
+			<-pool
+			continue
+		}
+
+		runner, err := CreateRunner(task)
+		if err != nil {
+			<-pool
+			slog.Error("error creating runner", "error", err)
+			// the task can never run, so don't leave it for another runner to reclaim
+			if err := w.transport.AckTask(ctx, claim); err != nil {
+				slog.Error("failed to acknowledge task", "task_id", task.ID, "error", err)
+			}
+			continue
+		}
+
+		running.Add(1)
+		go func() {
+			defer running.Done()
+			defer func() { <-pool }()
+			w.handleTask(ctx, runner, task, claim)
+		}()
+	}
+
+	// Checks were cancelled along with ctx; wait for them to clean up after
+	// themselves and hand their tasks back before leaving the registry
+	slog.Info("draining runner", "runner_id", w.conf.ID, "in_flight", w.inFlight.Load())
+	running.Wait()
+	<-heartbeatDone
+	if err := w.transport.Deregister(context.Background(), w.conf.ID); err != nil {
+		slog.Error("failed to deregister runner", "error", err)
+	}
+}
+
+// heartbeat keeps this runner's registration fresh so the engine knows it is alive
+func (w *Worker) heartbeat(ctx context.Context) {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "unknown"
+	}
+	info := engine.RunnerInfo{
+		ID:         w.conf.ID,
+		Hostname:   hostname,
+		Version:    w.conf.Version,
+		CheckTypes: CheckTypes(),
+		Tags:       w.conf.Tags,
+		Workers:    w.conf.Workers,
+		StartedAt:  time.Now(),
+	}
+
+	ticker := time.NewTicker(engine.HeartbeatInterval)
+	defer ticker.Stop()
+	for {
+		info.InFlight = int(w.inFlight.Load())
+		info.Refused = int(w.refused.Load())
+		if err := w.transport.Heartbeat(ctx, info); err != nil {
+			slog.Error("failed to send heartbeat", "error", err)
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (w *Worker) nextTask(ctx context.Context) (*engine.Task, engine.Claim, error) {
+	// Wait for a task, checking regularly for ones abandoned by other runners
+	task, claim, err := w.transport.ClaimTask(ctx, w.conf.ID, w.conf.Tags, w.conf.VisibilityTimeout, w.conf.VisibilityTimeout/3)
+	if err != nil || task == nil {
+		return nil, engine.Claim{}, err
+	}
+
+	slog.Info("received task", "task_id", task.ID, "round_id", task.RoundID, "team_id", task.TeamID,
+		"team_identifier", task.TeamIdentifier, "service_type", task.ServiceType, "runner_id", w.conf.ID)
+
+	return task, claim, nil
+}
+
+// checkTypes maps each service type a runner can execute to a constructor for its check
+var checkTypes = map[string]func() checks.Runner{
+	"Custom": func() checks.Runner { return &checks.Custom{} },
+	"Dns":    func() checks.Runner { return &checks.Dns{} },
+	"Ftp":    func() checks.Runner { return &checks.Ftp{} },
+	"Imap":   func() checks.Runner { return &checks.Imap{} },
+	"Koth":   func() checks.Runner { return &checks.Koth{} },
+	"Ldap":   func() checks.Runner { return &checks.Ldap{} },
+	"Ping":   func() checks.Runner { return &checks.Ping{} },
+	"Pop3":   func() checks.Runner { return &checks.Pop3{} },
+	"Rdp":    func() checks.Runner { return &checks.Rdp{} },
+	"Smb":    func() checks.Runner { return &checks.Smb{} },
+	"Smtp":   func() checks.Runner { return &checks.Smtp{} },
+	"Sql":    func() checks.Runner { return &checks.Sql{} },
+	"Ssh":    func() checks.Runner { return &checks.Ssh{} },
+	"Tcp":    func() checks.Runner { return &checks.Tcp{} },
+	"Vnc":    func() checks.Runner { return &checks.Vnc{} },
+	"Web":    func() checks.Runner { return &checks.Web{} },
+	"WinRM":  func() checks.Runner { return &checks.WinRM{} },
+}
+
+// CheckTypes returns the service types a runner can execute, sorted
+func CheckTypes() []string {
+	return slices.Sorted(maps.Keys(checkTypes))
+}
+
+// CreateRunner builds the check a task describes
+func CreateRunner(task *engine.Task) (checks.Runner, error) {
+	newRunner, ok := checkTypes[task.ServiceType]
+	if !ok {
+		return nil, fmt.Errorf("unknown service type: %s", task.ServiceType)
+	}
+	runner := newRunner()
+
+	if err := json.Unmarshal(task.CheckData, runner); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal check data: %w", err)
+	}
+
+	slog.Debug("check data", "runner", fmt.Sprintf("%+v", runner))
+	return runner, nil
+}
+
+func (w *Worker) handleTask(ctx context.Context, runner checks.Runner, task *engine.Task, claim engine.Claim) {
+	w.inFlight.Add(1)
+	defer w.inFlight.Add(-1)
+
+	// Transport calls outlive a drain, so a finished check still gets its result pushed
+	rctx := context.WithoutCancel(ctx)
+
+	// When the runner drains mid-check the task is handed back for another runner,
+	// once the claim is no longer being extended
+	handBack := false
+	defer func() {
+		if handBack {
+			w.releaseTask(rctx, task, claim)
+		}
+	}()
+
+	// Keep the task claimed while the check runs so it is not handed to another runner
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	defer func() {
+		close(done)
+		<-stopped
+	}()
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(w.conf.VisibilityTimeout / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if err := w.transport.ExtendTask(rctx, w.conf.ID, claim); err != nil {
+					slog.Warn("failed to extend task claim", "task_id", task.ID, "error", err)
+				}
+			}
+		}
+	}()
+
+	result, finished := w.RunCheck(ctx, runner, task)
+	if !finished {
+		handBack = true
+		return
+	}
+
+	task.ScrubResult(&result)
+
+	// Store the result; if this fails the task stays unacknowledged and another runner retries it
+	if err := w.transport.PushResult(rctx, result); err != nil {
+		slog.Error("failed to push result", "error", err)
+		return
+	}
+
+	if err := w.transport.AckTask(rctx, claim); err != nil {
+		slog.Error("failed to acknowledge task", "task_id", task.ID, "error", err)
+	}
+
+	slog.Info("successfully pushed result", "round_id", result.RoundID, "team_id", result.TeamID,
+		"service_type", result.ServiceType, "status", result.Status)
+}
+
+// RunCheck runs every attempt of a task's check and returns its result. It returns
+// false when ctx was cancelled before the check finished, leaving no result to report.
+func (w *Worker) RunCheck(ctx context.Context, runner checks.Runner, task *engine.Task) (checks.Result, bool) {
+	// Create a result
+	startTime := time.Now()
+	result := checks.Result{
+		TaskID:      task.ID,
+		TeamID:      task.TeamID,
+		ServiceName: task.ServiceName,
+		ServiceType: task.ServiceType,
+		RoundID:     task.RoundID,
+		Status:      false,
+		RunnerID:    w.conf.ID,
+		StartTime:   startTime.Format(time.RFC3339),
+		StatusText:  "running",
+	}
+
+	resultsChan := make(chan checks.Result, 1)
+
+	// Set credentials from task payload for the checks to use (per-instance, thread-safe)
+	if len(task.Credentials) > 0 {
+		creds := make([]checks.TaskCredential, len(task.Credentials))
+		for i, c := range task.Credentials {
+			creds[i] = checks.TaskCredential{
+				Username: c.Username,
+				Password: c.Password,
+			}
+		}
+		runner.SetTaskCredentials(creds)
+	}
+	runner.SetTaskRun(task.Run)
+
+	// Wait for a free slot on the target so a team's box is not flooded with connections
+	attempts := task.Attempts
+	target := strings.ReplaceAll(runner.GetTarget(), "_", task.TeamIdentifier)
+	waitCtx, cancelWait := context.WithDeadline(ctx, task.Deadline)
+	release, err := w.targets.acquire(waitCtx, target)
+	cancelWait()
+	if err != nil && ctx.Err() != nil {
+		return result, false
+	}
+	if err != nil {
+		result.Debug = "round ended while waiting for other checks against " + target + " to finish"
+		result.Error = "timeout"
+		attempts = 0
+
+		slog.Warn("check timed out waiting for target", "round_id", task.RoundID, "team_id", task.TeamID,
+			"service_type", task.ServiceType, "target", target)
+	} else {
+		defer release()
+	}
+
+	// every attempt is kept so that a check passing on a retry still shows why earlier attempts failed
+	var history []checks.Attempt
+	for i := range attempts {
+		if ctx.Err() != nil {
+			return result, false
+		}
+		slog.Info("running check", "round_id", task.RoundID, "team_id", task.TeamID,
+			"service_type", task.ServiceType, "service_name", task.ServiceName, "attempt", i+1)
+		attemptStart := time.Now()
+
+		// Create context with deadline
+		checkCtx, cancel := context.WithDeadline(ctx, task.Deadline)
+		defer cancel()
+
+		// Run the check in a goroutine
+		go runner.Run(checkCtx, task.TeamID, task.TeamIdentifier, task.RoundID, resultsChan)
+
+		// Wait for either result or deadline
+		select {
+		case result = <-resultsChan:
+			result.TeamID = task.TeamID
+			result.ServiceName = task.ServiceName
+			result.ServiceType = task.ServiceType
+			result.RoundID = task.RoundID
+			result.TaskID = task.ID
+
+			slog.Info("check result received", "round_id", result.RoundID, "team_id", result.TeamID,
+				"service_type", result.ServiceType, "status", result.Status, "debug", result.Debug, "error", result.Error)
+
+		case <-checkCtx.Done():
+			if ctx.Err() != nil {
+				// give the cancelled check a moment to kill its processes and remove its files
+				select {
+				case <-resultsChan:
+				case <-time.After(drainTimeout):
+				}
+				return result, false
+			}
+			result.Status = false
+			result.Debug = "round ended before check completed"
+			result.Error = "timeout"
+			result.TeamID = task.TeamID
+			result.ServiceName = task.ServiceName
+			result.ServiceType = task.ServiceType
+			result.RoundID = task.RoundID
+			result.TaskID = task.ID
+
+			slog.Warn("check timed out", "round_id", task.RoundID, "team_id", task.TeamID,
+				"service_type", task.ServiceType)
+		}
+
+		// a check that failed because it was cancelled by the drain is not a real result
+		if ctx.Err() != nil && !result.Status {
+			return result, false
+		}
+
+		history = append(history, checks.Attempt{
+			Number:     i + 1,
+			Status:     result.Status,
+			Error:      result.Error,
+			Debug:      result.Debug,
+			DurationMs: time.Since(attemptStart).Milliseconds(),
+			RunnerID:   w.conf.ID,
+		})
+
+		// Break if successful or deadline passed
+		if result.Status || time.Now().After(task.Deadline) {
+			break
+		}
+	}
+
+	result.Attempts = history
+	result.MaxAttempts = task.Attempts
+	result.RunnerID = w.conf.ID
+	result.StartTime = startTime.Format(time.RFC3339)
+	result.EndTime = time.Now().Format(time.RFC3339)
+	result.StatusText = map[bool]string{true: "success", false: "failed"}[result.Status]
+	return result, true
+}
+
+// releaseTask hands a claimed task back so another runner picks it up without
+// waiting out the visibility timeout
+func (w *Worker) releaseTask(ctx context.Context, task *engine.Task, claim engine.Claim) {
+	if err := w.transport.ReleaseTask(ctx, w.conf.ID, claim, w.conf.VisibilityTimeout); err != nil {
+		slog.Error("failed to release task", "task_id", task.ID, "error", err)
+		return
+	}
+	slog.Info("released task for another runner", "task_id", task.ID, "round_id", task.RoundID)
+}
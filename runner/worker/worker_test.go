@@ -0,0 +1,69 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"quotient/engine"
+	"quotient/engine/checks"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWorkerRunsTasksFromMemoryTransport(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+	port := listener.Addr().(*net.TCPAddr).Port
+
+	check := checks.Tcp{Service: checks.Service{Name: "web01-tcp", ServiceType: "Tcp", Target: "127.0.0.1", Port: port, Timeout: 2}}
+	data, err := json.Marshal(check)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	transport := engine.NewMemoryTransport()
+	require.NoError(t, transport.EnqueueTask(ctx, engine.Task{
+		ID:          "task-" + strconv.Itoa(port),
+		TeamID:      1,
+		ServiceType: "Tcp",
+		ServiceName: "web01-tcp",
+		RoundID:     1,
+		Deadline:    time.Now().Add(10 * time.Second),
+		Attempts:    1,
+		CheckData:   data,
+	}))
+
+	w := New(transport, Config{ID: "embedded-1", Workers: 2, MaxPerTarget: 1, VisibilityTimeout: time.Minute})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		w.Run(ctx)
+	}()
+
+	result, err := transport.ReadResult(ctx, 5*time.Second)
+	require.NoError(t, err)
+	assert.True(t, result.Status)
+	assert.Equal(t, "embedded-1", result.RunnerID)
+	require.Len(t, result.Attempts, 1)
+
+	// the result was acknowledged and the runner registered itself
+	running, err := transport.RunningTasks(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, running)
+	runners, err := transport.Runners(ctx)
+	require.NoError(t, err)
+	require.Len(t, runners, 1)
+	assert.Equal(t, CheckTypes(), runners[0].CheckTypes)
+
+	// a drained runner leaves the registry
+	cancel()
+	<-done
+	runners, err = transport.Runners(context.Background())
+	require.NoError(t, err)
+	assert.Empty(t, runners)
+}
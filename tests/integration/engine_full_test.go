@@ -77,19 +77,13 @@ func TestFullEngineWorkflow(t *testing.T) {
 			CheckData:      json.RawMessage(`{"port":80,"target":"127.0.0.1","scheme":"http"}`),
 		}
 
-		taskJSON, err := json.Marshal(task)
-		require.NoError(t, err)
-
-		err = redisContainer.Client.RPush(ctx, "tasks", taskJSON).Err()
+		err := engine.EnqueueTask(ctx, redisContainer.Client, task)
 		require.NoError(t, err)
 
 		// Step 2: Simulate runner consuming task and producing result
-		taskData, err := redisContainer.Client.LPop(ctx, "tasks").Result()
-		require.NoError(t, err)
-
-		var consumedTask engine.Task
-		err = json.Unmarshal([]byte(taskData), &consumedTask)
+		consumedTask, msgID, err := engine.ClaimTask(ctx, redisContainer.Client, "runner-1", nil, time.Minute, time.Second)
 		require.NoError(t, err)
+		require.NotNil(t, consumedTask)
 
 		assert.Equal(t, task.TeamID, consumedTask.TeamID)
 		assert.Equal(t, task.ServiceName, consumedTask.ServiceName)
@@ -106,19 +100,19 @@ func TestFullEngineWorkflow(t *testing.T) {
 			Debug:       "Check passed",
 		}
 
-		resultJSON, err := json.Marshal(result)
+		err = engine.PushResult(ctx, redisContainer.Client, result)
 		require.NoError(t, err)
-
-		err = redisContainer.Client.RPush(ctx, "results", resultJSON).Err()
+		err = engine.AckTask(ctx, redisContainer.Client, msgID)
 		require.NoError(t, err)
 
 		// Step 4: Collect result (simulating engine)
-		resultData, err := redisContainer.Client.BLPop(ctx, 2*time.Second, "results").Result()
+		resultData, err := redisContainer.Client.XRange(ctx, engine.ResultStream, "-", "+").Result()
 		require.NoError(t, err)
-		require.Len(t, resultData, 2)
+		require.Len(t, resultData, 1)
 
 		var collectedResult checks.Result
-		err = json.Unmarshal([]byte(resultData[1]), &collectedResult)
+		payload, _ := resultData[0].Values["payload"].(string)
+		err = json.Unmarshal([]byte(payload), &collectedResult)
 		require.NoError(t, err)
 
 		assert.Equal(t, result.TeamID, collectedResult.TeamID)
@@ -288,8 +282,7 @@ func TestFullEngineWorkflow(t *testing.T) {
 					Points:      5,
 				}
 
-				resultJSON, _ := json.Marshal(result)
-				redisContainer.Client.RPush(ctx, "results", resultJSON)
+				engine.PushResult(ctx, redisContainer.Client, result)
 				done <- true
 			}(i)
 		}
@@ -300,18 +293,18 @@ func TestFullEngineWorkflow(t *testing.T) {
 		}
 
 		// Verify all results are in Redis
-		count, err := redisContainer.Client.LLen(ctx, "results").Result()
+		count, err := redisContainer.Client.XLen(ctx, engine.ResultStream).Result()
 		require.NoError(t, err)
 		assert.Equal(t, int64(numResults), count)
 
 		// Collect all results
+		data, err := redisContainer.Client.XRange(ctx, engine.ResultStream, "-", "+").Result()
+		require.NoError(t, err)
 		collected := []checks.Result{}
-		for i := 0; i < numResults; i++ {
-			data, err := redisContainer.Client.BLPop(ctx, 2*time.Second, "results").Result()
-			require.NoError(t, err)
-
+		for _, msg := range data {
 			var result checks.Result
-			json.Unmarshal([]byte(data[1]), &result)
+			payload, _ := msg.Values["payload"].(string)
+			json.Unmarshal([]byte(payload), &result)
 			collected = append(collected, result)
 		}
 
@@ -42,24 +42,18 @@ func TestEngineRedisTaskEnqueue(t *testing.T) {
 		}
 
 		// Serialize and push to Redis
-		payload, err := json.Marshal(task)
-		require.NoError(t, err)
-
-		err = redisContainer.Client.RPush(ctx, "tasks", payload).Err()
+		err := engine.EnqueueTask(ctx, redisContainer.Client, task)
 		require.NoError(t, err)
 
 		// Verify task is in queue
-		length, err := redisContainer.Client.LLen(ctx, "tasks").Result()
+		length, err := redisContainer.Client.XLen(ctx, engine.TaskStream).Result()
 		require.NoError(t, err)
 		assert.Equal(t, int64(1), length)
 
-		// Pop task and verify
-		val, err := redisContainer.Client.LPop(ctx, "tasks").Result()
-		require.NoError(t, err)
-
-		var decodedTask engine.Task
-		err = json.Unmarshal([]byte(val), &decodedTask)
+		// Claim task and verify
+		decodedTask, _, err := engine.ClaimTask(ctx, redisContainer.Client, "runner-1", nil, time.Minute, time.Second)
 		require.NoError(t, err)
+		require.NotNil(t, decodedTask)
 
 		assert.Equal(t, task.TeamID, decodedTask.TeamID)
 		assert.Equal(t, task.ServiceName, decodedTask.ServiceName)
@@ -79,25 +73,20 @@ func TestEngineRedisTaskEnqueue(t *testing.T) {
 
 		// Enqueue all tasks
 		for _, task := range tasks {
-			payload, err := json.Marshal(task)
-			require.NoError(t, err)
-			err = redisContainer.Client.RPush(ctx, "tasks", payload).Err()
+			err := engine.EnqueueTask(ctx, redisContainer.Client, task)
 			require.NoError(t, err)
 		}
 
 		// Verify queue length
-		length, err := redisContainer.Client.LLen(ctx, "tasks").Result()
+		length, err := redisContainer.Client.XLen(ctx, engine.TaskStream).Result()
 		require.NoError(t, err)
 		assert.Equal(t, int64(len(tasks)), length)
 
 		// Verify tasks are in FIFO order
 		for i, expectedTask := range tasks {
-			val, err := redisContainer.Client.LPop(ctx, "tasks").Result()
-			require.NoError(t, err, "failed to pop task %d", i)
-
-			var decodedTask engine.Task
-			err = json.Unmarshal([]byte(val), &decodedTask)
-			require.NoError(t, err)
+			decodedTask, _, err := engine.ClaimTask(ctx, redisContainer.Client, "runner-1", nil, time.Minute, time.Second)
+			require.NoError(t, err, "failed to claim task %d", i)
+			require.NotNil(t, decodedTask)
 
 			assert.Equal(t, expectedTask.TeamID, decodedTask.TeamID)
 			assert.Equal(t, expectedTask.ServiceName, decodedTask.ServiceName)
@@ -119,23 +108,259 @@ func TestEngineRedisTaskEnqueue(t *testing.T) {
 				Attempts:       3,
 				Deadline:       time.Now().Add(-60 * time.Second), // Past deadline
 			}
-			payload, _ := json.Marshal(task)
-			redisContainer.Client.RPush(ctx, "tasks", payload)
+			engine.EnqueueTask(ctx, redisContainer.Client, task)
 		}
 
 		// Verify stale tasks exist
-		length, _ := redisContainer.Client.LLen(ctx, "tasks").Result()
+		length, _ := redisContainer.Client.XLen(ctx, engine.TaskStream).Result()
 		assert.Equal(t, int64(5), length)
 
-		// Clear stale tasks (simulating engine behavior)
-		redisContainer.Client.Del(ctx, "tasks")
+		// Runners refuse tasks past their deadline instead of running them
+		for i := 0; i < 5; i++ {
+			task, _, err := engine.ClaimTask(ctx, redisContainer.Client, "runner-1", nil, time.Minute, time.Second)
+			require.ErrorIs(t, err, engine.ErrTaskRefused)
+			assert.Nil(t, task)
+		}
+
+		// Verify nothing is left pending
+		pending, err := redisContainer.Client.XPending(ctx, engine.TaskStream, engine.RunnerGroup).Result()
+		require.NoError(t, err)
+		assert.Equal(t, int64(0), pending.Count)
+	})
+
+	t.Run("reclaim unacknowledged task", func(t *testing.T) {
+		// Clear Redis
+		redisContainer.Client.FlushDB(ctx)
+
+		task := engine.Task{
+			ID:          "task-1",
+			TeamID:      1,
+			ServiceType: "Web",
+			ServiceName: "web01-web",
+			RoundID:     1,
+			Attempts:    1,
+			Deadline:    time.Now().Add(60 * time.Second),
+		}
+		require.NoError(t, engine.EnqueueTask(ctx, redisContainer.Client, task))
+
+		// The first runner claims the task and dies without acknowledging it
+		claimed, _, err := engine.ClaimTask(ctx, redisContainer.Client, "runner-1", nil, 200*time.Millisecond, time.Second)
+		require.NoError(t, err)
+		require.NotNil(t, claimed)
+
+		// Nothing to hand out until the visibility timeout passes
+		again, _, err := engine.ClaimTask(ctx, redisContainer.Client, "runner-2", nil, 200*time.Millisecond, 10*time.Millisecond)
+		require.NoError(t, err)
+		assert.Nil(t, again)
+
+		time.Sleep(300 * time.Millisecond)
+
+		// Another runner now takes the task over
+		reclaimed, msgID, err := engine.ClaimTask(ctx, redisContainer.Client, "runner-2", nil, 200*time.Millisecond, 10*time.Millisecond)
+		require.NoError(t, err)
+		require.NotNil(t, reclaimed)
+		assert.Equal(t, task.ID, reclaimed.ID)
+
+		require.NoError(t, engine.AckTask(ctx, redisContainer.Client, msgID))
+		pending, err := redisContainer.Client.XPending(ctx, engine.TaskStream, engine.RunnerGroup).Result()
+		require.NoError(t, err)
+		assert.Equal(t, int64(0), pending.Count)
+	})
+
+	t.Run("released task is reclaimed without waiting", func(t *testing.T) {
+		// Clear Redis
+		redisContainer.Client.FlushDB(ctx)
+
+		task := engine.Task{
+			ID:          "task-1",
+			TeamID:      1,
+			ServiceType: "Web",
+			ServiceName: "web01-web",
+			RoundID:     1,
+			Attempts:    1,
+			Deadline:    time.Now().Add(60 * time.Second),
+		}
+		require.NoError(t, engine.EnqueueTask(ctx, redisContainer.Client, task))
+
+		// The first runner claims the task, then hands it back while draining
+		claimed, claim, err := engine.ClaimTask(ctx, redisContainer.Client, "runner-1", nil, 10*time.Second, time.Second)
+		require.NoError(t, err)
+		require.NotNil(t, claimed)
+		require.NoError(t, engine.ReleaseTask(ctx, redisContainer.Client, "runner-1", claim, 10*time.Second))
+
+		// Another runner takes it over well before the visibility timeout
+		reclaimed, _, err := engine.ClaimTask(ctx, redisContainer.Client, "runner-2", nil, 10*time.Second, 10*time.Millisecond)
+		require.NoError(t, err)
+		require.NotNil(t, reclaimed)
+		assert.Equal(t, task.ID, reclaimed.ID)
+	})
+}
+
+// TestEngineRedisTaskSigning tests that runners only run tasks signed by the engine
+func TestEngineRedisTaskSigning(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode")
+	}
+
+	redisContainer := testutil.StartRedis(t)
+	defer redisContainer.Close()
+
+	ctx := context.Background()
+	t.Setenv("TASK_SIGNING_KEY", "test-signing-key")
+
+	task := engine.Task{
+		ID:          "task-1",
+		TeamID:      1,
+		ServiceType: "Custom",
+		ServiceName: "web01-custom",
+		RoundID:     1,
+		Attempts:    1,
+		Deadline:    time.Now().Add(60 * time.Second),
+	}
+	payload, err := json.Marshal(task)
+	require.NoError(t, err)
+
+	t.Run("signed task is run", func(t *testing.T) {
+		redisContainer.Client.FlushDB(ctx)
+		require.NoError(t, engine.EnqueueTask(ctx, redisContainer.Client, task))
+
+		claimed, _, err := engine.ClaimTask(ctx, redisContainer.Client, "runner-1", nil, time.Minute, time.Second)
+		require.NoError(t, err)
+		require.NotNil(t, claimed)
+		assert.Equal(t, task.ID, claimed.ID)
+	})
+
+	t.Run("unsigned task is refused", func(t *testing.T) {
+		redisContainer.Client.FlushDB(ctx)
+		require.NoError(t, engine.EnsureStreams(ctx, redisContainer.Client))
+		redisContainer.Client.XAdd(ctx, &redis.XAddArgs{
+			Stream: engine.TaskStream,
+			Values: map[string]any{"payload": payload},
+		})
+
+		claimed, _, err := engine.ClaimTask(ctx, redisContainer.Client, "runner-1", nil, time.Minute, time.Second)
+		require.ErrorIs(t, err, engine.ErrTaskRefused)
+		assert.Nil(t, claimed)
+	})
+
+	t.Run("tampered task is refused", func(t *testing.T) {
+		redisContainer.Client.FlushDB(ctx)
+		require.NoError(t, engine.EnqueueTask(ctx, redisContainer.Client, task))
 
-		// Verify queue is empty
-		length, _ = redisContainer.Client.LLen(ctx, "tasks").Result()
-		assert.Equal(t, int64(0), length)
+		// swap the payload for one running a different command, keeping the signature
+		entries, err := redisContainer.Client.XRange(ctx, engine.TaskStream, "-", "+").Result()
+		require.NoError(t, err)
+		require.Len(t, entries, 1)
+		tampered := task
+		tampered.CheckData = json.RawMessage(`{"Command":"id"}`)
+		tamperedPayload, err := json.Marshal(tampered)
+		require.NoError(t, err)
+		redisContainer.Client.XAdd(ctx, &redis.XAddArgs{
+			Stream: engine.TaskStream,
+			Values: map[string]any{"payload": tamperedPayload, "signature": entries[0].Values["signature"]},
+		})
+		redisContainer.Client.XDel(ctx, engine.TaskStream, entries[0].ID)
+
+		claimed, _, err := engine.ClaimTask(ctx, redisContainer.Client, "runner-1", nil, time.Minute, time.Second)
+		require.ErrorIs(t, err, engine.ErrTaskRefused)
+		assert.Nil(t, claimed)
+
+		// refused tasks are dropped rather than handed to the next runner
+		pending, err := redisContainer.Client.XPending(ctx, engine.TaskStream, engine.RunnerGroup).Result()
+		require.NoError(t, err)
+		assert.Equal(t, int64(0), pending.Count)
 	})
 }
 
+// TestEngineRedisCredentialEncryption tests that credentials are never stored in Redis in plaintext
+func TestEngineRedisCredentialEncryption(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode")
+	}
+
+	redisContainer := testutil.StartRedis(t)
+	defer redisContainer.Close()
+
+	ctx := context.Background()
+	t.Setenv("TASK_ENCRYPTION_KEY", "test-encryption-key")
+
+	task := engine.Task{
+		ID:          "task-1",
+		TeamID:      1,
+		ServiceType: "Ssh",
+		ServiceName: "web01-ssh",
+		RoundID:     1,
+		Attempts:    1,
+		Deadline:    time.Now().Add(60 * time.Second),
+		Credentials: []engine.Credential{{Username: "admin", Password: "hunter22"}},
+	}
+	require.NoError(t, engine.EnqueueTask(ctx, redisContainer.Client, task))
+
+	entries, err := redisContainer.Client.XRange(ctx, engine.TaskStream, "-", "+").Result()
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.NotContains(t, entries[0].Values["payload"], "hunter22")
+
+	// the runner gets the credentials back
+	claimed, _, err := engine.ClaimTask(ctx, redisContainer.Client, "runner-1", nil, time.Minute, time.Second)
+	require.NoError(t, err)
+	require.NotNil(t, claimed)
+	assert.Equal(t, task.Credentials, claimed.Credentials)
+}
+
+// TestEngineRedisTaskRouting tests that tagged tasks only go to runners carrying their tags
+func TestEngineRedisTaskRouting(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode")
+	}
+
+	redisContainer := testutil.StartRedis(t)
+	defer redisContainer.Close()
+
+	ctx := context.Background()
+	redisContainer.Client.FlushDB(ctx)
+
+	tagged := engine.Task{
+		ID:          "task-tagged",
+		TeamID:      1,
+		ServiceType: "Custom",
+		ServiceName: "dc01-custom",
+		RoundID:     1,
+		Attempts:    1,
+		Deadline:    time.Now().Add(60 * time.Second),
+		Tags:        []string{"windows-tools"},
+	}
+	require.NoError(t, engine.EnqueueTask(ctx, redisContainer.Client, tagged))
+
+	// A runner without the tag never sees the task
+	task, _, err := engine.ClaimTask(ctx, redisContainer.Client, "runner-1", nil, time.Minute, 10*time.Millisecond)
+	require.NoError(t, err)
+	assert.Nil(t, task)
+
+	// A runner carrying the tag picks it up
+	task, claim, err := engine.ClaimTask(ctx, redisContainer.Client, "runner-2", []string{"subnet-a", "windows-tools"}, time.Minute, time.Second)
+	require.NoError(t, err)
+	require.NotNil(t, task)
+	assert.Equal(t, tagged.ID, task.ID)
+	assert.Equal(t, engine.TaskQueue(tagged.Tags), claim.Queue)
+	require.NoError(t, engine.AckTask(ctx, redisContainer.Client, claim))
+}
+
+// readResults returns every result pushed to the results stream, oldest first
+func readResults(t *testing.T, client *redis.Client) []checks.Result {
+	msgs, err := client.XRange(context.Background(), engine.ResultStream, "-", "+").Result()
+	require.NoError(t, err)
+
+	results := make([]checks.Result, 0, len(msgs))
+	for _, msg := range msgs {
+		var result checks.Result
+		payload, _ := msg.Values["payload"].(string)
+		require.NoError(t, json.Unmarshal([]byte(payload), &result))
+		results = append(results, result)
+	}
+	return results
+}
+
 // TestEngineRedisResultCollection tests result collection from Redis
 func TestEngineRedisResultCollection(t *testing.T) {
 	if testing.Short() {
@@ -161,20 +386,13 @@ func TestEngineRedisResultCollection(t *testing.T) {
 			Points:      5,
 		}
 
-		payload, err := json.Marshal(result)
-		require.NoError(t, err)
-
-		err = redisContainer.Client.RPush(ctx, "results", payload).Err()
+		err := engine.PushResult(ctx, redisContainer.Client, result)
 		require.NoError(t, err)
 
 		// Collect result (simulate engine behavior)
-		val, err := redisContainer.Client.BLPop(ctx, 2*time.Second, "results").Result()
-		require.NoError(t, err)
-		require.Len(t, val, 2) // [queue_name, value]
-
-		var decodedResult checks.Result
-		err = json.Unmarshal([]byte(val[1]), &decodedResult)
-		require.NoError(t, err)
+		collected := readResults(t, redisContainer.Client)
+		require.Len(t, collected, 1)
+		decodedResult := collected[0]
 
 		assert.Equal(t, result.TeamID, decodedResult.TeamID)
 		assert.Equal(t, result.ServiceName, decodedResult.ServiceName)
@@ -194,20 +412,11 @@ func TestEngineRedisResultCollection(t *testing.T) {
 		}
 
 		for _, result := range expectedResults {
-			payload, _ := json.Marshal(result)
-			redisContainer.Client.RPush(ctx, "results", payload)
+			engine.PushResult(ctx, redisContainer.Client, result)
 		}
 
 		// Collect all results
-		collectedResults := []checks.Result{}
-		for i := 0; i < len(expectedResults); i++ {
-			val, err := redisContainer.Client.BLPop(ctx, 2*time.Second, "results").Result()
-			require.NoError(t, err)
-
-			var result checks.Result
-			json.Unmarshal([]byte(val[1]), &result)
-			collectedResults = append(collectedResults, result)
-		}
+		collectedResults := readResults(t, redisContainer.Client)
 
 		assert.Len(t, collectedResults, len(expectedResults))
 
@@ -227,7 +436,10 @@ func TestEngineRedisResultCollection(t *testing.T) {
 		timeoutCtx, cancel := context.WithTimeout(ctx, time.Second)
 		defer cancel()
 
-		_, err := redisContainer.Client.BLPop(timeoutCtx, time.Second, "results").Result()
+		_, err := redisContainer.Client.XRead(timeoutCtx, &redis.XReadArgs{
+			Streams: []string{engine.ResultStream, "$"},
+			Block:   time.Second,
+		}).Result()
 		assert.Error(t, err) // Should timeout
 	})
 
@@ -246,19 +458,12 @@ func TestEngineRedisResultCollection(t *testing.T) {
 		}
 
 		for _, result := range results {
-			payload, _ := json.Marshal(result)
-			redisContainer.Client.RPush(ctx, "results", payload)
+			engine.PushResult(ctx, redisContainer.Client, result)
 		}
 
 		// Collect and filter results
 		validResults := []checks.Result{}
-		for i := 0; i < len(results); i++ {
-			val, err := redisContainer.Client.BLPop(ctx, 2*time.Second, "results").Result()
-			require.NoError(t, err)
-
-			var result checks.Result
-			json.Unmarshal([]byte(val[1]), &result)
-
+		for _, result := range readResults(t, redisContainer.Client) {
 			// Only keep results from current round (simulating engine behavior)
 			if result.RoundID == currentRound {
 				validResults = append(validResults, result)
@@ -399,22 +604,19 @@ func TestEngineRedisRoundWorkflow(t *testing.T) {
 				Attempts:       3,
 				Deadline:       time.Now().Add(10 * time.Second),
 			}
-			payload, _ := json.Marshal(task)
-			redisContainer.Client.RPush(ctx, "tasks", payload)
+			engine.EnqueueTask(ctx, redisContainer.Client, task)
 		}
 
 		// Verify tasks enqueued
-		taskCount, _ := redisContainer.Client.LLen(ctx, "tasks").Result()
+		taskCount, _ := redisContainer.Client.XLen(ctx, engine.TaskStream).Result()
 		assert.Equal(t, int64(numTasks), taskCount)
 
 		// Step 2: Simulate runners consuming tasks and producing results
 		for i := 0; i < numTasks; i++ {
-			// Pop task
-			taskVal, err := redisContainer.Client.LPop(ctx, "tasks").Result()
+			// Claim task
+			task, msgID, err := engine.ClaimTask(ctx, redisContainer.Client, "runner-1", nil, time.Minute, time.Second)
 			require.NoError(t, err)
-
-			var task engine.Task
-			json.Unmarshal([]byte(taskVal), &task)
+			require.NotNil(t, task)
 
 			// Create result
 			result := checks.Result{
@@ -426,43 +628,82 @@ func TestEngineRedisRoundWorkflow(t *testing.T) {
 				Points:      5,
 			}
 
-			// Push result
-			resultPayload, _ := json.Marshal(result)
-			redisContainer.Client.RPush(ctx, "results", resultPayload)
+			// Push result, then acknowledge the task
+			engine.PushResult(ctx, redisContainer.Client, result)
+			engine.AckTask(ctx, redisContainer.Client, msgID)
 		}
 
-		// Verify tasks consumed
-		taskCount, _ = redisContainer.Client.LLen(ctx, "tasks").Result()
-		assert.Equal(t, int64(0), taskCount)
+		// Verify tasks acknowledged
+		pending, _ := redisContainer.Client.XPending(ctx, engine.TaskStream, engine.RunnerGroup).Result()
+		assert.Equal(t, int64(0), pending.Count)
 
 		// Verify results available
-		resultCount, _ := redisContainer.Client.LLen(ctx, "results").Result()
+		resultCount, _ := redisContainer.Client.XLen(ctx, engine.ResultStream).Result()
 		assert.Equal(t, int64(numTasks), resultCount)
 
 		// Step 3: Engine collects results
-		collectedResults := []checks.Result{}
-		for i := 0; i < numTasks; i++ {
-			val, err := redisContainer.Client.BLPop(ctx, 2*time.Second, "results").Result()
-			require.NoError(t, err)
-
-			var result checks.Result
-			json.Unmarshal([]byte(val[1]), &result)
-			collectedResults = append(collectedResults, result)
-		}
+		collectedResults := readResults(t, redisContainer.Client)
 
 		// Verify all results collected
 		assert.Len(t, collectedResults, numTasks)
 
-		// Verify results queue empty
-		resultCount, _ = redisContainer.Client.LLen(ctx, "results").Result()
-		assert.Equal(t, int64(0), resultCount)
-
 		// Step 4: Publish round completion event
 		err := redisContainer.Client.Publish(ctx, "events", "round_finish").Err()
 		require.NoError(t, err)
 	})
 }
 
+// TestRunnerRegistry tests runner heartbeats and dead-runner detection
+func TestRunnerRegistry(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode")
+	}
+
+	redisContainer := testutil.StartRedis(t)
+	defer redisContainer.Close()
+
+	ctx := context.Background()
+	redisContainer.Client.FlushDB(ctx)
+
+	// A runner that is heartbeating
+	err := engine.Heartbeat(ctx, redisContainer.Client, engine.RunnerInfo{
+		ID:         "runner-alive",
+		Hostname:   "host-1",
+		Version:    "v1",
+		CheckTypes: []string{"Ssh", "Web"},
+		InFlight:   2,
+	})
+	require.NoError(t, err)
+
+	// A runner that stopped heartbeating a minute ago, and one gone for much longer
+	for id, lastSeen := range map[string]time.Time{
+		"runner-dead":      time.Now().Add(-time.Minute),
+		"runner-forgotten": time.Now().Add(-2 * time.Hour),
+	} {
+		payload, _ := json.Marshal(engine.RunnerInfo{ID: id, LastSeen: lastSeen})
+		redisContainer.Client.HSet(ctx, "runners", id, payload)
+	}
+
+	runners, err := engine.GetRunners(ctx, redisContainer.Client)
+	require.NoError(t, err)
+	require.Len(t, runners, 2)
+
+	assert.Equal(t, "runner-alive", runners[0].ID)
+	assert.True(t, runners[0].Healthy)
+	assert.Equal(t, 2, runners[0].InFlight)
+	assert.Equal(t, []string{"Ssh", "Web"}, runners[0].CheckTypes)
+
+	assert.Equal(t, "runner-dead", runners[1].ID)
+	assert.False(t, runners[1].Healthy)
+
+	// A runner that shuts down cleanly is removed
+	require.NoError(t, engine.Deregister(ctx, redisContainer.Client, "runner-alive"))
+	runners, err = engine.GetRunners(ctx, redisContainer.Client)
+	require.NoError(t, err)
+	require.Len(t, runners, 1)
+	assert.Equal(t, "runner-dead", runners[0].ID)
+}
+
 // TestRedisConnectionFailure tests behavior when Redis is unavailable
 func TestRedisConnectionFailure(t *testing.T) {
 	t.Run("connection to non-existent Redis", func(t *testing.T) {
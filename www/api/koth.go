@@ -0,0 +1,67 @@
+package api
+
+import (
+	"net/http"
+	"quotient/engine/db"
+	"slices"
+)
+
+// GetKothOwnership returns the per-round ownership history of every koth box
+func GetKothOwnership(w http.ResponseWriter, r *http.Request) {
+	if !CheckCompetitionStarted(w, r) {
+		return
+	}
+
+	ownerships, err := db.GetKothOwnerships()
+	if err != nil {
+		WriteJSON(w, http.StatusInternalServerError, map[string]any{"error": "Failed to retrieve koth ownership"})
+		return
+	}
+
+	teams, err := db.GetTeams()
+	if err != nil {
+		WriteJSON(w, http.StatusInternalServerError, map[string]any{"error": "Failed to retrieve teams"})
+		return
+	}
+	teamNames := make(map[uint]string, len(teams))
+	for _, team := range teams {
+		teamNames[team.ID] = team.Name
+	}
+
+	type Ownership struct {
+		RoundID     uint   `json:"round_id"`
+		ServiceName string `json:"service_name"`
+		TeamID      uint   `json:"team_id"`
+		TeamName    string `json:"team_name"`
+		Points      int    `json:"points"`
+		Error       string `json:"error,omitempty"`
+		Debug       string `json:"debug,omitempty"`
+	}
+
+	scrub := shouldScrub(r)
+	isAdmin := false
+	if roles, ok := r.Context().Value("roles").([]string); ok {
+		isAdmin = slices.Contains(roles, "admin")
+	}
+
+	data := make([]Ownership, 0, len(ownerships))
+	for _, o := range ownerships {
+		entry := Ownership{
+			RoundID:     o.RoundID,
+			ServiceName: o.ServiceName,
+			TeamID:      o.TeamID,
+			TeamName:    teamNames[o.TeamID],
+			Points:      o.Points,
+		}
+		if scrub && o.TeamID != 0 {
+			entry.TeamName = "Team"
+		}
+		if isAdmin {
+			entry.Error = o.Error
+			entry.Debug = o.Debug
+		}
+		data = append(data, entry)
+	}
+
+	WriteJSON(w, http.StatusOK, data)
+}
@@ -27,6 +27,10 @@ var (
 	// Session storage for OIDC state and PKCE
 	oidcSessions   = make(map[string]*oidcSession)
 	oidcSessionsMu sync.RWMutex
+
+	// OIDC user sessions when the engine runs without Redis, lost on restart
+	oidcUserSessions   = make(map[string]OidcUserInfo)
+	oidcUserSessionsMu sync.Mutex
 )
 
 type oidcSession struct {
@@ -466,7 +470,7 @@ func getRefreshTokenExpiry(roles []string) int {
 	return expiry
 }
 
-// OIDC user session storage (stored in Redis)
+// OIDC user session storage (stored in Redis, or in memory without it)
 type OidcUserInfo struct {
 	Username     string
 	Groups       []string
@@ -504,15 +508,35 @@ func storeOIDCUserInfo(username string, groups []string, roles []string, expires
 		}
 		slog.Debug("Stored OIDC user session in Redis", "username", username, "expires_at", expiresAt.Format(time.RFC3339), "expires_in_hours", time.Until(expiresAt).Hours())
 	} else {
-		slog.Warn("Redis client not available, OIDC session not persisted", "username", username)
+		oidcUserSessionsMu.Lock()
+		oidcUserSessions[username] = *userInfo
+		oidcUserSessionsMu.Unlock()
+		slog.Debug("Redis client not available, OIDC session kept in memory", "username", username)
 	}
 }
 
 func GetOIDCUserInfo(username string) (*OidcUserInfo, bool) {
-	// Try to get from Redis
+	// Without Redis the session can only be in memory
 	if eng == nil || eng.RedisClient == nil {
-		slog.Warn("Redis client not available, cannot retrieve OIDC session", "username", username)
-		return nil, false
+		oidcUserSessionsMu.Lock()
+		info, ok := oidcUserSessions[username]
+		expired := ok && time.Now().After(info.ExpiresAt)
+		if expired {
+			delete(oidcUserSessions, username)
+		}
+		oidcUserSessionsMu.Unlock()
+		if !ok {
+			slog.Debug("OIDC user not found in memory", "username", username)
+			return nil, false
+		}
+		if expired {
+			slog.Info("OIDC session expired", "username", username, "expired_at", info.ExpiresAt.Format(time.RFC3339))
+			if info.RefreshToken != "" && tryRefreshOIDCSession(username, &info) {
+				return GetOIDCUserInfo(username)
+			}
+			return nil, false
+		}
+		return &info, true
 	}
 
 	ctx := context.Background()
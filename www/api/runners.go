@@ -0,0 +1,357 @@
+package api
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"quotient/engine"
+	"quotient/engine/checks"
+	"quotient/engine/config"
+	"quotient/engine/db"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultLeaseVisibility = 30 * time.Second
+	minLeaseVisibility     = 5 * time.Second
+	maxLeaseVisibility     = 10 * time.Minute
+
+	// long enough to save a remote runner from polling, short enough for proxies
+	defaultLeaseWait = 20 * time.Second
+	maxLeaseWait     = 25 * time.Second
+
+	// leases a runner never reported on are forgotten after this long
+	staleLeaseAge = time.Hour
+)
+
+var validRunnerNameRegex = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,63}$`)
+
+// remoteLease ties a lease handed to a remote runner to the task it claimed
+type remoteLease struct {
+	runner     string
+	taskID     string
+	claim      engine.Claim
+	visibility time.Duration
+	leased     time.Time
+}
+
+var (
+	remoteLeases   = make(map[string]remoteLease)
+	remoteLeasesMu sync.Mutex
+)
+
+// hashRunnerToken returns what is stored of a runner token
+func hashRunnerToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// AuthenticateRunner returns the name of the remote runner whose unrevoked token
+// the request carries as a bearer token
+func AuthenticateRunner(r *http.Request) (string, bool) {
+	token, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !found || token == "" {
+		return "", false
+	}
+	runnerToken, err := db.GetActiveRunnerToken(hashRunnerToken(token))
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			slog.Error("failed to look up runner token", "error", err)
+		}
+		return "", false
+	}
+	return runnerToken.Name, true
+}
+
+func runnerName(r *http.Request) string {
+	name, _ := r.Context().Value("runner").(string)
+	return name
+}
+
+// findLease returns a lease the requesting runner holds
+func findLease(r *http.Request) (string, remoteLease, bool) {
+	id := r.PathValue("lease")
+	remoteLeasesMu.Lock()
+	defer remoteLeasesMu.Unlock()
+	lease, ok := remoteLeases[id]
+	if !ok || lease.runner != runnerName(r) {
+		return id, remoteLease{}, false
+	}
+	return id, lease, true
+}
+
+func forgetLease(id string) {
+	remoteLeasesMu.Lock()
+	delete(remoteLeases, id)
+	remoteLeasesMu.Unlock()
+}
+
+// forgetRunnerLeases drops every lease held by a runner; the tasks behind them are
+// reclaimed by other runners once their visibility timeout passes
+func forgetRunnerLeases(runner string) {
+	remoteLeasesMu.Lock()
+	defer remoteLeasesMu.Unlock()
+	for id, lease := range remoteLeases {
+		if lease.runner == runner {
+			delete(remoteLeases, id)
+		}
+	}
+}
+
+func LeaseRunnerTask(w http.ResponseWriter, r *http.Request) {
+	runner := runnerName(r)
+
+	var req engine.LeaseRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		WriteJSON(w, http.StatusBadRequest, map[string]any{"error": "Invalid request body"})
+		return
+	}
+	tags, err := config.NormalizeTags(req.Tags)
+	if err != nil {
+		WriteJSON(w, http.StatusBadRequest, map[string]any{"error": err.Error()})
+		return
+	}
+	visibility := defaultLeaseVisibility
+	if req.VisibilitySeconds > 0 {
+		visibility = min(max(time.Duration(req.VisibilitySeconds)*time.Second, minLeaseVisibility), maxLeaseVisibility)
+	}
+	// a zero wait would block on the Redis stream for good
+	wait := defaultLeaseWait
+	if req.WaitSeconds > 0 {
+		wait = min(time.Duration(req.WaitSeconds)*time.Second, maxLeaseWait)
+	}
+
+	task, claim, err := eng.Transport.ClaimTask(r.Context(), runner, tags, visibility, wait)
+	if errors.Is(err, engine.ErrTaskRefused) {
+		WriteJSON(w, http.StatusUnprocessableEntity, map[string]any{"error": err.Error()})
+		return
+	} else if err != nil {
+		if r.Context().Err() == nil {
+			slog.Error("failed to lease task to remote runner", "runner_id", runner, "error", err)
+		}
+		WriteJSON(w, http.StatusInternalServerError, map[string]any{"error": "Failed to lease task"})
+		return
+	}
+	if task == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	id := uuid.New().String()
+	remoteLeasesMu.Lock()
+	for staleID, lease := range remoteLeases {
+		if time.Since(lease.leased) > staleLeaseAge {
+			delete(remoteLeases, staleID)
+		}
+	}
+	remoteLeases[id] = remoteLease{runner: runner, taskID: task.ID, claim: claim, visibility: visibility, leased: time.Now()}
+	remoteLeasesMu.Unlock()
+
+	WriteJSON(w, http.StatusOK, engine.Lease{ID: id, Task: *task})
+}
+
+func ExtendRunnerTask(w http.ResponseWriter, r *http.Request) {
+	_, lease, ok := findLease(r)
+	if !ok {
+		WriteJSON(w, http.StatusNotFound, map[string]any{"error": "Unknown lease"})
+		return
+	}
+	if err := eng.Transport.ExtendTask(r.Context(), lease.runner, lease.claim); err != nil {
+		slog.Error("failed to extend remote runner lease", "runner_id", lease.runner, "task_id", lease.taskID, "error", err)
+		WriteJSON(w, http.StatusInternalServerError, map[string]any{"error": "Failed to extend lease"})
+		return
+	}
+	WriteJSON(w, http.StatusOK, map[string]any{"status": "success"})
+}
+
+func ReleaseRunnerTask(w http.ResponseWriter, r *http.Request) {
+	id, lease, ok := findLease(r)
+	if !ok {
+		WriteJSON(w, http.StatusNotFound, map[string]any{"error": "Unknown lease"})
+		return
+	}
+	if err := eng.Transport.ReleaseTask(r.Context(), lease.runner, lease.claim, lease.visibility); err != nil {
+		slog.Error("failed to release remote runner lease", "runner_id", lease.runner, "task_id", lease.taskID, "error", err)
+		WriteJSON(w, http.StatusInternalServerError, map[string]any{"error": "Failed to release lease"})
+		return
+	}
+	forgetLease(id)
+	WriteJSON(w, http.StatusOK, map[string]any{"status": "success"})
+}
+
+func PushRunnerResult(w http.ResponseWriter, r *http.Request) {
+	_, lease, ok := findLease(r)
+	if !ok {
+		WriteJSON(w, http.StatusNotFound, map[string]any{"error": "Unknown lease"})
+		return
+	}
+
+	var result checks.Result
+	if err := json.NewDecoder(r.Body).Decode(&result); err != nil {
+		WriteJSON(w, http.StatusBadRequest, map[string]any{"error": "Invalid request body"})
+		return
+	}
+	// a runner can only report on the task it leased, and only under its own name
+	if result.TaskID != lease.taskID {
+		WriteJSON(w, http.StatusBadRequest, map[string]any{"error": "Result is not for the leased task"})
+		return
+	}
+	result.RunnerID = lease.runner
+	for i := range result.Attempts {
+		result.Attempts[i].RunnerID = lease.runner
+	}
+
+	if err := eng.Transport.PushResult(r.Context(), result); err != nil {
+		slog.Error("failed to push remote runner result", "runner_id", lease.runner, "task_id", lease.taskID, "error", err)
+		WriteJSON(w, http.StatusInternalServerError, map[string]any{"error": "Failed to store result"})
+		return
+	}
+	WriteJSON(w, http.StatusOK, map[string]any{"status": "success"})
+}
+
+func AckRunnerTask(w http.ResponseWriter, r *http.Request) {
+	id, lease, ok := findLease(r)
+	if !ok {
+		WriteJSON(w, http.StatusNotFound, map[string]any{"error": "Unknown lease"})
+		return
+	}
+	if err := eng.Transport.AckTask(r.Context(), lease.claim); err != nil {
+		slog.Error("failed to acknowledge remote runner task", "runner_id", lease.runner, "task_id", lease.taskID, "error", err)
+		WriteJSON(w, http.StatusInternalServerError, map[string]any{"error": "Failed to acknowledge task"})
+		return
+	}
+	forgetLease(id)
+	WriteJSON(w, http.StatusOK, map[string]any{"status": "success"})
+}
+
+func RunnerHeartbeat(w http.ResponseWriter, r *http.Request) {
+	var info engine.RunnerInfo
+	if err := json.NewDecoder(r.Body).Decode(&info); err != nil {
+		WriteJSON(w, http.StatusBadRequest, map[string]any{"error": "Invalid request body"})
+		return
+	}
+	info.ID = runnerName(r)
+	if err := eng.Transport.Heartbeat(r.Context(), info); err != nil {
+		slog.Error("failed to record remote runner heartbeat", "runner_id", info.ID, "error", err)
+		WriteJSON(w, http.StatusInternalServerError, map[string]any{"error": "Failed to record heartbeat"})
+		return
+	}
+	WriteJSON(w, http.StatusOK, map[string]any{"status": "success"})
+}
+
+func DeregisterRunner(w http.ResponseWriter, r *http.Request) {
+	if err := eng.Transport.Deregister(r.Context(), runnerName(r)); err != nil {
+		slog.Error("failed to deregister remote runner", "runner_id", runnerName(r), "error", err)
+		WriteJSON(w, http.StatusInternalServerError, map[string]any{"error": "Failed to deregister"})
+		return
+	}
+	WriteJSON(w, http.StatusOK, map[string]any{"status": "success"})
+}
+
+type runnerTokenInfo struct {
+	ID        uint      `json:"id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+	Revoked   bool      `json:"revoked"`
+	RevokedAt time.Time `json:"revoked_at,omitzero"`
+}
+
+func GetRunnerTokens(w http.ResponseWriter, r *http.Request) {
+	tokens, err := db.GetRunnerTokens()
+	if err != nil {
+		WriteJSON(w, http.StatusInternalServerError, map[string]any{"error": "Failed to retrieve runner tokens"})
+		return
+	}
+	data := make([]runnerTokenInfo, 0, len(tokens))
+	for _, token := range tokens {
+		data = append(data, runnerTokenInfo{
+			ID:        token.ID,
+			Name:      token.Name,
+			CreatedAt: token.CreatedAt,
+			Revoked:   token.Revoked,
+			RevokedAt: token.RevokedAt,
+		})
+	}
+	WriteJSON(w, http.StatusOK, data)
+}
+
+// CreateRunnerToken issues a token for a remote runner. The token is only ever
+// shown in this response.
+func CreateRunnerToken(w http.ResponseWriter, r *http.Request) {
+	type Form struct {
+		Name string `json:"name"`
+	}
+
+	var form Form
+	if err := json.NewDecoder(r.Body).Decode(&form); err != nil {
+		WriteJSON(w, http.StatusBadRequest, map[string]any{"error": "Invalid request body"})
+		return
+	}
+	if !validRunnerNameRegex.MatchString(form.Name) {
+		WriteJSON(w, http.StatusBadRequest, map[string]any{"error": "Runner name must be 1-64 letters, digits, dots, dashes or underscores"})
+		return
+	}
+	inUse, err := db.RunnerTokenNameInUse(form.Name)
+	if err != nil {
+		WriteJSON(w, http.StatusInternalServerError, map[string]any{"error": "Failed to check runner name"})
+		return
+	}
+	if inUse {
+		WriteJSON(w, http.StatusConflict, map[string]any{"error": "A runner with this name already has a token"})
+		return
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		WriteJSON(w, http.StatusInternalServerError, map[string]any{"error": "Failed to generate token"})
+		return
+	}
+	token := hex.EncodeToString(secret)
+	created, err := db.CreateRunnerToken(db.RunnerTokenSchema{Name: form.Name, TokenHash: hashRunnerToken(token)})
+	if err != nil {
+		WriteJSON(w, http.StatusInternalServerError, map[string]any{"error": "Failed to create runner token"})
+		return
+	}
+
+	slog.Info("runner token created", "runner_id", created.Name, "token_id", created.ID)
+	WriteJSON(w, http.StatusOK, map[string]any{"id": created.ID, "name": created.Name, "token": token})
+}
+
+// RevokeRunnerToken stops a remote runner from reaching the runner API. The tasks
+// it holds go to other runners once their visibility timeout passes.
+func RevokeRunnerToken(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+	if err != nil {
+		WriteJSON(w, http.StatusBadRequest, map[string]any{"error": "Invalid token ID"})
+		return
+	}
+	token, err := db.RevokeRunnerToken(uint(id))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		WriteJSON(w, http.StatusNotFound, map[string]any{"error": "Runner token not found"})
+		return
+	} else if err != nil {
+		WriteJSON(w, http.StatusInternalServerError, map[string]any{"error": "Failed to revoke runner token"})
+		return
+	}
+
+	forgetRunnerLeases(token.Name)
+	if err := eng.Transport.Deregister(r.Context(), token.Name); err != nil {
+		slog.Error("failed to deregister revoked runner", "runner_id", token.Name, "error", err)
+	}
+
+	slog.Info("runner token revoked", "runner_id", token.Name, "token_id", token.ID)
+	WriteJSON(w, http.StatusOK, map[string]any{"status": "success"})
+}
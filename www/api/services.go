@@ -211,6 +211,20 @@ func GetServiceAll(w http.ResponseWriter, r *http.Request) {
 		for i := range service {
 			service[i].Debug = ""
 			service[i].Error = ""
+			service[i].Item = ""
+			for j := range service[i].Attempts {
+				service[i].Attempts[j].Debug = ""
+				service[i].Attempts[j].Error = ""
+			}
+		}
+	}
+
+	// runner IDs are internal infrastructure
+	if !slices.Contains(req_roles, "admin") {
+		for i := range service {
+			for j := range service[i].Attempts {
+				service[i].Attempts[j].RunnerID = ""
+			}
 		}
 	}
 
@@ -46,3 +46,16 @@ func Authentication(roles ...string) Middleware {
 		}
 	}
 }
+
+// RunnerAuthentication admits remote runners presenting an unrevoked runner token
+func RunnerAuthentication(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		runner, ok := api.AuthenticateRunner(r)
+		if !ok {
+			api.WriteJSON(w, http.StatusUnauthorized, map[string]any{"error": "unauthorized"})
+			return
+		}
+		ctx := context.WithValue(r.Context(), "runner", runner)
+		next(w, r.WithContext(ctx))
+	}
+}
@@ -56,6 +56,7 @@ func (router *Router) Start() {
 	mux.HandleFunc("GET /api/graphs/services", UNAUTH(api.GetServiceStatus))
 	mux.HandleFunc("GET /api/graphs/scores", UNAUTH(api.GetScoreStatus))
 	mux.HandleFunc("GET /api/graphs/uptimes", UNAUTH(api.GetUptimeStatus))
+	mux.HandleFunc("GET /api/koth", UNAUTH(api.GetKothOwnership))
 
 	// public WWW routes
 	mux.HandleFunc("GET /login", router.LoginPage)
@@ -163,6 +164,9 @@ func (router *Router) Start() {
 	mux.HandleFunc("POST /api/admin/teams", ADMINAUTH(api.UpdateTeams))
 	mux.HandleFunc("GET /api/admin/teamchecks", ADMINAUTH(api.GetTeamChecks))
 	mux.HandleFunc("POST /api/admin/teamchecks", ADMINAUTH(api.UpdateTeamChecks))
+	mux.HandleFunc("GET /api/admin/runners/tokens", ADMINAUTH(api.GetRunnerTokens))
+	mux.HandleFunc("POST /api/admin/runners/tokens", ADMINAUTH(api.CreateRunnerToken))
+	mux.HandleFunc("DELETE /api/admin/runners/tokens/{id}", ADMINAUTH(api.RevokeRunnerToken))
 
 	mux.HandleFunc("GET /api/engine/export/scores", ADMINAUTH(api.ExportScores))
 	mux.HandleFunc("GET /api/engine/export/config", ADMINAUTH(api.ExportConfig))
@@ -178,6 +182,22 @@ func (router *Router) Start() {
 	mux.HandleFunc("GET /admin/teams", ADMINAUTH(router.AdministrateTeamsPage))
 	mux.HandleFunc("GET /admin/appearance", ADMINAUTH(router.AdministrateAppearancePage))
 
+	/******************************************
+	|                                         |
+	|              RUNNER ROUTES              |
+	|                                         |
+	******************************************/
+
+	// remote runners poll these constantly, so they are not logged like user requests
+	RUNNERAUTH := middleware.RunnerAuthentication
+	mux.HandleFunc("POST /api/runner/tasks/lease", RUNNERAUTH(api.LeaseRunnerTask))
+	mux.HandleFunc("POST /api/runner/tasks/{lease}/extend", RUNNERAUTH(api.ExtendRunnerTask))
+	mux.HandleFunc("POST /api/runner/tasks/{lease}/release", RUNNERAUTH(api.ReleaseRunnerTask))
+	mux.HandleFunc("POST /api/runner/tasks/{lease}/result", RUNNERAUTH(api.PushRunnerResult))
+	mux.HandleFunc("POST /api/runner/tasks/{lease}/ack", RUNNERAUTH(api.AckRunnerTask))
+	mux.HandleFunc("POST /api/runner/heartbeat", RUNNERAUTH(api.RunnerHeartbeat))
+	mux.HandleFunc("POST /api/runner/deregister", RUNNERAUTH(api.DeregisterRunner))
+
 	// start server with security headers middleware wrapping all routes
 	securityMiddleware := middleware.SecurityHeaders(router.Config)
 	server := http.Server{